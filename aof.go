@@ -0,0 +1,274 @@
+package goredis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls how often the append only file is synced to disk.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs after every write command, so acknowledged writes
+	// survive a power failure.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncEverysec syncs once a second, losing at most a second of writes.
+	FsyncEverysec FsyncPolicy = "everysec"
+	// FsyncNo leaves syncing to the operating system.
+	FsyncNo FsyncPolicy = "no"
+)
+
+// appendFsyncInterval is how often FsyncEverysec syncs the file.
+const appendFsyncInterval = time.Second
+
+// appendOnlyFile logs write commands as RESP arrays, the same format clients
+// send them in, so the file can be replayed through dispatch.
+type appendOnlyFile struct {
+	lock   sync.Mutex
+	file   *os.File
+	writer *respWriter
+	fsync  FsyncPolicy
+	closed bool
+	// appended counts the commands written and synced those known to be on
+	// disk. syncLock lets one syncAlways at a time sync without holding
+	// lock, so writers are not held up meanwhile.
+	appended int64
+	synced   int64
+	syncLock sync.Mutex
+}
+
+func openAppendOnlyFile(path string, fsync FsyncPolicy) (*appendOnlyFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &appendOnlyFile{
+		file:   file,
+		writer: newRespWriter(file),
+		fsync:  fsync,
+	}, nil
+}
+
+// append writes command to the file. Every command reaches the operating
+// system before append returns. With FsyncAlways, it is synced later by
+// syncAlways, which append's callers hold dbLock for, so the disk does not
+// hold up every other client.
+func (a *appendOnlyFile) append(command []string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.closed {
+		return errors.New("append only file is closed")
+	}
+
+	elements := make([]any, len(command))
+	for i, arg := range command {
+		elements[i] = arg
+	}
+	a.writer.WriteArray(elements)
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+	a.appended += 1
+	return nil
+}
+
+// syncAlways syncs the file if the policy is FsyncAlways and commands were
+// appended since the last sync. Clients call it after each command, before
+// the reply can be sent, so acknowledged writes are on disk. A client that
+// waits for another's sync is often covered by it.
+func (a *appendOnlyFile) syncAlways() error {
+	a.syncLock.Lock()
+	defer a.syncLock.Unlock()
+	a.lock.Lock()
+	appended := a.appended
+	skip := a.closed || a.fsync != FsyncAlways || a.synced == appended
+	a.lock.Unlock()
+	if skip {
+		return nil
+	}
+
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.lock.Lock()
+	a.synced = appended
+	a.lock.Unlock()
+	return nil
+}
+
+func (a *appendOnlyFile) setFsync(fsync FsyncPolicy) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.fsync = fsync
+}
+
+// syncEverysec syncs the file if the policy is FsyncEverysec.
+func (a *appendOnlyFile) syncEverysec() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.closed || a.fsync != FsyncEverysec {
+		return nil
+	}
+	return a.file.Sync()
+}
+
+func (a *appendOnlyFile) close() error {
+	a.syncLock.Lock()
+	defer a.syncLock.Unlock()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	syncErr := a.file.Sync()
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// propagate records a command that changed the keyspace in the append only
+// file and queues it for the replicas. Handlers call it while still holding
+// dbLock for writing, so the log and the replicas get the changes in the
+// order they were applied in. Commands whose effect depends on when they
+// run, such as EXPIRE, are propagated in an absolute form like PEXPIREAT.
+// The writes of a transaction are wrapped in MULTI and EXEC, so they are
+// replayed as a single step too.
+func (s *server) propagate(command ...string) {
+	if s.inExec && !s.multiPropagated {
+		s.multiPropagated = true
+		s.writePropagated([]string{"MULTI"})
+	}
+	s.writePropagated(command)
+}
+
+// writePropagated sends command to the append only file and the replicas.
+// Callers must hold dbLock for writing.
+func (s *server) writePropagated(command []string) {
+	if s.appendOnly != nil {
+		if err := s.appendOnly.append(command); err != nil {
+			s.logger.Error("cannot write to append only file", slog.String("err", err.Error()))
+		}
+	}
+	s.feedReplicas(command)
+}
+
+// syncAppendOnly makes sure the writes of the command a client just ran are
+// on disk before its reply is sent, when the policy is FsyncAlways. It runs
+// without dbLock, like the writes to the client.
+func (s *server) syncAppendOnly() {
+	if s.appendOnly == nil {
+		return
+	}
+	if err := s.appendOnly.syncAlways(); err != nil {
+		s.logger.Error("cannot sync append only file", slog.String("err", err.Error()))
+	}
+}
+
+// loadAppendOnlyFile rebuilds the keyspace by running every command in the
+// append only file. A command cut short by a crash is dropped and the file
+// is truncated after the last complete one.
+func (s *server) loadAppendOnlyFile() error {
+	file, err := os.OpenFile(s.appendOnlyPath, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	replay := &client{
+		writer:        newRespWriter(io.Discard),
+		authenticated: true,
+	}
+	reader := bufio.NewReader(file)
+	// offset is the position reader has reached in the file.
+	offset := func() (int64, error) {
+		position, err := file.Seek(0, io.SeekCurrent)
+		return position - int64(reader.Buffered()), err
+	}
+
+	loaded := 0
+	// multiStart is where the transaction being read starts, or -1 outside
+	// of one.
+	multiStart := int64(-1)
+	for {
+		start, err := offset()
+		if err != nil {
+			return err
+		}
+		command, err := readArray(reader, true)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			end, err := offset()
+			if err != nil {
+				return err
+			}
+			// A transaction cut short was never run, and commands appended
+			// from now on must not end up in it.
+			if multiStart >= 0 {
+				s.logger.Warn("truncating incomplete transaction at the end of the append only file", slog.Int64("offset", multiStart))
+				if err := file.Truncate(multiStart); err != nil {
+					return err
+				}
+				break
+			}
+			if end == start {
+				break
+			}
+			s.logger.Warn("truncating incomplete command at the end of the append only file", slog.Int64("offset", start))
+			if err := file.Truncate(start); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("command %d: %w", loaded+1, err)
+		}
+		if len(command) == 0 {
+			continue
+		}
+		switch {
+		case strings.EqualFold(command[0], "multi"):
+			multiStart = start
+		case strings.EqualFold(command[0], "exec"):
+			multiStart = -1
+		}
+		s.dispatch(replay, command)
+		loaded += 1
+	}
+
+	s.logger.Info("append only file loaded", slog.String("path", s.appendOnlyPath), slog.Int("commands", loaded))
+	return nil
+}
+
+// appendFsyncCycle syncs the append only file once a second when the policy
+// is FsyncEverysec, and closes the file when the server stops.
+func (s *server) appendFsyncCycle() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(appendFsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			if err := s.appendOnly.close(); err != nil {
+				s.logger.Error("cannot close append only file", slog.String("err", err.Error()))
+			}
+			return
+		case <-ticker.C:
+			if err := s.appendOnly.syncEverysec(); err != nil {
+				s.logger.Error("cannot sync append only file", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package goredis_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestAppendOnlyTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	addr, stop := redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"SET", "key", "value"}, "+QUEUED\r\n"},
+		{[]string{"GET", "key"}, "+QUEUED\r\n"},
+		{[]string{"INCR", "counter"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*3\r\n+OK\r\n$5\r\nvalue\r\n:1\r\n"},
+		// A transaction that writes nothing leaves no trace.
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n$5\r\nvalue\r\n"},
+		{[]string{"SET", "after", "1"}, "+OK\r\n"},
+	})
+	stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "*1\r\n$5\r\nMULTI\r\n" +
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n" +
+		"*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n" +
+		"*1\r\n$4\r\nEXEC\r\n" +
+		"*3\r\n$3\r\nSET\r\n$5\r\nafter\r\n$1\r\n1\r\n"
+	if string(data) != want {
+		t.Errorf("append only file = %q, want %q", data, want)
+	}
+
+	addr, _ = redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	run(t, redistest.Connect(t, addr), []step{
+		{[]string{"GET", "key"}, "$5\r\nvalue\r\n"},
+		{[]string{"GET", "counter"}, "$1\r\n1\r\n"},
+		{[]string{"GET", "after"}, "$1\r\n1\r\n"},
+	})
+}
+
+func TestAppendOnlyIncompleteTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	complete := "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"
+	// The server stopped before writing EXEC.
+	incomplete := "*1\r\n$5\r\nMULTI\r\n*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	if err := os.WriteFile(path, []byte(complete+incomplete), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, stop := redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	run(t, redistest.Connect(t, addr), []step{
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+		{[]string{"GET", "b"}, "$-1\r\n"},
+		{[]string{"SET", "c", "3"}, "+OK\r\n"},
+	})
+	stop()
+
+	// The transaction was cut off the file, so later writes replay on
+	// their own.
+	addr, _ = redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	run(t, redistest.Connect(t, addr), []step{
+		{[]string{"GET", "b"}, "$-1\r\n"},
+		{[]string{"GET", "c"}, "$1\r\n3\r\n"},
+	})
+}
+
+func TestAppendFsyncAlways(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	addr, stop := redistest.StartServer(t, goredis.WithAppendOnlyFile(path), goredis.WithAppendFsync(goredis.FsyncAlways))
+
+	// Clients writing at once share syncs, and every write is in the file
+	// by the time it is acknowledged.
+	done := make(chan struct{})
+	for i := range 4 {
+		c := redistest.Connect(t, addr)
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for j := range 50 {
+				if got := c.Do("SET", fmt.Sprintf("key:%d:%d", i, j), "value"); got != "+OK\r\n" {
+					t.Errorf("SET = %q", got)
+					return
+				}
+			}
+		}()
+	}
+	for range 4 {
+		<-done
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "*3\r\n$3\r\nSET\r\n"); n != 200 {
+		t.Errorf("append only file holds %d SETs, want 200", n)
+	}
+	stop()
+
+	addr, _ = redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	run(t, redistest.Connect(t, addr), []step{{[]string{"DBSIZE"}, ":200\r\n"}})
+}
+
+func TestAppendOnlyReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	addr, stop := redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"INCR", "a"}, ":2\r\n"},
+		{[]string{"SET", "short", "x", "PX", "50"}, "+OK\r\n"},
+		{[]string{"SET", "e", "y"}, "+OK\r\n"},
+		{[]string{"EXPIRE", "e", "100"}, ":1\r\n"},
+		{[]string{"MSET", "c", "1", "d", "2"}, "+OK\r\n"},
+		{[]string{"DEL", "d", "missing"}, ":1\r\n"},
+		{[]string{"APPEND", "c", "z"}, ":2\r\n"},
+		{[]string{"RENAME", "c", "renamed"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "a", "b"}, ":2\r\n"},
+		{[]string{"LPOP", "list"}, "$1\r\na\r\n"},
+		// Reads and failed writes are not logged.
+		{[]string{"GET", "a"}, "$1\r\n2\r\n"},
+		{[]string{"INCR", "list"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+	stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Relative expiration times are logged as absolute ones, so replaying
+	// the file later does not extend them.
+	if strings.Contains(string(data), "EXPIRE\r\n") || !strings.Contains(string(data), "PEXPIREAT\r\n$1\r\ne\r\n") {
+		t.Errorf("append only file = %q, want expiration times as PEXPIREAT", data)
+	}
+	if strings.Contains(string(data), "GET\r\n") {
+		t.Errorf("append only file = %q, want no reads", data)
+	}
+
+	// The server stopped partway through writing a command.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("*2\r\n$3\r\nDEL\r\n$1\r\na")
+	f.Close()
+	// By the time the file is replayed, short has expired.
+	time.Sleep(100 * time.Millisecond)
+
+	addr, stop = redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	c = redistest.Connect(t, addr)
+	run(t, c, []step{
+		{[]string{"GET", "a"}, "$1\r\n2\r\n"},
+		{[]string{"EXISTS", "short"}, ":0\r\n"},
+		{[]string{"TTL", "e"}, ":100\r\n"},
+		{[]string{"GET", "renamed"}, "$2\r\n1z\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*1\r\n$1\r\nb\r\n"},
+		{[]string{"DBSIZE"}, ":4\r\n"},
+		{[]string{"SET", "new", "1"}, "+OK\r\n"},
+	})
+	stop()
+
+	// The partial command was cut off, so the next write follows the last
+	// complete one.
+	replayed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(data) + "*3\r\n$3\r\nSET\r\n$3\r\nnew\r\n$1\r\n1\r\n"; string(replayed) != want {
+		t.Errorf("append only file = %q, want %q", replayed, want)
+	}
+}
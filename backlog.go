@@ -0,0 +1,85 @@
+package goredis
+
+import (
+	"strconv"
+)
+
+// defaultReplBacklogSize is the size of the replication backlog unless
+// changed with repl-backlog-size, and minReplBacklogSize is the smallest
+// size used, as in Redis.
+const (
+	defaultReplBacklogSize = 1024 * 1024
+	minReplBacklogSize     = 16 * 1024
+)
+
+// replicationBacklog keeps the most recent bytes of the replication stream,
+// so a replica that lost its link can continue from the offset it reached
+// instead of loading a snapshot again.
+type replicationBacklog struct {
+	// data is a ring buffer holding the last length bytes of the stream,
+	// which end at the replication offset end, just before next.
+	data   []byte
+	next   int
+	length int
+	end    int64
+}
+
+// newReplicationBacklog returns an empty backlog of size bytes for a stream
+// that is at offset.
+func newReplicationBacklog(size int, offset int64) *replicationBacklog {
+	return &replicationBacklog{
+		data: make([]byte, max(size, minReplBacklogSize)),
+		end:  offset,
+	}
+}
+
+// write appends p to the stream, overwriting the oldest bytes once the
+// backlog is full.
+func (b *replicationBacklog) write(p []byte) {
+	b.end += int64(len(p))
+	if len(p) >= len(b.data) {
+		copy(b.data, p[len(p)-len(b.data):])
+		b.next = 0
+		b.length = len(b.data)
+		return
+	}
+	n := copy(b.data[b.next:], p)
+	copy(b.data, p[n:])
+	b.next = (b.next + len(p)) % len(b.data)
+	b.length = min(b.length+len(p), len(b.data))
+}
+
+// start returns the offset of the oldest byte the backlog holds.
+func (b *replicationBacklog) start() int64 {
+	return b.end - int64(b.length)
+}
+
+// since returns the bytes of the stream from offset on, and reports false
+// if the backlog does not hold all of them.
+func (b *replicationBacklog) since(offset int64) ([]byte, bool) {
+	if offset < b.start() || offset > b.end {
+		return nil, false
+	}
+	missing := make([]byte, b.end-offset)
+	from := (b.next - len(missing) + len(b.data)) % len(b.data)
+	n := copy(missing, b.data[from:])
+	copy(missing[n:], b.data)
+	return missing, true
+}
+
+// encodeCommand returns command as a RESP array, the form it takes in the
+// replication stream.
+func encodeCommand(command []string) []byte {
+	data := make([]byte, 0, 16*len(command))
+	data = append(data, '*')
+	data = strconv.AppendInt(data, int64(len(command)), 10)
+	data = append(data, '\r', '\n')
+	for _, arg := range command {
+		data = append(data, '$')
+		data = strconv.AppendInt(data, int64(len(arg)), 10)
+		data = append(data, '\r', '\n')
+		data = append(data, arg...)
+		data = append(data, '\r', '\n')
+	}
+	return data
+}
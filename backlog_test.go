@@ -0,0 +1,65 @@
+package goredis
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplicationBacklog(t *testing.T) {
+	b := newReplicationBacklog(0, 100)
+	if len(b.data) != minReplBacklogSize {
+		t.Fatalf("backlog has %d bytes, want %d", len(b.data), minReplBacklogSize)
+	}
+
+	// Write enough to wrap around a few times, remembering the whole
+	// stream to compare with.
+	var stream []byte
+	for i := 0; len(stream) < 3*minReplBacklogSize; i += 1 {
+		data := encodeCommand([]string{"SET", "key", string(rune('a' + i%26))})
+		b.write(data)
+		stream = append(stream, data...)
+	}
+	if b.end != 100+int64(len(stream)) {
+		t.Errorf("end = %d, want %d", b.end, 100+len(stream))
+	}
+	if b.start() != b.end-minReplBacklogSize {
+		t.Errorf("start = %d, want %d", b.start(), b.end-minReplBacklogSize)
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"end", b.end, true},
+		{"one byte", b.end - 1, true},
+		{"oldest byte", b.start(), true},
+		{"overwritten", b.start() - 1, false},
+		{"future", b.end + 1, false},
+	}
+	for _, test := range tests {
+		got, ok := b.since(test.offset)
+		if ok != test.ok {
+			t.Errorf("%s: since(%d) ok = %v, want %v", test.name, test.offset, ok, test.ok)
+			continue
+		}
+		if ok && !bytes.Equal(got, stream[test.offset-100:]) {
+			t.Errorf("%s: since(%d) returned %d bytes that differ from the stream", test.name, test.offset, len(got))
+		}
+	}
+
+	// A write larger than the backlog keeps its end.
+	large := bytes.Repeat([]byte("x"), 2*minReplBacklogSize)
+	large[len(large)-1] = 'y'
+	b.write(large)
+	if got, ok := b.since(b.end - 2); !ok || string(got) != "xy" {
+		t.Errorf("since after a large write = %q, %v", got, ok)
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	got := string(encodeCommand([]string{"SET", "key", ""}))
+	if want := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n"; got != want {
+		t.Errorf("encodeCommand = %q, want %q", got, want)
+	}
+}
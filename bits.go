@@ -0,0 +1,150 @@
+package goredis
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset bounds SETBIT offsets so the string never grows past the
+// longest bulk string.
+const maxBitOffset = maxBulkLength*8 - 1
+
+// parseBitOffset parses the offset argument of SETBIT and GETBIT.
+func parseBitOffset(value string) (int64, bool) {
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		return 0, false
+	}
+	return offset, true
+}
+
+// bitAt returns the bit at offset in value, where bit 0 is the most
+// significant bit of the first byte. Bits past the end are 0.
+func bitAt(value string, offset int64) int64 {
+	i := offset / 8
+	if i >= int64(len(value)) {
+		return 0
+	}
+	return int64(value[i]>>(7-offset%8)) & 1
+}
+
+// handleSetbitCommand serves SETBIT key offset value, growing the string with
+// zero bytes as needed, and replies with the previous bit.
+func (s *server) handleSetbitCommand(c *client, command []string) error {
+	key := command[1]
+
+	offset, ok := parseBitOffset(command[2])
+	if !ok {
+		return c.writer.WriteError("ERR bit offset is not an integer or out of range")
+	}
+	if command[3] != "0" && command[3] != "1" {
+		return c.writer.WriteError("ERR bit is not an integer or out of range")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	value, _, wrongType := lookupTyped[string](s, key)
+	old := bitAt(value, offset)
+	if !wrongType {
+		buf := []byte(value)
+		if i := int(offset / 8); i >= len(buf) {
+			buf = append(buf, make([]byte, i+1-len(buf))...)
+		}
+		mask := byte(1) << (7 - offset%8)
+		if command[3] == "1" {
+			buf[offset/8] |= mask
+		} else {
+			buf[offset/8] &^= mask
+		}
+		s.storeValue(key, string(buf))
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyString, "setbit", key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(old)
+}
+
+func (s *server) handleGetbitCommand(c *client, command []string) error {
+	key := command[1]
+
+	offset, ok := parseBitOffset(command[2])
+	if !ok {
+		return c.writer.WriteError("ERR bit offset is not an integer or out of range")
+	}
+
+	s.dbLock.RLock()
+	value, ok, wrongType := lookupTyped[string](s, key)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(bitAt(value, offset))
+}
+
+// handleBitcountCommand serves BITCOUNT key [start end [BYTE | BIT]], which
+// counts the set bits of the string, optionally between the inclusive byte or
+// bit offsets start and end. Negative offsets count from the end.
+func (s *server) handleBitcountCommand(c *client, command []string) error {
+	if len(command) == 3 {
+		return c.writer.WriteError("ERR syntax error")
+	}
+	key := command[1]
+
+	var start, end int
+	inBits := false
+	if len(command) >= 4 {
+		var err error
+		if start, err = strconv.Atoi(command[2]); err != nil {
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+		if end, err = strconv.Atoi(command[3]); err != nil {
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+	}
+	if len(command) == 5 {
+		switch strings.ToUpper(command[4]) {
+		case "BYTE":
+		case "BIT":
+			inBits = true
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.RLock()
+	value, ok, wrongType := lookupTyped[string](s, key)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+
+	if len(command) == 2 {
+		start, end = 0, -1
+	}
+	count := int64(0)
+	if inBits {
+		lo, hi, empty := normalizeRange(start, end, len(value)*8)
+		for offset := lo; !empty && offset <= hi; offset += 1 {
+			count += bitAt(value, int64(offset))
+		}
+		return c.writer.WriteInteger(count)
+	}
+	lo, hi, empty := normalizeRange(start, end, len(value))
+	for i := lo; !empty && i <= hi; i += 1 {
+		count += int64(bits.OnesCount8(value[i]))
+	}
+	return c.writer.WriteInteger(count)
+}
@@ -0,0 +1,66 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSetbit(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		// SETBIT replies with the bit's old value. Bit 0 is the most
+		// significant bit of the first byte.
+		{[]string{"SETBIT", "key", "7", "1"}, ":0\r\n"},
+		{[]string{"SETBIT", "key", "7", "1"}, ":1\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n\x01\r\n"},
+		// The string grows with zero bytes to hold the bit.
+		{[]string{"SETBIT", "key", "23", "1"}, ":0\r\n"},
+		{[]string{"GET", "key"}, "$3\r\n\x01\x00\x01\r\n"},
+		{[]string{"SETBIT", "key", "8", "1"}, ":0\r\n"},
+		{[]string{"SETBIT", "key", "8", "0"}, ":1\r\n"},
+		{[]string{"STRLEN", "key"}, ":3\r\n"},
+
+		{[]string{"SETBIT", "key", "4294967296", "1"}, "-ERR bit offset is not an integer or out of range\r\n"},
+		{[]string{"SETBIT", "key", "-1", "1"}, "-ERR bit offset is not an integer or out of range\r\n"},
+		{[]string{"SETBIT", "key", "1", "2"}, "-ERR bit is not an integer or out of range\r\n"},
+		{[]string{"RPUSH", "list", "x"}, ":1\r\n"},
+		{[]string{"SETBIT", "list", "1", "1"}, wrongType},
+	})
+}
+
+func TestGetbit(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "\x80\x01"}, "+OK\r\n"},
+		{[]string{"GETBIT", "key", "0"}, ":1\r\n"},
+		{[]string{"GETBIT", "key", "1"}, ":0\r\n"},
+		{[]string{"GETBIT", "key", "15"}, ":1\r\n"},
+		// Bits past the end of the string, or of a missing key, are 0.
+		{[]string{"GETBIT", "key", "1000"}, ":0\r\n"},
+		{[]string{"GETBIT", "missing", "0"}, ":0\r\n"},
+		{[]string{"GETBIT", "key", "-1"}, "-ERR bit offset is not an integer or out of range\r\n"},
+	})
+}
+
+func TestBitcount(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "\x01\x80\x01"}, "+OK\r\n"},
+		{[]string{"BITCOUNT", "key"}, ":3\r\n"},
+		// Ranges are in bytes unless BIT is given, and may be negative.
+		{[]string{"BITCOUNT", "key", "1", "-1"}, ":2\r\n"},
+		{[]string{"BITCOUNT", "key", "0", "0", "BYTE"}, ":1\r\n"},
+		{[]string{"BITCOUNT", "key", "7", "8", "BIT"}, ":2\r\n"},
+		{[]string{"BITCOUNT", "key", "2", "1"}, ":0\r\n"},
+		{[]string{"BITCOUNT", "missing"}, ":0\r\n"},
+		{[]string{"BITCOUNT", "key", "0"}, "-ERR syntax error\r\n"},
+		{[]string{"BITCOUNT", "key", "0", "1", "WORD"}, "-ERR syntax error\r\n"},
+	})
+}
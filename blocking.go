@@ -0,0 +1,102 @@
+package goredis
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"time"
+)
+
+// blockedClient is a connection waiting in BLPOP or BRPOP for one of its
+// keys to receive elements. ready is signalled whenever one of them changes.
+// The waiters of each key are kept in the order they blocked, and only the
+// first one may pop from it.
+type blockedClient struct {
+	ready chan struct{}
+}
+
+// blockOnKeys registers a client waiting for any of keys to change. Callers
+// must hold dbLock for writing, in the same critical section that found the
+// keys empty, so no push can slip in between.
+func (s *server) blockOnKeys(keys []string) *blockedClient {
+	b := &blockedClient{ready: make(chan struct{}, 1)}
+	for _, key := range keys {
+		if !slices.Contains(s.blockedKeys[key], b) {
+			s.blockedKeys[key] = append(s.blockedKeys[key], b)
+		}
+	}
+	return b
+}
+
+// unblock removes b from the waiters of keys, deleting lists that become
+// empty so they do not pile up. The next waiters of keys that hold elements
+// are woken, since b may have left without taking the element it was woken
+// for. Callers must hold dbLock for writing.
+func (s *server) unblock(b *blockedClient, keys []string) {
+	for _, key := range keys {
+		waiters := slices.DeleteFunc(s.blockedKeys[key], func(w *blockedClient) bool {
+			return w == b
+		})
+		if len(waiters) == 0 {
+			delete(s.blockedKeys, key)
+			continue
+		}
+		s.blockedKeys[key] = waiters
+		if _, ok := s.database[key]; ok {
+			s.signalBlocked(key)
+		}
+	}
+}
+
+// firstInLine returns the keys for which b is the first waiter, or which
+// have no waiters at all. b is nil for a client that has not blocked yet,
+// which comes after every waiter. Callers must hold dbLock.
+func (s *server) firstInLine(b *blockedClient, keys []string) []string {
+	eligible := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if waiters := s.blockedKeys[key]; len(waiters) == 0 || waiters[0] == b {
+			eligible = append(eligible, key)
+		}
+	}
+	return eligible
+}
+
+// signalBlocked wakes the clients blocked on key. They recheck the key once
+// the caller releases dbLock, and all but the first in line go back to
+// waiting. Popping wakes them again while elements remain. Callers must hold
+// dbLock for writing.
+func (s *server) signalBlocked(key string) {
+	for _, b := range s.blockedKeys[key] {
+		select {
+		case b.ready <- struct{}{}:
+		default:
+			// The client has a wakeup pending already.
+		}
+	}
+}
+
+// watchDisconnect detects the peer of c closing the connection while c is
+// blocked and not reading requests: the returned channel is closed when that
+// happens. stop ends the watch and must be called before c reads again.
+// Commands the client pipelines in the meantime stay in its reader.
+func watchDisconnect(c *client) (gone <-chan struct{}, stop func()) {
+	closed := make(chan struct{})
+	finished := make(chan struct{})
+	// A blocked client is not idle, so the idle timeout does not apply.
+	c.conn.SetReadDeadline(time.Time{})
+	go func() {
+		defer close(finished)
+		_, err := c.reader.Peek(1)
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			close(closed)
+		}
+	}()
+	return closed, func() {
+		// Interrupt Peek, then clear the deadline for the next read.
+		// handleConn checks for shutdown before reading, so clearing a
+		// deadline set by Stop is harmless.
+		c.conn.SetReadDeadline(time.Now())
+		<-finished
+		c.conn.SetReadDeadline(time.Time{})
+	}
+}
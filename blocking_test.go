@@ -0,0 +1,98 @@
+package goredis_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// blockDelay is how long tests wait for a blocking command to reach the
+// server before the next step.
+const blockDelay = 100 * time.Millisecond
+
+func TestBlpopUnblockedByPush(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	blocked := redistest.Connect(t, addr)
+	pusher := redistest.Connect(t, addr)
+
+	blocked.Send("BLPOP", "empty", "list", "0")
+	time.Sleep(blockDelay)
+	run(t, pusher, []step{{[]string{"RPUSH", "list", "a", "b"}, ":2\r\n"}})
+
+	if got, want := blocked.Receive(), "*2\r\n$4\r\nlist\r\n$1\r\na\r\n"; got != want {
+		t.Errorf("BLPOP = %q, want %q", got, want)
+	}
+	run(t, pusher, []step{{[]string{"LRANGE", "list", "0", "-1"}, "*1\r\n$1\r\nb\r\n"}})
+}
+
+func TestBlpopTimeout(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	start := time.Now()
+	run(t, c, []step{{[]string{"BLPOP", "list", "0.1"}, "*-1\r\n"}})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("BLPOP returned after %v", elapsed)
+	}
+}
+
+func TestBlockedClientsServedInOrder(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	pusher := redistest.Connect(t, addr)
+
+	var clients []*redistest.Client
+	for range 5 {
+		c := redistest.Connect(t, addr)
+		c.Send("BRPOP", "list", "0")
+		time.Sleep(blockDelay)
+		clients = append(clients, c)
+	}
+	// A client that blocks on another key as well still waits its turn.
+	late := redistest.Connect(t, addr)
+	late.Send("BLPOP", "other", "list", "0")
+	time.Sleep(blockDelay)
+
+	run(t, pusher, []step{{[]string{"LPUSH", "list", "a", "b", "c", "d", "e", "f"}, ":6\r\n"}})
+	for i, element := range []string{"a", "b", "c", "d", "e"} {
+		want := "*2\r\n$4\r\nlist\r\n$1\r\n" + element + "\r\n"
+		if got := clients[i].Receive(); got != want {
+			t.Errorf("client %d got %q, want %q", i, got, want)
+		}
+	}
+	if got, want := late.Receive(), "*2\r\n$4\r\nlist\r\n$1\r\nf\r\n"; got != want {
+		t.Errorf("last client got %q, want %q", got, want)
+	}
+}
+
+func TestBlockedClientDisconnects(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	blocked := redistest.Connect(t, addr)
+
+	blocked.Send("BLPOP", "list", "other", "0")
+	time.Sleep(blockDelay)
+	if info := c.Do("INFO", "clients"); !strings.Contains(info, "blocked_clients:1\r\n") {
+		t.Fatalf("INFO clients with a blocked client:\n%s", info)
+	}
+
+	// The waiter goes away with the connection.
+	blocked.Conn().Close()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(c.Do("INFO", "clients"), "blocked_clients:0\r\n") {
+		if time.Now().After(deadline) {
+			t.Fatal("the disconnected client is still blocked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Pushing keeps the element, which the next client gets.
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a"}, ":1\r\n"},
+		{[]string{"RPUSH", "other", "b"}, ":1\r\n"},
+		{[]string{"LLEN", "list"}, ":1\r\n"},
+		{[]string{"BLPOP", "list", "0"}, "*2\r\n$4\r\nlist\r\n$1\r\na\r\n"},
+		{[]string{"BLPOP", "other", "0"}, "*2\r\n$5\r\nother\r\n$1\r\nb\r\n"},
+	})
+}
@@ -0,0 +1,121 @@
+package goredis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// client holds the state of a single connection.
+type client struct {
+	id   int64
+	conn net.Conn
+	// reader buffers requests from conn, so pipelined commands that arrive
+	// together are read from memory. read counts the bytes it received.
+	reader *bufio.Reader
+	read   byteCounter
+	// writer buffers replies to conn until there are no more pipelined
+	// commands to process, and holds the negotiated protocol version. Other
+	// connections write to it when they publish to a channel c subscribed to.
+	writer *respWriter
+	// written counts the bytes sent to conn. It belongs to the first writer,
+	// which keeps sending to conn after SYNC hands it to the replication
+	// stream and replaces writer.
+	written *byteCounter
+
+	// createdAt is when the connection was accepted.
+	createdAt time.Time
+	// name is set with CLIENT SETNAME. It is guarded by the server's
+	// clientsLock, since CLIENT LIST reads it from other connections.
+	name string
+
+	// db is the index of the selected database.
+	db int
+	// authenticated is set once the connection passed AUTH.
+	authenticated bool
+	// closeAfterReply makes handleConn close the connection once the reply
+	// to the current command has been sent.
+	closeAfterReply bool
+
+	// channels and patterns are the subscriptions of c. They are guarded by
+	// the server's pubsubLock.
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	// inMulti is set between MULTI and EXEC or DISCARD, while commands are
+	// added to queued instead of running. multiFailed records that one of
+	// them was rejected, which makes EXEC abort.
+	inMulti     bool
+	queued      [][]string
+	multiFailed bool
+	// watching maps each key c watches to its version at the time of WATCH.
+	watching map[string]int64
+
+	// isReplica is set once c sent SYNC or PSYNC, after which it receives
+	// the write commands the server runs. listeningPort is the port the
+	// replica announced with REPLCONF. It is guarded by the server's
+	// replicasLock, since INFO reads it from other connections.
+	isReplica     bool
+	listeningPort string
+	// isMonitor is set once c sent MONITOR, after which it receives the
+	// commands other clients run.
+	isMonitor bool
+}
+
+// newClient returns the state of a new connection. The bytes it reads and
+// writes are added to inputTotal and outputTotal.
+func newClient(id int64, conn net.Conn, inputTotal *atomic.Int64, outputTotal *atomic.Int64) *client {
+	c := &client{
+		id:     id,
+		conn:   conn,
+		writer: newRespWriter(conn),
+
+		createdAt: time.Now(),
+		name:      "",
+
+		db:              0,
+		authenticated:   false,
+		closeAfterReply: false,
+
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+
+		inMulti:     false,
+		queued:      nil,
+		multiFailed: false,
+		watching:    make(map[string]int64),
+	}
+	c.read.total = inputTotal
+	c.reader = bufio.NewReader(countingReader{conn, &c.read})
+	c.written = &c.writer.written
+	c.written.total = outputTotal
+	return c
+}
+
+// byteCounter counts the bytes a connection reads or writes, and adds them
+// to total, which counts them for all connections, when it is set.
+type byteCounter struct {
+	count atomic.Int64
+	total *atomic.Int64
+}
+
+func (b *byteCounter) add(n int) {
+	b.count.Add(int64(n))
+	if b.total != nil {
+		b.total.Add(int64(n))
+	}
+}
+
+// countingReader counts the bytes read from reader.
+type countingReader struct {
+	reader  io.Reader
+	counter *byteCounter
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.counter.add(n)
+	return n, err
+}
@@ -0,0 +1,56 @@
+package goredis
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+)
+
+func TestClientOverPipe(t *testing.T) {
+	s := NewServer(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	conn, peer := net.Pipe()
+	c := newClient(1, conn, &s.netInputBytes, &s.netOutputBytes)
+	s.clients[c.id] = c
+	s.connections.Add(1)
+	go s.handleConn(c)
+
+	reader := bufio.NewReader(peer)
+	steps := []struct {
+		request string
+		want    []string
+	}{
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"$-1\r\n"}},
+		{"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n", []string{"+OK\r\n"}},
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"$5\r\n", "value\r\n"}},
+	}
+	for _, step := range steps {
+		if _, err := io.WriteString(peer, step.request); err != nil {
+			t.Fatalf("cannot send %q: %v", step.request, err)
+		}
+		for _, want := range step.want {
+			got, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("cannot read the reply to %q: %v", step.request, err)
+			}
+			if got != want {
+				t.Errorf("reply to %q = %q, want %q", step.request, got, want)
+			}
+		}
+	}
+
+	// Closing the connection ends handleConn, which forgets the client.
+	peer.Close()
+	s.connections.Wait()
+	if _, ok := s.clients[c.id]; ok {
+		t.Error("client is still registered after its connection closed")
+	}
+	want := 0
+	for _, step := range steps {
+		want += len(step.request)
+	}
+	if got := c.read.count.Load(); got != int64(want) {
+		t.Errorf("client read %d bytes, want %d", got, want)
+	}
+}
@@ -0,0 +1,223 @@
+package goredis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// commandInfo describes a command: the handler that runs it, the arguments
+// dispatch lets through to it, and what COMMAND replies about it.
+type commandInfo struct {
+	handler func(s *server, c *client, command []string) error
+	// arity counts the command name too. A positive arity is the exact
+	// number of arguments and a negative one is the minimum.
+	arity int
+	// maxArity, when set, is the largest number of arguments a command with
+	// a negative arity accepts.
+	maxArity int
+	// argGroup, when set, is the size of the groups that the arguments past
+	// the minimum come in, such as the field value pairs of HSET.
+	argGroup int
+	flags    []string
+	// firstKey, lastKey and step locate the key arguments. A negative
+	// lastKey counts from the end, and firstKey 0 means there are no keys.
+	firstKey int
+	lastKey  int
+	step     int
+}
+
+// commandTable lists every command the server implements, keyed by lower
+// case name. It is filled in by init because handlers such as COMMAND refer
+// to it, which a variable initializer cannot do.
+var commandTable map[string]commandInfo
+
+func init() {
+	commandTable = map[string]commandInfo{
+		"append":        {handler: (*server).handleAppendCommand, arity: 3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"auth":          {handler: (*server).handleAuthCommand, arity: 2, flags: []string{"noscript", "loading", "stale", "fast"}},
+		"bgsave":        {handler: (*server).handleBgsaveCommand, arity: 1, flags: []string{"admin", "noscript"}},
+		"bitcount":      {handler: (*server).handleBitcountCommand, arity: -2, maxArity: 5, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"blpop":         {handler: (*server).handleBlockingPopCommand, arity: -3, flags: []string{"write", "blocking"}, firstKey: 1, lastKey: -2, step: 1},
+		"brpop":         {handler: (*server).handleBlockingPopCommand, arity: -3, flags: []string{"write", "blocking"}, firstKey: 1, lastKey: -2, step: 1},
+		"client":        {handler: (*server).handleClientCommand, arity: -2, flags: []string{"admin", "noscript", "loading", "stale"}},
+		"command":       {handler: (*server).handleCommandCommand, arity: -1, flags: []string{"loading", "stale"}},
+		"config":        {handler: (*server).handleConfigCommand, arity: -2, flags: []string{"admin", "noscript", "loading", "stale"}},
+		"copy":          {handler: (*server).handleCopyCommand, arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1},
+		"dbsize":        {handler: (*server).handleDbsizeCommand, arity: 1, flags: []string{"readonly", "fast"}},
+		"debug":         {handler: (*server).handleDebugCommand, arity: -2, flags: []string{"admin", "noscript", "loading", "stale"}},
+		"decr":          {handler: (*server).handleDecrCommand, arity: 2, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"del":           {handler: (*server).handleDelCommand, arity: -2, flags: []string{"write"}, firstKey: 1, lastKey: -1, step: 1},
+		"discard":       {handler: (*server).handleDiscardCommand, arity: 1, flags: []string{"noscript", "loading", "stale", "fast"}},
+		"echo":          {handler: (*server).handleEchoCommand, arity: 2, flags: []string{"fast"}},
+		"exec":          {handler: (*server).handleExecCommand, arity: 1, flags: []string{"noscript", "loading", "stale", "skip_slowlog"}},
+		"exists":        {handler: (*server).handleExistsCommand, arity: -2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: -1, step: 1},
+		"expire":        {handler: (*server).handleExpireCommand, arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"expireat":      {handler: (*server).handleExpireatCommand, arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"flushall":      {handler: (*server).handleFlushCommand, arity: -1, maxArity: 2, flags: []string{"write"}},
+		"flushdb":       {handler: (*server).handleFlushCommand, arity: -1, maxArity: 2, flags: []string{"write"}},
+		"get":           {handler: (*server).handleGetCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"getbit":        {handler: (*server).handleGetbitCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"getdel":        {handler: (*server).handleGetdelCommand, arity: 2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"getex":         {handler: (*server).handleGetexCommand, arity: -2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"getrange":      {handler: (*server).handleGetrangeCommand, arity: 4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"getset":        {handler: (*server).handleGetsetCommand, arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hdel":          {handler: (*server).handleHdelCommand, arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hello":         {handler: (*server).handleHelloCommand, arity: -1, flags: []string{"noscript", "loading", "stale", "fast"}},
+		"hexists":       {handler: (*server).handleHexistsCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hget":          {handler: (*server).handleHgetCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hgetall":       {handler: (*server).handleHgetallCommand, arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"hkeys":         {handler: (*server).handleHkeysCommand, arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"hlen":          {handler: (*server).handleHlenCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hmget":         {handler: (*server).handleHmgetCommand, arity: -3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hrandfield":    {handler: (*server).handleHrandfieldCommand, arity: -2, maxArity: 4, flags: []string{"readonly", "random"}, firstKey: 1, lastKey: 1, step: 1},
+		"hset":          {handler: (*server).handleHsetCommand, arity: -4, argGroup: 2, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"hvals":         {handler: (*server).handleHvalsCommand, arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"incr":          {handler: (*server).handleIncrCommand, arity: 2, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"info":          {handler: (*server).handleInfoCommand, arity: -1, flags: []string{"loading", "stale"}},
+		"keys":          {handler: (*server).handleKeysCommand, arity: 2, flags: []string{"readonly"}},
+		"lastsave":      {handler: (*server).handleLastsaveCommand, arity: 1, flags: []string{"random", "loading", "stale", "fast"}},
+		"lcs":           {handler: (*server).handleLcsCommand, arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 2, step: 1},
+		"linsert":       {handler: (*server).handleLinsertCommand, arity: 5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"llen":          {handler: (*server).handleLlenCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"lmove":         {handler: (*server).handleLmoveCommand, arity: 5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1},
+		"lpop":          {handler: (*server).handlePopCommand, arity: -2, maxArity: 3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"lpos":          {handler: (*server).handleLposCommand, arity: -3, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"lpush":         {handler: (*server).handlePushCommand, arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"lrange":        {handler: (*server).handleLrangeCommand, arity: 4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"lrem":          {handler: (*server).handleLremCommand, arity: 4, flags: []string{"write"}, firstKey: 1, lastKey: 1, step: 1},
+		"lset":          {handler: (*server).handleLsetCommand, arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"ltrim":         {handler: (*server).handleLtrimCommand, arity: 4, flags: []string{"write"}, firstKey: 1, lastKey: 1, step: 1},
+		"memory":        {handler: (*server).handleMemoryCommand, arity: -2, flags: []string{"readonly"}},
+		"mget":          {handler: (*server).handleMgetCommand, arity: -2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: -1, step: 1},
+		"monitor":       {handler: (*server).handleMonitorCommand, arity: 1, flags: []string{"admin", "noscript", "loading", "stale"}},
+		"mset":          {handler: (*server).handleMsetCommand, arity: -3, argGroup: 2, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 2},
+		"multi":         {handler: (*server).handleMultiCommand, arity: 1, flags: []string{"noscript", "loading", "stale", "fast"}},
+		"object":        {handler: (*server).handleObjectCommand, arity: -2, flags: []string{"readonly"}, firstKey: 2, lastKey: 2, step: 1},
+		"persist":       {handler: (*server).handlePersistCommand, arity: 2, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"pexpireat":     {handler: (*server).handleExpireatCommand, arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"ping":          {handler: (*server).handlePingCommand, arity: -1, maxArity: 2, flags: []string{"fast"}},
+		"psetex":        {handler: (*server).handleSetexCommand, arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"psubscribe":    {handler: (*server).handlePsubscribeCommand, arity: -2, flags: []string{"pubsub", "noscript", "loading", "stale"}},
+		"psync":         {handler: (*server).handleSyncCommand, arity: -3, flags: []string{"admin", "noscript"}},
+		"pttl":          {handler: (*server).handleTtlCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"publish":       {handler: (*server).handlePublishCommand, arity: 3, flags: []string{"pubsub", "loading", "stale", "fast"}},
+		"pubsub":        {handler: (*server).handlePubsubCommand, arity: -2, flags: []string{"pubsub", "random", "loading", "stale"}},
+		"punsubscribe":  {handler: (*server).handlePunsubscribeCommand, arity: -1, flags: []string{"pubsub", "noscript", "loading", "stale"}},
+		"quit":          {handler: (*server).handleQuitCommand, arity: -1, flags: []string{"fast"}},
+		"randomkey":     {handler: (*server).handleRandomkeyCommand, arity: 1, flags: []string{"readonly", "random"}},
+		"rename":        {handler: (*server).handleRenameCommand, arity: 3, flags: []string{"write"}, firstKey: 1, lastKey: 2, step: 1},
+		"renamenx":      {handler: (*server).handleRenamenxCommand, arity: 3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 2, step: 1},
+		"replconf":      {handler: (*server).handleReplconfCommand, arity: -1, flags: []string{"admin", "noscript", "loading", "stale"}},
+		"replicaof":     {handler: (*server).handleReplicaofCommand, arity: 3, flags: []string{"admin", "noscript", "stale"}},
+		"rpop":          {handler: (*server).handlePopCommand, arity: -2, maxArity: 3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"rpoplpush":     {handler: (*server).handleRpoplpushCommand, arity: 3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1},
+		"rpush":         {handler: (*server).handlePushCommand, arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"sadd":          {handler: (*server).handleSaddCommand, arity: -3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"save":          {handler: (*server).handleSaveCommand, arity: 1, flags: []string{"admin", "noscript"}},
+		"scan":          {handler: (*server).handleScanCommand, arity: -2, flags: []string{"readonly"}},
+		"scard":         {handler: (*server).handleScardCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"sdiff":         {handler: (*server).handleSetAlgebraCommand, arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1},
+		"sdiffstore":    {handler: (*server).handleSetAlgebraStoreCommand, arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1},
+		"set":           {handler: (*server).handleSetCommand, arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"setbit":        {handler: (*server).handleSetbitCommand, arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"setex":         {handler: (*server).handleSetexCommand, arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"setnx":         {handler: (*server).handleSetnxCommand, arity: 3, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"setrange":      {handler: (*server).handleSetrangeCommand, arity: 4, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 1, step: 1},
+		"sinter":        {handler: (*server).handleSetAlgebraCommand, arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1},
+		"sintercard":    {handler: (*server).handleSintercardCommand, arity: -3, flags: []string{"readonly", "movablekeys"}},
+		"sinterstore":   {handler: (*server).handleSetAlgebraStoreCommand, arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1},
+		"sismember":     {handler: (*server).handleSismemberCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"slaveof":       {handler: (*server).handleReplicaofCommand, arity: 3, flags: []string{"admin", "noscript", "stale"}},
+		"slowlog":       {handler: (*server).handleSlowlogCommand, arity: -2, flags: []string{"admin", "random", "loading", "stale"}},
+		"smembers":      {handler: (*server).handleSmembersCommand, arity: 2, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"spop":          {handler: (*server).handleSpopCommand, arity: -2, maxArity: 3, flags: []string{"write", "random", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"srandmember":   {handler: (*server).handleSrandmemberCommand, arity: -2, maxArity: 3, flags: []string{"readonly", "random"}, firstKey: 1, lastKey: 1, step: 1},
+		"srem":          {handler: (*server).handleSremCommand, arity: -3, flags: []string{"write", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"strlen":        {handler: (*server).handleStrlenCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"subscribe":     {handler: (*server).handleSubscribeCommand, arity: -2, flags: []string{"pubsub", "noscript", "loading", "stale"}},
+		"sunion":        {handler: (*server).handleSetAlgebraCommand, arity: -2, flags: []string{"readonly"}, firstKey: 1, lastKey: -1, step: 1},
+		"sunionstore":   {handler: (*server).handleSetAlgebraStoreCommand, arity: -3, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: -1, step: 1},
+		"sync":          {handler: (*server).handleSyncCommand, arity: 1, flags: []string{"admin", "noscript"}},
+		"ttl":           {handler: (*server).handleTtlCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"type":          {handler: (*server).handleTypeCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"unsubscribe":   {handler: (*server).handleUnsubscribeCommand, arity: -1, flags: []string{"pubsub", "noscript", "loading", "stale"}},
+		"unwatch":       {handler: (*server).handleUnwatchCommand, arity: 1, flags: []string{"noscript", "loading", "stale", "fast"}},
+		"wait":          {handler: (*server).handleWaitCommand, arity: 3, flags: []string{"noscript"}},
+		"watch":         {handler: (*server).handleWatchCommand, arity: -2, flags: []string{"noscript", "loading", "stale", "fast"}, firstKey: 1, lastKey: -1, step: 1},
+		"zadd":          {handler: (*server).handleZaddCommand, arity: -4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zcard":         {handler: (*server).handleZcardCommand, arity: 2, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zincrby":       {handler: (*server).handleZincrbyCommand, arity: 4, flags: []string{"write", "denyoom", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zlexcount":     {handler: (*server).handleZlexcountCommand, arity: 4, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrandmember":   {handler: (*server).handleZrandmemberCommand, arity: -2, maxArity: 4, flags: []string{"readonly", "random"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrange":        {handler: (*server).handleZrangeCommand, arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrangebylex":   {handler: (*server).handleZrangebylexCommand, arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrangebyscore": {handler: (*server).handleZrangebyscoreCommand, arity: -4, flags: []string{"readonly"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrangestore":   {handler: (*server).handleZrangestoreCommand, arity: -5, flags: []string{"write", "denyoom"}, firstKey: 1, lastKey: 2, step: 1},
+		"zrank":         {handler: (*server).handleZrankCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zrevrank":      {handler: (*server).handleZrankCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+		"zscore":        {handler: (*server).handleZscoreCommand, arity: 3, flags: []string{"readonly", "fast"}, firstKey: 1, lastKey: 1, step: 1},
+	}
+}
+
+// checkArity reports whether a command with n arguments, counting its name,
+// satisfies the arity of info.
+func checkArity(info commandInfo, n int) bool {
+	if info.arity >= 0 {
+		return n == info.arity
+	}
+	if n < -info.arity || (info.maxArity > 0 && n > info.maxArity) {
+		return false
+	}
+	return info.argGroup == 0 || (n+info.arity)%info.argGroup == 0
+}
+
+// arityError returns the error for a command called with the wrong number
+// of arguments.
+func arityError(name string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", name)
+}
+
+// describeCommand returns the COMMAND reply entry for a command.
+func describeCommand(name string, info commandInfo) []any {
+	flags := make([]any, len(info.flags))
+	for i, flag := range info.flags {
+		flags[i] = flag
+	}
+	return []any{
+		name,
+		int64(info.arity),
+		flags,
+		int64(info.firstKey),
+		int64(info.lastKey),
+		int64(info.step),
+	}
+}
+
+// handleCommandCommand serves COMMAND, COMMAND COUNT and COMMAND DOCS, which
+// client libraries send while setting up a connection.
+func (s *server) handleCommandCommand(c *client, command []string) error {
+	if len(command) == 1 {
+		names := make([]string, 0, len(commandTable))
+		for name := range commandTable {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		reply := make([]any, len(names))
+		for i, name := range names {
+			reply[i] = describeCommand(name, commandTable[name])
+		}
+		return c.writer.WriteArray(reply)
+	}
+
+	switch strings.ToUpper(command[1]) {
+	case "COUNT":
+		return c.writer.WriteInteger(int64(len(commandTable)))
+	case "DOCS":
+		// Documentation is optional for clients, so an empty reply is enough.
+		return c.writer.WriteArray([]any{})
+	default:
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try COMMAND HELP.", command[1]))
+	}
+}
@@ -0,0 +1,80 @@
+package goredis_test
+
+import (
+	"strings"
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestArity(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key"}, "-ERR wrong number of arguments for 'set' command\r\n"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"GET", "a", "b"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		// Commands with a maximum number of arguments.
+		{[]string{"PING", "a", "b"}, "-ERR wrong number of arguments for 'ping' command\r\n"},
+		{[]string{"LPOP", "list", "1", "2"}, "-ERR wrong number of arguments for 'lpop' command\r\n"},
+		{[]string{"BITCOUNT", "key", "0", "1", "BIT", "extra"}, "-ERR wrong number of arguments for 'bitcount' command\r\n"},
+		// Commands taking pairs of arguments.
+		{[]string{"HSET", "hash", "field"}, "-ERR wrong number of arguments for 'hset' command\r\n"},
+		{[]string{"HSET", "hash", "a", "1", "b"}, "-ERR wrong number of arguments for 'hset' command\r\n"},
+		{[]string{"MSET", "a", "1", "b"}, "-ERR wrong number of arguments for 'mset' command\r\n"},
+		{[]string{"HSET", "hash", "a", "1", "b", "2"}, ":2\r\n"},
+		{[]string{"MSET", "a", "1", "b", "2"}, "+OK\r\n"},
+		{[]string{"PING", "hello"}, "$5\r\nhello\r\n"},
+		// An option without its value is a syntax error, as in Redis.
+		{[]string{"SCAN", "0", "COUNT"}, "-ERR syntax error\r\n"},
+		{[]string{"SCAN", "0", "MATCH", "*", "COUNT"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestCommand(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// COMMAND describes as many commands as COMMAND COUNT counts.
+	reply := c.Do("COMMAND")
+	count := c.Do("COMMAND", "COUNT")
+	header, _, _ := strings.Cut(reply, "\r\n")
+	if header != "*"+strings.Trim(count, ":\r\n") {
+		t.Errorf("COMMAND has %s entries but COMMAND COUNT = %q", header, count)
+	}
+
+	for _, want := range []string{
+		"*6\r\n$3\r\nget\r\n:2\r\n*2\r\n$8\r\nreadonly\r\n$4\r\nfast\r\n:1\r\n:1\r\n:1\r\n",
+		"*6\r\n$4\r\nmset\r\n:-3\r\n*2\r\n$5\r\nwrite\r\n$7\r\ndenyoom\r\n:1\r\n:-1\r\n:2\r\n",
+		"*6\r\n$4\r\nping\r\n:-1\r\n*1\r\n$4\r\nfast\r\n:0\r\n:0\r\n:0\r\n",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("COMMAND lacks %q", want)
+		}
+	}
+
+	run(t, c, []step{
+		{[]string{"COMMAND", "DOCS"}, "*0\r\n"},
+		{[]string{"command", "docs", "get"}, "*0\r\n"},
+		{[]string{"COMMAND", "FOO"}, "-ERR unknown subcommand 'FOO'. Try COMMAND HELP.\r\n"},
+	})
+}
+
+func TestUnknownCommand(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"FOO", "bar", "baz"}, "-ERR unknown command 'FOO', with args beginning with: 'bar' 'baz' \r\n"},
+		{[]string{"foo"}, "-ERR unknown command 'foo', with args beginning with: \r\n"},
+		// The connection carries on.
+		{[]string{"PING"}, "+PONG\r\n"},
+	})
+
+	// The quoted arguments are cut off once they reach 128 bytes.
+	a, b := strings.Repeat("a", 100), strings.Repeat("b", 100)
+	run(t, c, []step{
+		{[]string{"foo", a, b, "c"}, "-ERR unknown command 'foo', with args beginning with: '" + a + "' '" + b[:25] + "' \r\n"},
+	})
+}
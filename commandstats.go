@@ -0,0 +1,56 @@
+package goredis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// commandStat counts the calls of a command and the time spent running it.
+type commandStat struct {
+	calls int64
+	usec  int64
+}
+
+// recordCall adds a call of command by c that began at start to the
+// statistics and to the slow log. It is meant to be deferred around the
+// handler.
+func (s *server) recordCall(c *client, command []string, start time.Time) {
+	duration := time.Since(start)
+	name := strings.ToLower(command[0])
+	s.statsLock.Lock()
+	stat, ok := s.commandStats[name]
+	if !ok {
+		stat = &commandStat{}
+		s.commandStats[name] = stat
+	}
+	stat.calls += 1
+	stat.usec += duration.Microseconds()
+	s.statsLock.Unlock()
+
+	s.logSlowCommand(c, command, start, duration)
+}
+
+// infoCommandstats reports a cmdstat_name line for every command called at
+// least once, in the format of Redis.
+func (s *server) infoCommandstats() []infoField {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+
+	names := make([]string, 0, len(s.commandStats))
+	for name := range s.commandStats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fields := make([]infoField, len(names))
+	for i, name := range names {
+		stat := s.commandStats[name]
+		fields[i] = infoField{
+			"cmdstat_" + name,
+			fmt.Sprintf("calls=%d,usec=%d,usec_per_call=%.2f", stat.calls, stat.usec, float64(stat.usec)/float64(stat.calls)),
+		}
+	}
+	return fields
+}
@@ -0,0 +1,52 @@
+package goredis_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestCommandstats(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"INFO", "commandstats"}, "$16\r\n# Commandstats\r\n\r\n"},
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$5\r\nvalue\r\n"},
+		{[]string{"get", "key"}, "$5\r\nvalue\r\n"},
+		// Commands are counted when a transaction runs them, not when they
+		// are queued.
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n$5\r\nvalue\r\n"},
+		// Commands that are refused are not counted.
+		{[]string{"NOSUCHCOMMAND"}, "-ERR unknown command 'NOSUCHCOMMAND', with args beginning with: \r\n"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"DEBUG", "SLEEP", "0.01"}, "+OK\r\n"},
+	})
+
+	stats := c.Do("INFO", "commandstats")
+	for _, want := range []string{
+		`cmdstat_debug:calls=1,usec=\d{5,},usec_per_call=\d+\.\d\d\r\n`,
+		`cmdstat_exec:calls=1,`,
+		`cmdstat_get:calls=3,usec=\d+,usec_per_call=\d+\.\d\d\r\n`,
+		`cmdstat_info:calls=1,`,
+		`cmdstat_multi:calls=1,`,
+		`cmdstat_set:calls=1,`,
+	} {
+		if !regexp.MustCompile(want).MatchString(stats) {
+			t.Errorf("INFO commandstats does not match %q:\n%s", want, stats)
+		}
+	}
+	if strings.Contains(stats, "nosuchcommand") {
+		t.Errorf("INFO commandstats counts an unknown command:\n%s", stats)
+	}
+	// Commands are listed by name.
+	if i, j := strings.Index(stats, "cmdstat_debug"), strings.Index(stats, "cmdstat_set"); i > j {
+		t.Errorf("INFO commandstats is not sorted:\n%s", stats)
+	}
+}
@@ -0,0 +1,329 @@
+package goredis
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serverConfig holds the settings that CONFIG SET can change while the
+// server is running. Read them with getConfig.
+type serverConfig struct {
+	idleTimeout time.Duration
+	maxClients  int
+	// maxMemory is the memory limit in bytes, or 0 for no limit.
+	maxMemory       int64
+	maxMemoryPolicy EvictionPolicy
+	appendFsync     FsyncPolicy
+	// notifyKeyspaceEvents selects the keyspace notifications to publish.
+	notifyKeyspaceEvents keyspaceEvents
+	// slowlogLogSlowerThan is the duration above which commands are added
+	// to the slow log. A negative value disables the slow log.
+	slowlogLogSlowerThan time.Duration
+	slowlogMaxLen        int
+	// replBacklogSize is the size of the replication backlog in bytes.
+	replBacklogSize int
+	// listMaxListpackSize and listMaxListpackValue are the limits under
+	// which OBJECT ENCODING reports a list as a listpack.
+	listMaxListpackSize  int
+	listMaxListpackValue int
+}
+
+// getConfig returns a copy of the current runtime settings.
+func (s *server) getConfig() serverConfig {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.config
+}
+
+// configParameter describes a parameter for CONFIG GET and, when set is not
+// nil, CONFIG SET. get reads the value from config, a single copy taken for
+// all the parameters of a CONFIG GET so that it sees either all or none of
+// a CONFIG SET. set validates the value and applies it to config, and
+// returns an error message for invalid values.
+type configParameter struct {
+	get func(s *server, config serverConfig) string
+	set func(s *server, config *serverConfig, value string) string
+}
+
+var configParameters = map[string]configParameter{
+	"timeout": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.FormatInt(int64(config.idleTimeout/time.Second), 10)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 0 || seconds > int64(time.Duration(1<<63-1)/time.Second) {
+				return "argument couldn't be parsed into an integer"
+			}
+			config.idleTimeout = time.Duration(seconds) * time.Second
+			return ""
+		},
+	},
+	"maxclients": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.Itoa(config.maxClients)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return "argument must be a positive integer"
+			}
+			config.maxClients = n
+			return ""
+		},
+	},
+	"maxmemory": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.FormatInt(config.maxMemory, 10)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, ok := parseMemory(value)
+			if !ok {
+				return "argument must be a memory value"
+			}
+			config.maxMemory = n
+			return ""
+		},
+	},
+	"maxmemory-policy": {
+		get: func(s *server, config serverConfig) string {
+			return string(config.maxMemoryPolicy)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			policy := EvictionPolicy(strings.ToLower(value))
+			if policy != NoEviction && policy != AllKeysLRU {
+				return "argument(s) must be one of the following: noeviction, allkeys-lru"
+			}
+			config.maxMemoryPolicy = policy
+			return ""
+		},
+	},
+	"appendfsync": {
+		get: func(s *server, config serverConfig) string {
+			return string(config.appendFsync)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			policy := FsyncPolicy(strings.ToLower(value))
+			if policy != FsyncAlways && policy != FsyncEverysec && policy != FsyncNo {
+				return "argument(s) must be one of the following: always, everysec, no"
+			}
+			config.appendFsync = policy
+			return ""
+		},
+	},
+	"notify-keyspace-events": {
+		get: func(s *server, config serverConfig) string {
+			return config.notifyKeyspaceEvents.String()
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			events, ok := parseKeyspaceEvents(value)
+			if !ok {
+				return "Invalid event class character. Use 'Ag$lshzxeKEn'."
+			}
+			config.notifyKeyspaceEvents = events
+			return ""
+		},
+	},
+	"slowlog-log-slower-than": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.FormatInt(config.slowlogLogSlowerThan.Microseconds(), 10)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			usec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || usec > int64(time.Duration(1<<63-1)/time.Microsecond) {
+				return "argument couldn't be parsed into an integer"
+			}
+			if usec < 0 {
+				usec = -1
+			}
+			config.slowlogLogSlowerThan = time.Duration(usec) * time.Microsecond
+			return ""
+		},
+	},
+	"slowlog-max-len": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.Itoa(config.slowlogMaxLen)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "argument must be a non-negative integer"
+			}
+			config.slowlogMaxLen = n
+			return ""
+		},
+	},
+	"repl-backlog-size": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.Itoa(config.replBacklogSize)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, ok := parseMemory(value)
+			if !ok || n > int64(maxBulkLength) {
+				return "argument must be a memory value"
+			}
+			config.replBacklogSize = int(n)
+			return ""
+		},
+	},
+	"list-max-listpack-size": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.Itoa(config.listMaxListpackSize)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, err := strconv.Atoi(value)
+			if err != nil || n == 0 || n < -5 {
+				return "argument must be a positive integer or between -5 and -1"
+			}
+			config.listMaxListpackSize = n
+			return ""
+		},
+	},
+	"list-max-listpack-value": {
+		get: func(s *server, config serverConfig) string {
+			return strconv.Itoa(config.listMaxListpackValue)
+		},
+		set: func(s *server, config *serverConfig, value string) string {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "argument must be a non-negative integer"
+			}
+			config.listMaxListpackValue = n
+			return ""
+		},
+	},
+	"appendonly": {
+		get: func(s *server, config serverConfig) string {
+			if s.appendOnlyPath != "" {
+				return "yes"
+			}
+			return "no"
+		},
+	},
+	"enable-debug-command": {
+		get: func(s *server, config serverConfig) string {
+			if s.debugEnabled {
+				return "yes"
+			}
+			return "no"
+		},
+	},
+	"unixsocket": {
+		get: func(s *server, config serverConfig) string {
+			return s.unixSocketPath
+		},
+	},
+	"replicaof": {
+		get: func(s *server, config serverConfig) string {
+			s.replicationLock.Lock()
+			defer s.replicationLock.Unlock()
+			if s.masterHost == "" {
+				return ""
+			}
+			return s.masterHost + " " + s.masterPort
+		},
+	},
+	"requirepass": {
+		get: func(s *server, config serverConfig) string {
+			return s.password
+		},
+	},
+}
+
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"kb", 1024},
+	{"mb", 1024 * 1024},
+	{"gb", 1024 * 1024 * 1024},
+	{"k", 1000},
+	{"m", 1000 * 1000},
+	{"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory parses a byte count with an optional unit suffix such as
+// 100mb or 1gb.
+func parseMemory(value string) (int64, bool) {
+	number := strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSuffix(number, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/multiplier {
+		return 0, false
+	}
+	return n * multiplier, true
+}
+
+// handleConfigCommand serves CONFIG GET pattern [pattern ...] and CONFIG SET
+// parameter value [parameter value ...].
+func (s *server) handleConfigCommand(c *client, command []string) error {
+	switch subcommand := strings.ToUpper(command[1]); {
+	case subcommand == "GET" && len(command) >= 3:
+		return s.handleConfigGet(c, command[2:])
+	case subcommand == "SET" && len(command) >= 4 && len(command)%2 == 0:
+		return s.handleConfigSet(c, command[2:])
+	case subcommand == "SET":
+		return c.writer.WriteError("ERR wrong number of arguments for 'config|set' command")
+	default:
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try CONFIG HELP.", command[1]))
+	}
+}
+
+func (s *server) handleConfigGet(c *client, patterns []string) error {
+	names := make([]string, 0, len(configParameters))
+	for name := range configParameters {
+		for _, pattern := range patterns {
+			if matchGlob(strings.ToLower(pattern), name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	slices.Sort(names)
+
+	config := s.getConfig()
+	pairs := make([]any, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, name, configParameters[name].get(s, config))
+	}
+	return c.writer.WriteMap(pairs)
+}
+
+// handleConfigSet validates every parameter before applying any of them, so
+// a CONFIG SET with an invalid value changes nothing.
+func (s *server) handleConfigSet(c *client, args []string) error {
+	s.configLock.Lock()
+	config := s.config
+	for i := 0; i < len(args); i += 2 {
+		name := strings.ToLower(args[i])
+		parameter, ok := configParameters[name]
+		if !ok || parameter.set == nil {
+			s.configLock.Unlock()
+			return c.writer.WriteError(fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", args[i]))
+		}
+		if message := parameter.set(s, &config, args[i+1]); message != "" {
+			s.configLock.Unlock()
+			return c.writer.WriteError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", args[i], message))
+		}
+	}
+	s.config = config
+	s.configLock.Unlock()
+
+	if s.appendOnly != nil {
+		s.appendOnly.setFsync(config.appendFsync)
+	}
+	s.resizeBacklog(config.replBacklogSize)
+
+	return c.writer.WriteSimpleString("OK")
+}
@@ -0,0 +1,80 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestConfig(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"CONFIG", "GET", "maxclients"}, "*2\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n"},
+		{[]string{"CONFIG", "SET", "maxclients", "50"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "MAXCLIENTS"}, "*2\r\n$10\r\nmaxclients\r\n$2\r\n50\r\n"},
+		// Patterns select parameters in name order, each once.
+		{[]string{"CONFIG", "GET", "maxmemory*", "maxmemory"}, "*4\r\n$9\r\nmaxmemory\r\n$1\r\n0\r\n$16\r\nmaxmemory-policy\r\n$10\r\nnoeviction\r\n"},
+		{[]string{"CONFIG", "GET", "nosuchparameter"}, "*0\r\n"},
+		// Memory values take units.
+		{[]string{"CONFIG", "SET", "maxmemory", "1kb"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "maxmemory"}, "*2\r\n$9\r\nmaxmemory\r\n$4\r\n1024\r\n"},
+		{[]string{"CONFIG", "SET", "maxmemory", "0", "timeout", "30"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "timeout"}, "*2\r\n$7\r\ntimeout\r\n$2\r\n30\r\n"},
+
+		{[]string{"CONFIG", "SET", "maxclients", "0"}, "-ERR CONFIG SET failed (possibly related to argument 'maxclients') - argument must be a positive integer\r\n"},
+		{[]string{"CONFIG", "SET", "maxmemory-policy", "volatile-lru"}, "-ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy') - argument(s) must be one of the following: noeviction, allkeys-lru\r\n"},
+		{[]string{"CONFIG", "SET", "nosuchparameter", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'nosuchparameter'\r\n"},
+		// Some parameters can only be read.
+		{[]string{"CONFIG", "GET", "appendonly"}, "*2\r\n$10\r\nappendonly\r\n$2\r\nno\r\n"},
+		{[]string{"CONFIG", "SET", "appendonly", "yes"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'appendonly'\r\n"},
+		{[]string{"CONFIG", "SET", "maxclients"}, "-ERR wrong number of arguments for 'config|set' command\r\n"},
+		{[]string{"CONFIG", "SET", "maxclients", "1", "timeout"}, "-ERR wrong number of arguments for 'config|set' command\r\n"},
+		{[]string{"CONFIG", "GET"}, "-ERR unknown subcommand or wrong number of arguments for 'GET'. Try CONFIG HELP.\r\n"},
+		{[]string{"CONFIG", "FOO"}, "-ERR unknown subcommand or wrong number of arguments for 'FOO'. Try CONFIG HELP.\r\n"},
+	})
+
+	// RESP3 replies with a map.
+	c.Do("HELLO", "3")
+	run(t, c, []step{{[]string{"CONFIG", "GET", "timeout"}, "%1\r\n$7\r\ntimeout\r\n$2\r\n30\r\n"}})
+}
+
+func TestConfigSetIsAtomic(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// An invalid value anywhere in CONFIG SET leaves every parameter as it
+	// was, including those before it.
+	run(t, c, []step{
+		{[]string{"CONFIG", "SET", "maxclients", "50", "timeout", "x", "maxmemory", "1mb"}, "-ERR CONFIG SET failed (possibly related to argument 'timeout') - argument couldn't be parsed into an integer\r\n"},
+		{[]string{"CONFIG", "SET", "maxclients", "50", "nosuchparameter", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'nosuchparameter'\r\n"},
+		{[]string{"CONFIG", "GET", "maxclients", "timeout", "maxmemory"}, "*6\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n$9\r\nmaxmemory\r\n$1\r\n0\r\n$7\r\ntimeout\r\n$3\r\n300\r\n"},
+	})
+
+	// Concurrent CONFIG SETs apply whole: a reader sees either set of
+	// values, never a mix of the two.
+	done := make(chan struct{})
+	for _, value := range []string{"100", "200"} {
+		writer := redistest.Connect(t, addr)
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for range 200 {
+				if got := writer.Do("CONFIG", "SET", "maxclients", value, "slowlog-max-len", value); got != "+OK\r\n" {
+					t.Errorf("CONFIG SET = %q", got)
+					return
+				}
+			}
+		}()
+	}
+	for range 200 {
+		reply := c.Do("CONFIG", "GET", "maxclients", "slowlog-max-len")
+		if reply != "*4\r\n$10\r\nmaxclients\r\n$3\r\n100\r\n$15\r\nslowlog-max-len\r\n$3\r\n100\r\n" &&
+			reply != "*4\r\n$10\r\nmaxclients\r\n$3\r\n200\r\n$15\r\nslowlog-max-len\r\n$3\r\n200\r\n" &&
+			reply != "*4\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n$15\r\nslowlog-max-len\r\n$3\r\n128\r\n" {
+			t.Fatalf("CONFIG GET = %q, a mix of two CONFIG SETs", reply)
+		}
+	}
+	<-done
+	<-done
+}
@@ -0,0 +1,237 @@
+package goredis
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *server) handlePingCommand(c *client, command []string) error {
+	if s.inSubscriberMode(c) {
+		// Subscribers receive replies in the same shape as messages.
+		message := ""
+		if len(command) == 2 {
+			message = command[1]
+		}
+		return c.writer.WriteArray([]any{"pong", message})
+	}
+
+	if len(command) == 1 {
+		return c.writer.WriteSimpleString("PONG")
+	}
+	message := command[1]
+	return c.writer.WriteBulkString(message)
+}
+
+func (s *server) handleEchoCommand(c *client, command []string) error {
+	message := command[1]
+	return c.writer.WriteBulkString(message)
+}
+
+func (s *server) handleAuthCommand(c *client, command []string) error {
+	if s.password == "" {
+		return c.writer.WriteError("ERR Client sent AUTH, but no password is set")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(command[1]), []byte(s.password)) != 1 {
+		c.authenticated = false
+		return c.writer.WriteError("ERR invalid password")
+	}
+	c.authenticated = true
+	return c.writer.WriteSimpleString("OK")
+}
+
+// handleQuitCommand replies +OK and has handleConn close the connection once
+// the reply has been flushed.
+func (s *server) handleQuitCommand(c *client, command []string) error {
+	c.closeAfterReply = true
+	return c.writer.WriteSimpleString("OK")
+}
+
+// serverVersion is the Redis version reported to clients, which some client
+// libraries use to decide which commands they can send.
+const serverVersion = "7.2.0"
+
+// handleHelloCommand serves HELLO [protover [AUTH username password]], which
+// switches the connection's protocol version and replies with information
+// about the server.
+func (s *server) handleHelloCommand(c *client, command []string) error {
+	protocol := c.writer.Protocol()
+	if len(command) > 1 {
+		version, err := strconv.Atoi(command[1])
+		if err != nil {
+			return c.writer.WriteError("ERR Protocol version is not an integer or out of range")
+		}
+		if version != 2 && version != 3 {
+			return c.writer.WriteError("NOPROTO unsupported protocol version")
+		}
+		protocol = version
+	}
+
+	authenticated := c.authenticated
+	for i := 2; i < len(command); i += 1 {
+		option := strings.ToUpper(command[i])
+		if option != "AUTH" || i+2 >= len(command) {
+			return c.writer.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", command[i]))
+		}
+		if s.password == "" {
+			return c.writer.WriteError("ERR Client sent AUTH, but no password is set")
+		}
+		// Only the default user exists.
+		username, password := command[i+1], command[i+2]
+		if username != "default" || subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) != 1 {
+			return c.writer.WriteError("ERR invalid password")
+		}
+		authenticated = true
+		i += 2
+	}
+	if s.password != "" && !authenticated {
+		return c.writer.WriteError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	role := "master"
+	if s.isReplica() {
+		role = "slave"
+	}
+
+	c.authenticated = authenticated
+	c.writer.SetProtocol(protocol)
+	return c.writer.WriteMap([]any{
+		"server", "redis",
+		"version", serverVersion,
+		"proto", int64(protocol),
+		"id", c.id,
+		"mode", "standalone",
+		"role", role,
+		"modules", []any{},
+	})
+}
+
+// handleClientCommand serves CLIENT ID, CLIENT GETNAME, CLIENT SETNAME name,
+// CLIENT LIST and CLIENT KILL.
+func (s *server) handleClientCommand(c *client, command []string) error {
+	switch subcommand := strings.ToUpper(command[1]); {
+	case subcommand == "ID" && len(command) == 2:
+		return c.writer.WriteInteger(c.id)
+	case subcommand == "GETNAME" && len(command) == 2:
+		s.clientsLock.Lock()
+		name := c.name
+		s.clientsLock.Unlock()
+		if name == "" {
+			return c.writer.WriteNull()
+		}
+		return c.writer.WriteBulkString(name)
+	case subcommand == "SETNAME" && len(command) == 3:
+		name := command[2]
+		// Names appear in CLIENT LIST, where spaces would break parsing.
+		for i := 0; i < len(name); i += 1 {
+			if name[i] < '!' || name[i] > '~' {
+				return c.writer.WriteError("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+		}
+		s.clientsLock.Lock()
+		c.name = name
+		s.clientsLock.Unlock()
+		return c.writer.WriteSimpleString("OK")
+	case subcommand == "LIST" && len(command) == 2:
+		return c.writer.WriteBulkString(s.clientList())
+	case subcommand == "KILL" && len(command) == 3:
+		// The legacy form takes an address and replies +OK.
+		if command[2] == "" || s.killClients(c, 0, command[2], false) == 0 {
+			return c.writer.WriteError("ERR No such client")
+		}
+		return c.writer.WriteSimpleString("OK")
+	case subcommand == "KILL" && len(command) >= 4 && len(command)%2 == 0:
+		return s.handleClientKill(c, command[2:])
+	default:
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", command[1]))
+	}
+}
+
+// clientList describes every connection on its own line, ordered by id.
+func (s *server) clientList() string {
+	s.clientsLock.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	slices.SortFunc(clients, func(a, b *client) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	var list strings.Builder
+	now := time.Now()
+	for _, c := range clients {
+		fmt.Fprintf(&list, "id=%d addr=%s name=%s age=%d tot-net-in=%d tot-net-out=%d\n", c.id, c.conn.RemoteAddr(), c.name, int64(now.Sub(c.createdAt).Seconds()), c.read.count.Load(), c.written.count.Load())
+	}
+	s.clientsLock.Unlock()
+	return list.String()
+}
+
+// handleClientKill serves CLIENT KILL [ID id] [ADDR ip:port] [SKIPME yes|no],
+// which disconnects the clients matching every filter and replies with their
+// number. The caller itself is skipped unless SKIPME is no.
+func (s *server) handleClientKill(c *client, filters []string) error {
+	var id int64
+	addr := ""
+	skipMe := true
+	for i := 0; i < len(filters); i += 2 {
+		value := filters[i+1]
+		switch strings.ToUpper(filters[i]) {
+		case "ID":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n <= 0 {
+				return c.writer.WriteError("ERR client-id should be greater than 0")
+			}
+			id = n
+		case "ADDR":
+			addr = value
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return c.writer.WriteError("ERR syntax error")
+			}
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+	return c.writer.WriteInteger(int64(s.killClients(c, id, addr, skipMe)))
+}
+
+// killClients disconnects the clients with the given id and address, where
+// a zero value matches any client, and returns how many matched. At least
+// one of them must be set, so a missing filter never disconnects everyone.
+// Closing the connection makes the client's handleConn return and tear it
+// down. The caller is instead closed once its reply has been sent.
+func (s *server) killClients(caller *client, id int64, addr string, skipCaller bool) int {
+	if id == 0 && addr == "" {
+		return 0
+	}
+	s.clientsLock.Lock()
+	defer s.clientsLock.Unlock()
+
+	killed := 0
+	for _, c := range s.clients {
+		if (id != 0 && c.id != id) || (addr != "" && c.conn.RemoteAddr().String() != addr) {
+			continue
+		}
+		if c == caller {
+			if skipCaller {
+				continue
+			}
+			c.closeAfterReply = true
+		} else {
+			c.conn.Close()
+		}
+		killed += 1
+	}
+	return killed
+}
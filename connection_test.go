@@ -0,0 +1,450 @@
+package goredis_test
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// helloReply is the reply to HELLO, with %s standing for the map header and
+// %d for the protocol version, the client id and the role.
+const helloReply = "%s$6\r\nserver\r\n$5\r\nredis\r\n$7\r\nversion\r\n$5\r\n7.2.0\r\n" +
+	"$5\r\nproto\r\n:%d\r\n$2\r\nid\r\n:%d\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n" +
+	"$4\r\nrole\r\n%s$7\r\nmodules\r\n*0\r\n"
+
+func TestHello(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	master := "$6\r\nmaster\r\n"
+	run(t, c, []step{
+		{[]string{"HELLO"}, fmt.Sprintf(helloReply, "*14\r\n", 2, 1, master)},
+		{[]string{"HELLO", "2"}, fmt.Sprintf(helloReply, "*14\r\n", 2, 1, master)},
+		{[]string{"HELLO", "3"}, fmt.Sprintf(helloReply, "%7\r\n", 3, 1, master)},
+		// The connection now speaks RESP3.
+		{[]string{"GET", "missing"}, "_\r\n"},
+		{[]string{"HELLO"}, fmt.Sprintf(helloReply, "%7\r\n", 3, 1, master)},
+		{[]string{"HELLO", "2"}, fmt.Sprintf(helloReply, "*14\r\n", 2, 1, master)},
+		{[]string{"GET", "missing"}, "$-1\r\n"},
+		{[]string{"HELLO", "4"}, "-NOPROTO unsupported protocol version\r\n"},
+	})
+}
+
+func TestHelloReplica(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// The role does not depend on reaching the master.
+	run(t, c, []step{
+		{[]string{"REPLICAOF", "127.0.0.1", "1"}, "+OK\r\n"},
+		{[]string{"HELLO"}, fmt.Sprintf(helloReply, "*14\r\n", 2, 1, "$5\r\nslave\r\n")},
+		{[]string{"REPLICAOF", "NO", "ONE"}, "+OK\r\n"},
+		{[]string{"HELLO"}, fmt.Sprintf(helloReply, "*14\r\n", 2, 1, "$6\r\nmaster\r\n")},
+	})
+}
+
+func TestClientName(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"CLIENT", "ID"}, ":1\r\n"},
+		{[]string{"CLIENT", "GETNAME"}, "$-1\r\n"},
+		{[]string{"CLIENT", "SETNAME", "my name"}, "-ERR Client names cannot contain spaces, newlines or special characters.\r\n"},
+		{[]string{"CLIENT", "SETNAME", "worker-1"}, "+OK\r\n"},
+		{[]string{"CLIENT", "GETNAME"}, "$8\r\nworker-1\r\n"},
+		{[]string{"CLIENT", "SETNAME", ""}, "+OK\r\n"},
+		{[]string{"CLIENT", "GETNAME"}, "$-1\r\n"},
+		{[]string{"CLIENT", "GETNAME", "x"}, "-ERR unknown subcommand or wrong number of arguments for 'GETNAME'. Try CLIENT HELP.\r\n"},
+		{[]string{"CLIENT", "FOO"}, "-ERR unknown subcommand or wrong number of arguments for 'FOO'. Try CLIENT HELP.\r\n"},
+	})
+	run(t, redistest.Connect(t, addr), []step{{[]string{"CLIENT", "ID"}, ":2\r\n"}})
+}
+
+func TestClientList(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	other := redistest.Connect(t, addr)
+
+	run(t, c, []step{{[]string{"CLIENT", "SETNAME", "worker-1"}, "+OK\r\n"}})
+	run(t, other, []step{{[]string{"PING"}, "+PONG\r\n"}})
+
+	// Clients are listed by id, with the bytes they sent and received so
+	// far, which for the caller includes the CLIENT LIST request.
+	list := fmt.Sprintf("id=1 addr=%s name=worker-1 age=0 tot-net-in=%d tot-net-out=5\n"+
+		"id=2 addr=%s name= age=0 tot-net-in=%d tot-net-out=7\n",
+		c.Conn().LocalAddr(), requestSize("CLIENT", "SETNAME", "worker-1"),
+		other.Conn().LocalAddr(), requestSize("PING")+requestSize("CLIENT", "LIST"))
+	run(t, other, []step{
+		{[]string{"CLIENT", "LIST"}, fmt.Sprintf("$%d\r\n%s\r\n", len(list), list)},
+	})
+
+	// Disconnected clients leave the list.
+	c.Conn().Close()
+	want := fmt.Sprintf("id=2 addr=%s ", other.Conn().LocalAddr())
+	deadline := time.Now().Add(time.Second)
+	for {
+		reply := other.Do("CLIENT", "LIST")
+		if strings.Count(reply, "id=") == 1 && strings.Contains(reply, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("CLIENT LIST = %q after the first client disconnected", reply)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	other := redistest.Connect(t, addr)
+	otherAddr := other.Conn().LocalAddr().String()
+
+	run(t, c, []step{
+		// An empty address matches no client rather than every client.
+		{[]string{"CLIENT", "KILL", ""}, "-ERR No such client\r\n"},
+		{[]string{"CLIENT", "KILL", "ADDR", ""}, ":0\r\n"},
+		{[]string{"CLIENT", "KILL", "SKIPME", "no"}, ":0\r\n"},
+		{[]string{"PING"}, "+PONG\r\n"},
+	})
+	run(t, other, []step{{[]string{"PING"}, "+PONG\r\n"}})
+
+	run(t, c, []step{
+		{[]string{"CLIENT", "KILL", otherAddr}, "+OK\r\n"},
+		{[]string{"CLIENT", "KILL", otherAddr}, "-ERR No such client\r\n"},
+		{[]string{"CLIENT", "KILL", "ID", "999"}, ":0\r\n"},
+	})
+	other.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := other.Conn().Read(make([]byte, 1)); err == nil {
+		t.Error("killed client is still connected")
+	}
+}
+
+func TestClientKillFilters(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+
+	run(t, subscriber, []step{{[]string{"SUBSCRIBE", "channel"}, "*3\r\n$9\r\nsubscribe\r\n$7\r\nchannel\r\n:1\r\n"}})
+	run(t, c, []step{
+		// The caller is skipped by default.
+		{[]string{"CLIENT", "KILL", "ID", "1"}, ":0\r\n"},
+		{[]string{"CLIENT", "KILL", "ID", "0"}, "-ERR client-id should be greater than 0\r\n"},
+		{[]string{"CLIENT", "KILL", "ID", "x"}, "-ERR client-id should be greater than 0\r\n"},
+		{[]string{"CLIENT", "KILL", "SKIPME", "maybe"}, "-ERR syntax error\r\n"},
+		{[]string{"CLIENT", "KILL", "USER", "default"}, "-ERR syntax error\r\n"},
+		// Every filter must match.
+		{[]string{"CLIENT", "KILL", "ID", "2", "ADDR", c.Conn().LocalAddr().String()}, ":0\r\n"},
+		{[]string{"CLIENT", "KILL", "ID", "2", "ADDR", subscriber.Conn().LocalAddr().String()}, ":1\r\n"},
+	})
+	subscriber.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := subscriber.Conn().Read(make([]byte, 1)); err == nil {
+		t.Error("killed client is still connected")
+	}
+	// Killing a client tears down its subscriptions.
+	waitForReply(t, c, "*2\r\n$7\r\nchannel\r\n:0\r\n", "PUBSUB", "NUMSUB", "channel")
+
+	// With SKIPME no, the caller gets its reply before it is disconnected.
+	run(t, c, []step{{[]string{"CLIENT", "KILL", "ID", "1", "SKIPME", "no"}, ":1\r\n"}})
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := c.Conn().Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read %d bytes, %v after killing the caller, want EOF", n, err)
+	}
+}
+
+// requestSize returns the number of bytes a client sends for command.
+func requestSize(command ...string) int {
+	size := len(fmt.Sprintf("*%d\r\n", len(command)))
+	for _, arg := range command {
+		size += len(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+	return size
+}
+
+func TestNetworkBytes(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	value := strings.Repeat("x", 1000)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", value}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$1000\r\n" + value + "\r\n"},
+	})
+	input := requestSize("SET", "key", value) + requestSize("GET", "key") + requestSize("CLIENT", "LIST")
+	output := len("+OK\r\n") + len("$1000\r\n"+value+"\r\n")
+	list := c.Do("CLIENT", "LIST")
+	if want := fmt.Sprintf(" tot-net-in=%d tot-net-out=%d\n", input, output); !strings.Contains(list, want) {
+		t.Errorf("CLIENT LIST = %q, want it to contain %q", list, want)
+	}
+
+	// The totals cover every connection, including the one asking.
+	other := redistest.Connect(t, addr)
+	input += requestSize("INFO", "stats")
+	output += len(list)
+	info := other.Do("INFO", "stats")
+	for _, field := range []string{
+		fmt.Sprintf("total_net_input_bytes:%d\r\n", input),
+		fmt.Sprintf("total_net_output_bytes:%d\r\n", output),
+	} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO stats lacks %q:\n%s", field, info)
+		}
+	}
+}
+
+func TestOneReplyPerCommand(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	conn := redistest.Dial(t, addr)
+
+	io.WriteString(conn, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n")
+	want := "+OK\r\n$5\r\nvalue\r\n"
+	got := make([]byte, len(want))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("cannot read replies: %v (read so far: %q)", err, got)
+	}
+	if string(got) != want {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+
+	// Nothing follows the replies.
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := conn.Read(got); err == nil {
+		t.Errorf("read %q after the replies", got[:n])
+	}
+}
+
+func TestPing(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"PING"}, "+PONG\r\n"},
+		{[]string{"ping", "hello world"}, "$11\r\nhello world\r\n"},
+		{[]string{"PING", ""}, "$0\r\n\r\n"},
+		{[]string{"PING", "a", "b"}, "-ERR wrong number of arguments for 'ping' command\r\n"},
+		// Subscribers get a pong in the shape of a message.
+		{[]string{"SUBSCRIBE", "channel"}, "*3\r\n$9\r\nsubscribe\r\n$7\r\nchannel\r\n:1\r\n"},
+		{[]string{"PING"}, "*2\r\n$4\r\npong\r\n$0\r\n\r\n"},
+		{[]string{"PING", "hello"}, "*2\r\n$4\r\npong\r\n$5\r\nhello\r\n"},
+	})
+}
+
+func TestEcho(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"ECHO", "hello"}, "$5\r\nhello\r\n"},
+		{[]string{"ECHO", ""}, "$0\r\n\r\n"},
+		// The message is binary safe.
+		{[]string{"ECHO", "a\r\nb\x00"}, "$5\r\na\r\nb\x00\r\n"},
+		{[]string{"ECHO"}, "-ERR wrong number of arguments for 'echo' command\r\n"},
+		{[]string{"ECHO", "a", "b"}, "-ERR wrong number of arguments for 'echo' command\r\n"},
+	})
+}
+
+func TestPipelining(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	conn := redistest.Dial(t, addr)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The replies to a pipeline come back in order.
+	io.WriteString(conn, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n*2\r\n$3\r\nGET\r\n$1\r\na\r\n*2\r\n$3\r\nGET\r\n$1\r\nb\r\n")
+	want := "+OK\r\n$1\r\n1\r\n$-1\r\n"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("cannot read replies: %v (read so far: %q)", err, got)
+	}
+	if string(got) != want {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+
+	// A long pipeline sent in a single write gets every reply.
+	var pipeline strings.Builder
+	for i := range 1000 {
+		key := strconv.Itoa(i)
+		fmt.Fprintf(&pipeline, "*3\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$1\r\nx\r\n", len(key), key)
+	}
+	pipeline.WriteString("*1\r\n$6\r\nDBSIZE\r\n")
+	go io.WriteString(conn, pipeline.String())
+	want = strings.Repeat("+OK\r\n", 1000) + ":1001\r\n"
+	got = make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("cannot read replies: %v (read so far: %q)", err, got)
+	}
+	if string(got) != want {
+		t.Errorf("replies to 1000 SETs and DBSIZE = %q", got)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithIdleTimeout(200*time.Millisecond))
+	idle := redistest.Dial(t, addr)
+	active := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+	run(t, subscriber, []step{{[]string{"SUBSCRIBE", "channel"}, "*3\r\n$9\r\nsubscribe\r\n$7\r\nchannel\r\n:1\r\n"}})
+
+	// Each command restarts the timeout.
+	for range 4 {
+		time.Sleep(100 * time.Millisecond)
+		run(t, active, []step{{[]string{"PING"}, "+PONG\r\n"}})
+	}
+
+	// The idle connection was closed without a reply.
+	idle.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := idle.Read(make([]byte, 64)); err != io.EOF {
+		t.Errorf("idle connection read %d bytes, %v, want EOF", n, err)
+	}
+	// Subscribers wait for messages, so they are never idle.
+	run(t, active, []step{{[]string{"PUBLISH", "channel", "hello"}, ":1\r\n"}})
+	if got, want := subscriber.Receive(), "*3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithMaxClients(2))
+	first := redistest.Connect(t, addr)
+	second := redistest.Connect(t, addr)
+	run(t, first, []step{{[]string{"PING"}, "+PONG\r\n"}})
+	run(t, second, []step{{[]string{"PING"}, "+PONG\r\n"}})
+
+	// The third connection is turned away.
+	third := redistest.Dial(t, addr)
+	third.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(third)
+	if err != nil {
+		t.Fatalf("cannot read from the rejected connection: %v", err)
+	}
+	if want := "-ERR max number of clients reached\r\n"; string(got) != want {
+		t.Errorf("rejected connection got %q, want %q", got, want)
+	}
+	if info := first.Do("INFO", "stats"); !strings.Contains(info, "rejected_connections:1\r\n") {
+		t.Errorf("INFO stats lacks rejected_connections:1:\n%s", info)
+	}
+
+	// A slot frees up once a client leaves.
+	run(t, second, []step{{[]string{"QUIT"}, "+OK\r\n"}})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c := redistest.Connect(t, addr)
+		c.Send("PING")
+		c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+		reply := make([]byte, 64)
+		n, _ := c.Conn().Read(reply)
+		if string(reply[:n]) == "+PONG\r\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("new connection got %q after a client left", reply[:n])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithPassword("secret"))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"GET", "key"}, "-NOAUTH Authentication required.\r\n"},
+		{[]string{"PING"}, "+PONG\r\n"},
+		{[]string{"AUTH", "wrong"}, "-ERR invalid password\r\n"},
+		{[]string{"SET", "key", "value"}, "-NOAUTH Authentication required.\r\n"},
+		{[]string{"AUTH", "secret"}, "+OK\r\n"},
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$5\r\nvalue\r\n"},
+		// A failed AUTH logs the connection out again.
+		{[]string{"AUTH", "wrong"}, "-ERR invalid password\r\n"},
+		{[]string{"GET", "key"}, "-NOAUTH Authentication required.\r\n"},
+		{[]string{"AUTH"}, "-ERR wrong number of arguments for 'auth' command\r\n"},
+	})
+
+	// Each connection authenticates on its own.
+	other := redistest.Connect(t, addr)
+	run(t, other, []step{{[]string{"GET", "key"}, "-NOAUTH Authentication required.\r\n"}})
+
+	// Without a password, AUTH is an error and every command runs.
+	addr, _ = redistest.StartServer(t)
+	c = redistest.Connect(t, addr)
+	run(t, c, []step{
+		{[]string{"GET", "key"}, "$-1\r\n"},
+		{[]string{"AUTH", "secret"}, "-ERR Client sent AUTH, but no password is set\r\n"},
+	})
+}
+
+func TestQuit(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// QUIT is answered and the connection closed, even with commands
+	// pipelined after it.
+	c.Send("QUIT")
+	c.Send("PING")
+	c.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(c.Conn())
+	if err != nil {
+		t.Fatalf("cannot read the reply to QUIT: %v", err)
+	}
+	if string(got) != "+OK\r\n" {
+		t.Errorf("QUIT got %q, want +OK and the connection closed", got)
+	}
+
+	// The server forgot the client.
+	other := redistest.Connect(t, addr)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info := other.Do("INFO", "clients")
+		if strings.Contains(info, "connected_clients:1\r\n") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("INFO clients still counts the client that quit:\n%s", info)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInlineCommands(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{"plain", "PING\r\n", "+PONG\r\n"},
+		{"newline only", "PING\n", "+PONG\r\n"},
+		{"arguments", "SET key value\r\nGET key\r\n", "+OK\r\n$5\r\nvalue\r\n"},
+		{"extra spaces", "  ECHO \t hello  \r\n", "$5\r\nhello\r\n"},
+		{"empty lines", "\r\n\r\nPING\r\n", "+PONG\r\n"},
+		{"double quotes", "ECHO \"hello world\"\r\n", "$11\r\nhello world\r\n"},
+		{"single quotes", "ECHO 'it\\'s'\r\n", "$4\r\nit's\r\n"},
+		{"escapes", "ECHO \"\\x41\\n\"\r\n", "$2\r\nA\n\r\n"},
+		{"mixed with arrays", "PING\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\nPING\r\n", "+PONG\r\n$2\r\nhi\r\n+PONG\r\n"},
+		{"unbalanced quotes", "ECHO \"hello\r\n", "-ERR Protocol error: unbalanced quotes in request\r\n"},
+		{"text after quotes", "ECHO \"a\"b\r\n", "-ERR Protocol error: unbalanced quotes in request\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := redistest.Dial(t, addr)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			io.WriteString(conn, test.request)
+			got := make([]byte, len(test.want))
+			if _, err := io.ReadFull(conn, got); err != nil {
+				t.Fatalf("cannot read the reply: %v (read so far: %q)", err, got)
+			}
+			if string(got) != test.want {
+				t.Errorf("reply to %q = %q, want %q", test.request, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package goredis
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// entryOverhead approximates the memory a key costs on top of the bytes of
+// the key and value: the map slot, the entry and the string headers.
+// elementOverhead is the same for each element of a list or other
+// collection.
+const (
+	entryOverhead   = 64
+	elementOverhead = 16
+)
+
+const wrongTypeError = "WRONGTYPE Operation against a key holding the wrong kind of value"
+
+// entry is a value in the keyspace. value is a string, a *list, a hash, a
+// set or a *sortedSet. Strings are replaced rather than modified, while
+// collections are modified in place by their commands, so a copy of the
+// keyspace must copy them with copyValue.
+type entry struct {
+	value any
+	// accessed is when the key was last read or written, in Unix
+	// nanoseconds, for allkeys-lru eviction. Readers update it while holding
+	// only the read lock, so it is atomic.
+	accessed atomic.Int64
+}
+
+// entrySize estimates the memory used by key and value.
+func entrySize(key string, value any) int64 {
+	return int64(len(key)) + valueSize(value) + entryOverhead
+}
+
+// valueSize estimates the memory used by a stored value.
+func valueSize(value any) int64 {
+	switch value := value.(type) {
+	case string:
+		return int64(len(value))
+	case *list:
+		size := int64(0)
+		for _, element := range value.elements {
+			size += elementSize(element)
+		}
+		return size
+	case hash:
+		size := int64(0)
+		for field, fieldValue := range value {
+			size += fieldSize(field, fieldValue)
+		}
+		return size
+	case set:
+		size := int64(0)
+		for member := range value {
+			size += elementSize(member)
+		}
+		return size
+	case *sortedSet:
+		size := int64(0)
+		for member := range value.scores {
+			size += zsetMemberSize(member)
+		}
+		return size
+	default:
+		panic(fmt.Sprintf("unknown value type %T", value))
+	}
+}
+
+// elementSize estimates the memory used by an element of a collection.
+// Commands that modify a collection in place add it to or subtract it from
+// usedMemory.
+func elementSize(element string) int64 {
+	return int64(len(element)) + elementOverhead
+}
+
+// copyValue returns a copy of value that shares nothing it could modify.
+func copyValue(value any) any {
+	switch value := value.(type) {
+	case string:
+		return value
+	case *list:
+		return &list{elements: slices.Clone(value.elements)}
+	case hash:
+		return maps.Clone(value)
+	case set:
+		return maps.Clone(value)
+	case *sortedSet:
+		return &sortedSet{scores: maps.Clone(value.scores), sorted: slices.Clone(value.sorted)}
+	default:
+		panic(fmt.Sprintf("unknown value type %T", value))
+	}
+}
+
+// lookupKey returns the value stored at key, treating a key whose expiration
+// time has passed as missing. Callers must hold dbLock.
+func (s *server) lookupKey(key string) (any, bool) {
+	now := time.Now()
+	if s.isExpired(key, now) {
+		return nil, false
+	}
+	e, ok := s.database[key]
+	if !ok {
+		return nil, false
+	}
+	e.accessed.Store(now.UnixNano())
+	return e.value, true
+}
+
+// lookupTyped is lookupKey for commands that work on values of type T.
+// wrongType reports that key exists but holds another type. Callers must
+// hold dbLock.
+func lookupTyped[T any](s *server, key string) (value T, ok bool, wrongType bool) {
+	stored, ok := s.lookupKey(key)
+	if !ok {
+		return value, false, false
+	}
+	value, ok = stored.(T)
+	return value, ok, !ok
+}
+
+// storeValue sets the value of key, leaving its expiration time alone.
+// Callers must hold dbLock for writing.
+func (s *server) storeValue(key string, value any) {
+	if old, ok := s.database[key]; ok {
+		s.usedMemory -= entrySize(key, old.value)
+	} else {
+		s.notifyKeyspaceEvent(notifyNew, "new", key)
+	}
+	e := &entry{value: value}
+	e.accessed.Store(time.Now().UnixNano())
+	s.database[key] = e
+	s.usedMemory += entrySize(key, value)
+	s.touchKey(key)
+}
+
+// deleteKey removes key and its expiration time. It reports whether the key
+// existed. Callers must hold dbLock for writing.
+func (s *server) deleteKey(key string) bool {
+	e, ok := s.database[key]
+	if ok {
+		s.usedMemory -= entrySize(key, e.value)
+		s.touchKey(key)
+	}
+	delete(s.database, key)
+	delete(s.expirations, key)
+	return ok
+}
+
+// flushKeys deletes every key. Callers must hold dbLock for writing.
+func (s *server) flushKeys() {
+	clear(s.database)
+	clear(s.expirations)
+	s.usedMemory = 0
+	s.touchAllKeys()
+}
+
+// typeName returns the type TYPE reports for a stored value.
+func typeName(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case *list:
+		return "list"
+	case hash:
+		return "hash"
+	case set:
+		return "set"
+	case *sortedSet:
+		return "zset"
+	default:
+		panic(fmt.Sprintf("unknown value type %T", value))
+	}
+}
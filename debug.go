@@ -0,0 +1,105 @@
+package goredis
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugStacksLimit bounds the stack dump of DEBUG GOROUTINES STACKS.
+const debugStacksLimit = 64 * 1024
+
+// handleDebugCommand serves DEBUG SLEEP seconds, which stalls the connection
+// to simulate a slow command, DEBUG SET-ACTIVE-EXPIRE 0|1, which pauses or
+// resumes the background reaper so tests can observe lazy expiration alone,
+// DEBUG OBJECT key, which describes how a value is stored, DEBUG JMAP,
+// which logs the memory use of the process, and DEBUG GOROUTINES [STACKS],
+// which reports the goroutines of the process to diagnose leaks. DEBUG is
+// refused unless enabled with WithDebugCommand.
+func (s *server) handleDebugCommand(c *client, command []string) error {
+	if !s.debugEnabled {
+		return c.writer.WriteError("ERR DEBUG command not allowed. If the enable-debug-command option is set to \"local\", you can run it from a local connection, otherwise you need to set this option in the configuration file, and then restart the server.")
+	}
+	switch subcommand := strings.ToUpper(command[1]); {
+	case subcommand == "OBJECT" && len(command) == 3:
+		return s.debugObject(c, command[2])
+	case subcommand == "JMAP" && len(command) == 2:
+		// Named after the JVM tool that dumps the heap, which is as close as
+		// a Go process gets.
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		s.logger.Info("memory use",
+			slog.Uint64("heapAlloc", stats.HeapAlloc),
+			slog.Uint64("heapObjects", stats.HeapObjects),
+			slog.Uint64("sys", stats.Sys),
+			slog.Uint64("numGC", uint64(stats.NumGC)))
+		return c.writer.WriteSimpleString("OK")
+	case subcommand == "GOROUTINES" && len(command) == 2:
+		return c.writer.WriteBulkString(fmt.Sprintf("goroutines:%d\n", runtime.NumGoroutine()))
+	case subcommand == "GOROUTINES" && len(command) == 3 && strings.EqualFold(command[2], "stacks"):
+		// The count is taken first, so it may differ from the goroutines in
+		// the dump, which is cut after debugStacksLimit bytes.
+		count := runtime.NumGoroutine()
+		stacks := make([]byte, debugStacksLimit)
+		stacks = stacks[:runtime.Stack(stacks, true)]
+		return c.writer.WriteBulkString(fmt.Sprintf("goroutines:%d\n\n%s", count, stacks))
+	case subcommand == "SLEEP" && len(command) == 3:
+		seconds, err := strconv.ParseFloat(command[2], 64)
+		if err != nil || seconds < 0 {
+			return c.writer.WriteError("ERR value is not a valid float")
+		}
+		// Stop does not wait for the sleep to finish.
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+		}
+		return c.writer.WriteSimpleString("OK")
+	case subcommand == "SET-ACTIVE-EXPIRE" && len(command) == 3:
+		switch command[2] {
+		case "0":
+			s.activeExpireDisabled.Store(true)
+		case "1":
+			s.activeExpireDisabled.Store(false)
+		default:
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+		return c.writer.WriteSimpleString("OK")
+	default:
+		return c.writer.WriteError("ERR DEBUG subcommand not supported")
+	}
+}
+
+// isDebugSleep reports whether command is DEBUG SLEEP, which dispatch runs
+// without holding execLock.
+func isDebugSleep(command []string) bool {
+	return len(command) == 3 && strings.EqualFold(command[1], "sleep")
+}
+
+// debugObject replies to DEBUG OBJECT key in the format of Redis. Unlike
+// other reads it leaves the access time alone, since the reply includes how
+// long the key has been idle.
+func (s *server) debugObject(c *client, key string) error {
+	now := time.Now()
+	config := s.getConfig()
+	s.dbLock.RLock()
+	e, ok := s.database[key]
+	ok = ok && !s.isExpired(key, now)
+	description := ""
+	if ok {
+		idle := now.Sub(time.Unix(0, e.accessed.Load()))
+		description = fmt.Sprintf("Value at:%p refcount:1 encoding:%s lru_seconds_idle:%d",
+			e, encodingName(e.value, config), int64(idle.Seconds()))
+	}
+	s.dbLock.RUnlock()
+
+	if !ok {
+		s.expireKey(key)
+		return c.writer.WriteError("ERR no such key")
+	}
+	return c.writer.WriteSimpleString(description)
+}
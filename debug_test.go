@@ -0,0 +1,186 @@
+package goredis_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestDebugSetActiveExpire(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+
+	// Expirations are observed through keyspace notifications, which are
+	// sent both when the reaper evicts a key and when a read deletes it.
+	run(t, c, []step{
+		{[]string{"CONFIG", "SET", "notify-keyspace-events", "Ex$"}, "+OK\r\n"},
+		{[]string{"DEBUG", "SET-ACTIVE-EXPIRE", "0"}, "+OK\r\n"},
+	})
+	run(t, subscriber, []step{
+		{[]string{"SUBSCRIBE", "__keyevent@0__:expired", "__keyevent@0__:set"}, "*3\r\n$9\r\nsubscribe\r\n$22\r\n__keyevent@0__:expired\r\n:1\r\n"},
+	})
+	if got, want := subscriber.Receive(), "*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@0__:set\r\n:2\r\n"; got != want {
+		t.Fatalf("subscribe reply = %q, want %q", got, want)
+	}
+
+	run(t, c, []step{{[]string{"SET", "key", "value", "PX", "10"}, "+OK\r\n"}})
+	setMessage := func(key string) string {
+		return "*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:set\r\n$3\r\n" + key + "\r\n"
+	}
+	expiredMessage := func(key string) string {
+		return "*3\r\n$7\r\nmessage\r\n$22\r\n__keyevent@0__:expired\r\n$3\r\n" + key + "\r\n"
+	}
+	if got := subscriber.Receive(); got != setMessage("key") {
+		t.Fatalf("message = %q, want %q", got, setMessage("key"))
+	}
+
+	// The reaper runs every 100ms. Had it evicted the key, the expired event
+	// would arrive before the one for the next SET.
+	time.Sleep(500 * time.Millisecond)
+	run(t, c, []step{{[]string{"SET", "now", "value"}, "+OK\r\n"}})
+	if got := subscriber.Receive(); got != setMessage("now") {
+		t.Fatalf("message = %q, want %q", got, setMessage("now"))
+	}
+
+	// The key is still deleted lazily when it is read.
+	run(t, c, []step{{[]string{"GET", "key"}, "$-1\r\n"}})
+	if got := subscriber.Receive(); got != expiredMessage("key") {
+		t.Fatalf("message = %q, want %q", got, expiredMessage("key"))
+	}
+
+	// Turning the reaper back on evicts keys that are never read.
+	run(t, c, []step{
+		{[]string{"DEBUG", "SET-ACTIVE-EXPIRE", "1"}, "+OK\r\n"},
+		{[]string{"SET", "old", "value", "PX", "10"}, "+OK\r\n"},
+	})
+	if got := subscriber.Receive(); got != setMessage("old") {
+		t.Fatalf("message = %q, want %q", got, setMessage("old"))
+	}
+	if got := subscriber.Receive(); got != expiredMessage("old") {
+		t.Fatalf("message = %q, want %q", got, expiredMessage("old"))
+	}
+}
+
+func TestDebugSleep(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	sleeper := redistest.Connect(t, addr)
+	c := redistest.Connect(t, addr)
+
+	start := time.Now()
+	sleeper.Send("DEBUG", "SLEEP", "0.5")
+	// Give the server time to start sleeping.
+	time.Sleep(50 * time.Millisecond)
+
+	// Other clients, including transactions, keep running meanwhile.
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"SET", "key", "value"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n+OK\r\n"},
+	})
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("EXEC waited for DEBUG SLEEP: %v", elapsed)
+	}
+
+	if got := sleeper.Receive(); got != "+OK\r\n" {
+		t.Errorf("DEBUG SLEEP = %q, want +OK", got)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("DEBUG SLEEP returned after %v", elapsed)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"DEBUG", "JMAP"}, "+OK\r\n"},
+		{[]string{"DEBUG", "NOSUCHSUBCOMMAND"}, "-ERR DEBUG subcommand not supported\r\n"},
+	})
+}
+
+func TestDebugObject(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "abc"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "a"}, ":1\r\n"},
+		{[]string{"DEBUG", "OBJECT", "missing"}, "-ERR no such key\r\n"},
+	})
+	for key, want := range map[string]string{
+		"key":  " refcount:1 encoding:embstr lru_seconds_idle:0\r\n",
+		"list": " refcount:1 encoding:listpack lru_seconds_idle:0\r\n",
+	} {
+		reply := c.Do("DEBUG", "OBJECT", key)
+		if !strings.HasPrefix(reply, "+Value at:0x") || !strings.HasSuffix(reply, want) {
+			t.Errorf("DEBUG OBJECT %s = %q, want it to end in %q", key, reply, want)
+		}
+	}
+}
+
+func TestDebugDisabled(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"DEBUG", "JMAP"}, "-ERR DEBUG command not allowed. If the enable-debug-command option is set to \"local\", you can run it from a local connection, otherwise you need to set this option in the configuration file, and then restart the server.\r\n"},
+		{[]string{"CONFIG", "GET", "enable-debug-command"}, "*2\r\n$20\r\nenable-debug-command\r\n$2\r\nno\r\n"},
+	})
+}
+
+// goroutines returns the goroutine count DEBUG GOROUTINES reports.
+func goroutines(t *testing.T, c *redistest.Client) int {
+	t.Helper()
+	reply := c.Do("DEBUG", "GOROUTINES")
+	_, count, _ := strings.Cut(reply, "goroutines:")
+	n, err := strconv.Atoi(strings.TrimSuffix(count, "\n\r\n"))
+	if err != nil {
+		t.Fatalf("DEBUG GOROUTINES = %q", reply)
+	}
+	return n
+}
+
+func TestDebugGoroutines(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+	baseline := goroutines(t, c)
+
+	// Every connection has a goroutine, including those blocked in BLPOP.
+	var clients []*redistest.Client
+	for i := range 10 {
+		client := redistest.Connect(t, addr)
+		if i%2 == 0 {
+			client.Send("BLPOP", "list", "0")
+		} else {
+			run(t, client, []step{{[]string{"PING"}, "+PONG\r\n"}})
+		}
+		clients = append(clients, client)
+	}
+	time.Sleep(blockDelay)
+	if n := goroutines(t, c); n < baseline+10 {
+		t.Errorf("%d goroutines with 10 more clients, want at least %d", n, baseline+10)
+	}
+
+	// Once the clients are gone, so are their goroutines.
+	for _, client := range clients {
+		client.Conn().Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for goroutines(t, c) > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after the clients left, want %d:\n%s", goroutines(t, c), baseline, c.Do("DEBUG", "GOROUTINES", "STACKS"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stacks := c.Do("DEBUG", "GOROUTINES", "STACKS")
+	if !strings.Contains(stacks, "\n\ngoroutine ") || !strings.Contains(stacks, "handleConn") {
+		t.Errorf("DEBUG GOROUTINES STACKS = %q", stacks)
+	}
+}
@@ -0,0 +1,71 @@
+package goredis
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// EvictionPolicy selects what happens when a write would exceed the memory
+// limit.
+type EvictionPolicy string
+
+const (
+	// NoEviction rejects commands that may add data with an OOM error.
+	NoEviction EvictionPolicy = "noeviction"
+	// AllKeysLRU deletes the least recently used keys to make room.
+	AllKeysLRU EvictionPolicy = "allkeys-lru"
+)
+
+// evictionSamples is how many keys are compared to pick each key to evict.
+// Like Redis, the LRU is approximated by sampling instead of keeping every
+// key in access order.
+const evictionSamples = 5
+
+// denyOOM reports whether upperName may add data, and so is refused when the
+// memory limit is reached.
+func denyOOM(upperName string) bool {
+	info, ok := commandTable[strings.ToLower(upperName)]
+	return ok && slices.Contains(info.flags, "denyoom")
+}
+
+// freeMemory evicts keys until the keyspace fits in maxmemory, as the policy
+// allows. It reports whether there is room for more data.
+func (s *server) freeMemory() bool {
+	config := s.getConfig()
+	if config.maxMemory == 0 {
+		return true
+	}
+
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+	for s.usedMemory > config.maxMemory {
+		if config.maxMemoryPolicy != AllKeysLRU || len(s.database) == 0 {
+			return false
+		}
+		s.evictKey()
+	}
+	return true
+}
+
+// evictKey deletes the least recently used of a few keys sampled at random.
+// Callers must hold dbLock for writing.
+func (s *server) evictKey() {
+	victim := ""
+	oldest := int64(math.MaxInt64)
+	sampled := 0
+	for key, e := range s.database {
+		if accessed := e.accessed.Load(); accessed < oldest {
+			victim = key
+			oldest = accessed
+		}
+		sampled += 1
+		if sampled == evictionSamples {
+			break
+		}
+	}
+	s.deleteKey(victim)
+	s.propagate("DEL", victim)
+	s.notifyKeyspaceEvent(notifyEvicted, "evicted", victim)
+	s.evictedKeys.Add(1)
+}
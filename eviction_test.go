@@ -0,0 +1,105 @@
+package goredis_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// infoField returns the value of a numeric field of an INFO section.
+func infoField(t *testing.T, c *redistest.Client, section string, field string) int64 {
+	t.Helper()
+	reply := c.Do("INFO", section)
+	_, value, _ := strings.Cut(reply, "\r\n"+field+":")
+	value, _, _ = strings.Cut(value, "\r\n")
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		t.Fatalf("INFO %s has no %s: %q", section, field, reply)
+	}
+	return n
+}
+
+func TestNoEviction(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithMaxMemory(1000))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "a", strings.Repeat("x", 500)}, "+OK\r\n"},
+		{[]string{"SET", "b", strings.Repeat("x", 500)}, "+OK\r\n"},
+		// Commands that may add data are refused once memory is full.
+		{[]string{"SET", "c", "x"}, "-OOM command not allowed when used memory > 'maxmemory'.\r\n"},
+		{[]string{"RPUSH", "list", "x"}, "-OOM command not allowed when used memory > 'maxmemory'.\r\n"},
+		{[]string{"EXISTS", "c", "list"}, ":0\r\n"},
+		// Reads and deletes still work, and make room.
+		{[]string{"STRLEN", "a"}, ":500\r\n"},
+		{[]string{"DEL", "b"}, ":1\r\n"},
+		{[]string{"SET", "c", "x"}, "+OK\r\n"},
+	})
+	if n := infoField(t, c, "stats", "evicted_keys"); n != 0 {
+		t.Errorf("evicted_keys = %d under noeviction", n)
+	}
+	if !strings.Contains(c.Do("INFO", "memory"), "maxmemory_policy:noeviction\r\n") {
+		t.Error("INFO memory lacks maxmemory_policy:noeviction")
+	}
+}
+
+func TestAllKeysLRU(t *testing.T) {
+	// Each key costs about 165 bytes, so three fit.
+	addr, _ := redistest.StartServer(t, goredis.WithMaxMemory(600), goredis.WithMaxMemoryPolicy(goredis.AllKeysLRU))
+	c := redistest.Connect(t, addr)
+	value := strings.Repeat("x", 100)
+
+	run(t, c, []step{
+		{[]string{"SET", "a", value}, "+OK\r\n"},
+		{[]string{"SET", "b", value}, "+OK\r\n"},
+		{[]string{"SET", "c", value}, "+OK\r\n"},
+		{[]string{"SET", "d", value}, "+OK\r\n"},
+		// The next write evicts first. With so few keys every one is
+		// sampled, so the least recently used is the one evicted, and
+		// reading a keeps it.
+		{[]string{"GET", "a"}, "$100\r\n" + value + "\r\n"},
+		{[]string{"SET", "e", value}, "+OK\r\n"},
+		{[]string{"DBSIZE"}, ":4\r\n"},
+		{[]string{"EXISTS", "b"}, ":0\r\n"},
+		{[]string{"SET", "f", value}, "+OK\r\n"},
+		{[]string{"EXISTS", "c"}, ":0\r\n"},
+		{[]string{"EXISTS", "a"}, ":1\r\n"},
+	})
+	if n := infoField(t, c, "stats", "evicted_keys"); n != 2 {
+		t.Errorf("evicted_keys = %d, want 2", n)
+	}
+
+	for i := range 100 {
+		run(t, c, []step{{[]string{"SET", "key:" + strconv.Itoa(i), value}, "+OK\r\n"}})
+	}
+	// Memory goes over the limit by at most the last write.
+	if used := infoField(t, c, "memory", "used_memory"); used > 600+200 {
+		t.Errorf("used_memory = %d with maxmemory 600", used)
+	}
+	run(t, c, []step{{[]string{"FLUSHALL"}, "+OK\r\n"}})
+	if used := infoField(t, c, "memory", "used_memory"); used != 0 {
+		t.Errorf("used_memory = %d after FLUSHALL", used)
+	}
+}
+
+func TestMaxMemoryConfig(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "a", strings.Repeat("x", 500)}, "+OK\r\n"},
+		{[]string{"CONFIG", "SET", "maxmemory-policy", "bogus"}, "-ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy') - argument(s) must be one of the following: noeviction, allkeys-lru\r\n"},
+		{[]string{"CONFIG", "SET", "maxmemory", "100"}, "+OK\r\n"},
+		{[]string{"SET", "b", "x"}, "-OOM command not allowed when used memory > 'maxmemory'.\r\n"},
+		// Switching the policy takes effect on the next write.
+		{[]string{"CONFIG", "SET", "maxmemory-policy", "allkeys-lru"}, "+OK\r\n"},
+		{[]string{"SET", "b", "x"}, "+OK\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"CONFIG", "SET", "maxmemory", "0"}, "+OK\r\n"},
+		{[]string{"SET", "a", strings.Repeat("x", 500)}, "+OK\r\n"},
+		{[]string{"DBSIZE"}, ":2\r\n"},
+	})
+}
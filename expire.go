@@ -0,0 +1,88 @@
+package goredis
+
+import (
+	"time"
+)
+
+const (
+	// expireCycleInterval is how often the background reaper runs.
+	expireCycleInterval = 100 * time.Millisecond
+	// expireCycleSamples is how many keys with a deadline are checked per
+	// round. A round is repeated while more than a quarter of the sampled
+	// keys turn out to be expired, the same heuristic Redis uses.
+	expireCycleSamples = 20
+)
+
+// isExpired reports whether key has an expiration time that is not after now.
+// Callers must hold dbLock.
+func (s *server) isExpired(key string, now time.Time) bool {
+	deadline, ok := s.expirations[key]
+	return ok && !now.Before(deadline)
+}
+
+// deleteIfExpired removes key if its expiration time has passed and reports
+// whether it did. Callers must hold dbLock for writing.
+func (s *server) deleteIfExpired(key string) bool {
+	if !s.isExpired(key, time.Now()) {
+		return false
+	}
+	s.deleteKey(key)
+	s.notifyKeyspaceEvent(notifyExpired, "expired", key)
+	return true
+}
+
+// expireKey lazily deletes key if its expiration time has passed. Readers
+// call it after releasing their read lock when they came across a missing
+// key, since deleting requires the write lock.
+func (s *server) expireKey(key string) {
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	s.dbLock.Unlock()
+}
+
+// expireCycle periodically evicts expired keys that are never accessed again
+// and so would not be removed lazily. It returns when the server stops.
+func (s *server) expireCycle() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(expireCycleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.activeExpireDisabled.Load() {
+				continue
+			}
+			for {
+				if expired := s.expireSample(); expired <= expireCycleSamples/4 {
+					break
+				}
+			}
+		}
+	}
+}
+
+// expireSample checks up to expireCycleSamples keys that have an expiration
+// time, deletes the expired ones and returns how many it deleted. Go's
+// randomised map iteration order makes this a cheap random sample.
+func (s *server) expireSample() int {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+
+	now := time.Now()
+	checked, expired := 0, 0
+	for key, deadline := range s.expirations {
+		if checked == expireCycleSamples {
+			break
+		}
+		checked += 1
+		if !now.Before(deadline) {
+			s.deleteKey(key)
+			s.notifyKeyspaceEvent(notifyExpired, "expired", key)
+			expired += 1
+		}
+	}
+	return expired
+}
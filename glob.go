@@ -0,0 +1,101 @@
+package goredis
+
+// matchGlob reports whether str matches the glob-style pattern using the
+// same rules as Redis's stringmatchlen: '*' matches any sequence, '?' any
+// single byte, '[...]' a byte class with optional '^' negation and 'a-z'
+// ranges, and '\' escapes the next byte. An unterminated class runs to the
+// end of the pattern instead of being an error.
+func matchGlob(pattern string, str string) bool {
+	skipLongerMatches := false
+	return matchGlobFrom(pattern, str, &skipLongerMatches)
+}
+
+// matchGlobFrom does the work for matchGlob. skipLongerMatches is set once a
+// '*' failed to match against every suffix of the string, at which point no
+// enclosing '*' can succeed either; this keeps patterns like "a*a*a*a*b"
+// from taking exponential time.
+func matchGlobFrom(pattern string, str string, skipLongerMatches *bool) bool {
+	p, s := 0, 0
+	for p < len(pattern) && s < len(str) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p += 1
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; s < len(str); s += 1 {
+				if matchGlobFrom(pattern[p+1:], str[s:], skipLongerMatches) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s += 1
+		case '[':
+			p += 1
+			negate := p < len(pattern) && pattern[p] == '^'
+			if negate {
+				p += 1
+			}
+			match := false
+			for {
+				if p == len(pattern) {
+					p -= 1
+					break
+				}
+				if pattern[p] == '\\' && len(pattern)-p >= 2 {
+					p += 1
+					if pattern[p] == str[s] {
+						match = true
+					}
+				} else if pattern[p] == ']' {
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end := pattern[p], pattern[p+2]
+					if start > end {
+						start, end = end, start
+					}
+					p += 2
+					if str[s] >= start && str[s] <= end {
+						match = true
+					}
+				} else if pattern[p] == str[s] {
+					match = true
+				}
+				p += 1
+			}
+			if negate {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s += 1
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p += 1
+			}
+			fallthrough
+		default:
+			if pattern[p] != str[s] {
+				return false
+			}
+			s += 1
+		}
+
+		p += 1
+	}
+	if s == len(str) {
+		// Trailing stars match the empty remainder of the string.
+		for p < len(pattern) && pattern[p] == '*' {
+			p += 1
+		}
+	}
+	return p == len(pattern) && s == len(str)
+}
@@ -0,0 +1,45 @@
+package goredis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, str string
+		want         bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"**", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "user:", true},
+		{"user:*", "user", false},
+		{"*:1", "user:1", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		// Reversed ranges are swapped.
+		{"h[c-a]llo", "hbllo", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{`[\]]`, "]", true},
+		// An unterminated class runs to the end of the pattern.
+		{"[abc", "b", true},
+		{"[abc", "d", false},
+		{"a*a*a*a*a*a*a*a*b", strings.Repeat("a", 100), false},
+	}
+	for _, test := range tests {
+		if got := matchGlob(test.pattern, test.str); got != test.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", test.pattern, test.str, got, test.want)
+		}
+	}
+}
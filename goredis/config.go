@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// directive validates a raw config value and returns it in the canonical form
+// that is stored in the config map.
+type directive func(value string) (string, error)
+
+var directives = map[string]directive{
+	"bind":                    parseString,
+	"port":                    parsePort,
+	"unixsocket":              parseString,
+	"replicaof":               parseReplicaOf,
+	"repl-backlog-size":       parseMemory,
+	"loglevel":                parseLogLevel,
+	"timeout":                 parseNonNegativeInt,
+	"maxclients":              parsePositiveInt,
+	"requirepass":             parseString,
+	"tls-cert-file":           parseString,
+	"tls-key-file":            parseString,
+	"tls-ca-cert-file":        parseString,
+	"tls-auth-clients":        parseTLSAuthClients,
+	"maxmemory":               parseMemory,
+	"maxmemory-policy":        parseMaxMemoryPolicy,
+	"notify-keyspace-events":  parseKeyspaceEvents,
+	"slowlog-log-slower-than": parseInt,
+	"slowlog-max-len":         parseNonNegativeInt,
+	"appendonly":              parseYesNo,
+	"appendfilename":          parseString,
+	"appendfsync":             parseAppendFsync,
+	"databases":               parseDatabases,
+	"shutdown-timeout":        parseNonNegativeInt,
+	"enable-debug-command":    parseYesNo,
+	"dir":                     parseString,
+	"dbfilename":              parseString,
+}
+
+func defaultConfig() map[string]string {
+	return map[string]string{
+		"bind":                    "127.0.0.1",
+		"port":                    "6379",
+		"unixsocket":              "",
+		"replicaof":               "",
+		"repl-backlog-size":       "1048576",
+		"loglevel":                "notice",
+		"timeout":                 "300",
+		"maxclients":              "10000",
+		"requirepass":             "",
+		"tls-cert-file":           "",
+		"tls-key-file":            "",
+		"tls-ca-cert-file":        "",
+		"tls-auth-clients":        "yes",
+		"maxmemory":               "0",
+		"maxmemory-policy":        "noeviction",
+		"notify-keyspace-events":  "",
+		"slowlog-log-slower-than": "10000",
+		"slowlog-max-len":         "128",
+		"appendonly":              "no",
+		"appendfilename":          "appendonly.aof",
+		"appendfsync":             "everysec",
+		"databases":               "1",
+		"shutdown-timeout":        "10",
+		"enable-debug-command":    "no",
+		"dir":                     ".",
+		"dbfilename":              "dump.rdb",
+	}
+}
+
+// setConfig validates value with the directive registered for name and
+// stores the canonical value in config.
+func setConfig(config map[string]string, name string, value string) error {
+	name = strings.ToLower(name)
+	parse, ok := directives[name]
+	if !ok {
+		return fmt.Errorf("unknown directive '%s'", name)
+	}
+	canonical, err := parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", name, err)
+	}
+	config[name] = canonical
+	return nil
+}
+
+// loadConfig applies the command line to config the same way redis-server
+// does: an optional config file path as the first argument, followed by
+// --directive value pairs. Flags take precedence over the config file, which
+// takes precedence over the defaults.
+func loadConfig(config map[string]string, args []string) error {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		if err := loadConfigFile(config, args[0]); err != nil {
+			return err
+		}
+		args = args[1:]
+	}
+
+	for len(args) > 0 {
+		name, ok := strings.CutPrefix(args[0], "--")
+		if !ok || name == "" {
+			return fmt.Errorf("unexpected argument '%s', expected --directive value", args[0])
+		}
+
+		value, hasValue := "", false
+		if name, value, hasValue = strings.Cut(name, "="); hasValue {
+			args = args[1:]
+		} else if len(args) > 1 {
+			value = args[1]
+			args = args[2:]
+		} else {
+			return fmt.Errorf("missing value for '--%s'", name)
+		}
+
+		if err := setConfig(config, name, value); err != nil {
+			return fmt.Errorf("--%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadConfigFile reads a redis.conf style file where every non-empty line is
+// a directive followed by its value. Lines starting with '#' are comments and
+// values may be wrapped in single or double quotes.
+func loadConfigFile(config map[string]string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		args, err := splitConfigLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		// replicaof is the only directive with more than one value, which
+		// is stored as written on the command line, as "host port".
+		if len(args) != 2 && !(len(args) == 3 && strings.EqualFold(args[0], "replicaof")) {
+			return fmt.Errorf("%s:%d: wrong number of arguments for '%s'", path, lineNumber, args[0])
+		}
+		if err := setConfig(config, args[0], strings.Join(args[1:], " ")); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// splitConfigLine splits a config line on whitespace, keeping quoted values
+// together. Double quoted values support the usual backslash escapes.
+func splitConfigLine(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i += 1
+			continue
+		}
+
+		var arg strings.Builder
+		switch line[i] {
+		case '"':
+			i += 1
+			for ; i < len(line) && line[i] != '"'; i += 1 {
+				if line[i] == '\\' && i+1 < len(line) {
+					i += 1
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 't':
+						arg.WriteByte('\t')
+					case 'r':
+						arg.WriteByte('\r')
+					default:
+						arg.WriteByte(line[i])
+					}
+					continue
+				}
+				arg.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("unbalanced quotes")
+			}
+			i += 1
+		case '\'':
+			i += 1
+			for ; i < len(line) && line[i] != '\''; i += 1 {
+				arg.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("unbalanced quotes")
+			}
+			i += 1
+		default:
+			for ; i < len(line) && line[i] != ' ' && line[i] != '\t'; i += 1 {
+				arg.WriteByte(line[i])
+			}
+		}
+		if i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			return nil, fmt.Errorf("closing quote must be followed by a space")
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func parseString(value string) (string, error) {
+	return value, nil
+}
+
+func parsePort(value string) (string, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return "", fmt.Errorf("port must be between 0 and 65535")
+	}
+	return strconv.Itoa(port), nil
+}
+
+func parsePositiveInt(value string) (string, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return "", fmt.Errorf("must be a positive integer")
+	}
+	return strconv.Itoa(n), nil
+}
+
+// parseDatabases only accepts 1, since the server has a single database,
+// rather than let a config file claim more.
+func parseDatabases(value string) (string, error) {
+	if n, err := strconv.Atoi(value); err != nil || n != 1 {
+		return "", fmt.Errorf("only 1 database is supported")
+	}
+	return "1", nil
+}
+
+func parseInt(value string) (string, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("must be an integer")
+	}
+	return strconv.Itoa(n), nil
+}
+
+func parseNonNegativeInt(value string) (string, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("must be a non-negative integer")
+	}
+	return strconv.Itoa(n), nil
+}
+
+// parseReplicaOf accepts "host port", or an empty value for a master.
+func parseReplicaOf(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	host, port, ok := strings.Cut(value, " ")
+	if !ok || host == "" {
+		return "", fmt.Errorf("must be a host and a port")
+	}
+	port, err := parsePort(port)
+	if err != nil {
+		return "", err
+	}
+	return host + " " + port, nil
+}
+
+func parseYesNo(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return "yes", nil
+	case "no":
+		return "no", nil
+	}
+	return "", fmt.Errorf("must be 'yes' or 'no'")
+}
+
+func parseTLSAuthClients(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "yes", "no", "optional":
+		return strings.ToLower(value), nil
+	}
+	return "", fmt.Errorf("must be one of yes, no, optional")
+}
+
+func parseAppendFsync(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "always", "everysec", "no":
+		return strings.ToLower(value), nil
+	}
+	return "", fmt.Errorf("must be one of always, everysec, no")
+}
+
+func parseMaxMemoryPolicy(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "noeviction", "allkeys-lru":
+		return strings.ToLower(value), nil
+	}
+	return "", fmt.Errorf("must be one of noeviction, allkeys-lru")
+}
+
+func parseKeyspaceEvents(value string) (string, error) {
+	if strings.Trim(value, "Ag$lshzxeKEn") != "" {
+		return "", fmt.Errorf("must be made of the classes Ag$lshzxeKEn")
+	}
+	return value, nil
+}
+
+var logLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"verbose": slog.LevelDebug,
+	"notice":  slog.LevelInfo,
+	"warning": slog.LevelWarn,
+}
+
+func parseLogLevel(value string) (string, error) {
+	value = strings.ToLower(value)
+	if _, ok := logLevels[value]; !ok {
+		return "", fmt.Errorf("must be one of debug, verbose, notice, warning")
+	}
+	return value, nil
+}
+
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"kb", 1024},
+	{"mb", 1024 * 1024},
+	{"gb", 1024 * 1024 * 1024},
+	{"k", 1000},
+	{"m", 1000 * 1000},
+	{"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory accepts a byte count with an optional unit suffix such as
+// 100mb or 1gb and returns the number of bytes.
+func parseMemory(value string) (string, error) {
+	number := strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSuffix(number, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/multiplier {
+		return "", fmt.Errorf("must be a memory amount like 1024, 100mb or 1gb")
+	}
+	return strconv.FormatInt(n*multiplier, 10), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitConfigLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{line: "port 6379", want: []string{"port", "6379"}},
+		{line: "port\t 6379  ", want: []string{"port", "6379"}},
+		{line: `requirepass "with space"`, want: []string{"requirepass", "with space"}},
+		{line: `requirepass "a\"b\\c\n"`, want: []string{"requirepass", "a\"b\\c\n"}},
+		{line: `dir '/tmp/a b\n'`, want: []string{"dir", `/tmp/a b\n`}},
+		{line: `requirepass ""`, want: []string{"requirepass", ""}},
+		{line: "replicaof host 6380", want: []string{"replicaof", "host", "6380"}},
+		{line: `requirepass "open`, err: "unbalanced quotes"},
+		{line: `requirepass 'open`, err: "unbalanced quotes"},
+		{line: `requirepass "a"b`, err: "closing quote must be followed by a space"},
+	}
+	for _, test := range tests {
+		got, err := splitConfigLine(test.line)
+		switch {
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("splitConfigLine(%q) error = %v, want %q", test.line, err, test.err)
+		case test.err == "" && err != nil:
+			t.Errorf("splitConfigLine(%q) error = %v", test.line, err)
+		case test.err == "" && !slices.Equal(got, test.want):
+			t.Errorf("splitConfigLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to a config file passed as the first argument
+		// when it is not empty.
+		file string
+		args []string
+		want map[string]string
+		err  string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"port": "6379", "maxmemory": "0", "appendonly": "no", "databases": "1"},
+		},
+		{
+			name: "flags",
+			args: []string{"--port", "7000", "--maxmemory=100mb", "--APPENDONLY", "YES"},
+			want: map[string]string{"port": "7000", "maxmemory": "104857600", "appendonly": "yes"},
+		},
+		{
+			name: "file",
+			file: "# comment\n\nport 7000\n  maxmemory 1gb\nrequirepass \"a b\"\nreplicaof 10.0.0.1 6380\n",
+			want: map[string]string{"port": "7000", "maxmemory": "1073741824", "requirepass": "a b", "replicaof": "10.0.0.1 6380"},
+		},
+		{
+			name: "flags override file",
+			file: "port 7000\nmaxmemory 1k\n",
+			args: []string{"--port", "7001"},
+			want: map[string]string{"port": "7001", "maxmemory": "1000"},
+		},
+		{
+			name: "unknown directive",
+			args: []string{"--no-such-directive", "1"},
+			err:  "--no-such-directive: unknown directive 'no-such-directive'",
+		},
+		{
+			name: "missing value",
+			args: []string{"--port"},
+			err:  "missing value for '--port'",
+		},
+		{
+			name: "no dashes",
+			args: []string{"--port", "7000", "maxmemory", "1"},
+			err:  "unexpected argument 'maxmemory', expected --directive value",
+		},
+		{
+			name: "invalid value",
+			args: []string{"--port", "65536"},
+			err:  "--port: invalid value for 'port': port must be between 0 and 65535",
+		},
+		{
+			name: "memory overflow",
+			args: []string{"--maxmemory", "9000000000gb"},
+			err:  "--maxmemory: invalid value for 'maxmemory': must be a memory amount like 1024, 100mb or 1gb",
+		},
+		{
+			name: "largest memory",
+			args: []string{"--maxmemory", "9223372036854775807b"},
+			want: map[string]string{"maxmemory": "9223372036854775807"},
+		},
+		{
+			name: "single database",
+			file: "databases 1\n",
+			want: map[string]string{"databases": "1"},
+		},
+		{
+			name: "more databases",
+			file: "databases 16\n",
+			err:  ":1: invalid value for 'databases': only 1 database is supported",
+		},
+		{
+			name: "wrong number of arguments in file",
+			file: "port 7000\nport 7000 7001\n",
+			err:  ":2: wrong number of arguments for 'port'",
+		},
+		{
+			name: "unbalanced quotes in file",
+			file: "requirepass \"open\n",
+			err:  ":1: unbalanced quotes",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				path := filepath.Join(t.TempDir(), "redis.conf")
+				if err := os.WriteFile(path, []byte(test.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{path}, args...)
+			}
+
+			config := defaultConfig()
+			err := loadConfig(config, args)
+			if test.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+					t.Fatalf("loadConfig(%q) error = %v, want one ending in %q", args, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig(%q) error = %v", args, err)
+			}
+			for name, want := range test.want {
+				if got := config[name]; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
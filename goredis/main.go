@@ -1 +1,92 @@
 package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+)
+
+func main() {
+	config := defaultConfig()
+	if err := loadConfig(config, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: logLevels[config["loglevel"]],
+	}))
+
+	address := net.JoinHostPort(config["bind"], config["port"])
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Error("cannot listen", slog.String("address", address), slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	timeout, _ := strconv.Atoi(config["timeout"])
+	maxClients, _ := strconv.Atoi(config["maxclients"])
+	maxMemory, _ := strconv.ParseInt(config["maxmemory"], 10, 64)
+	slowlogLogSlowerThan, _ := strconv.Atoi(config["slowlog-log-slower-than"])
+	slowlogMaxLen, _ := strconv.Atoi(config["slowlog-max-len"])
+	shutdownTimeout, _ := strconv.Atoi(config["shutdown-timeout"])
+	replBacklogSize, _ := strconv.Atoi(config["repl-backlog-size"])
+	options := []goredis.Option{
+		goredis.WithIdleTimeout(time.Duration(timeout) * time.Second),
+		goredis.WithMaxClients(maxClients),
+		goredis.WithMaxMemory(maxMemory),
+		goredis.WithMaxMemoryPolicy(goredis.EvictionPolicy(config["maxmemory-policy"])),
+		goredis.WithNotifyKeyspaceEvents(config["notify-keyspace-events"]),
+		goredis.WithSlowlog(time.Duration(slowlogLogSlowerThan)*time.Microsecond, slowlogMaxLen),
+		goredis.WithShutdownTimeout(time.Duration(shutdownTimeout) * time.Second),
+		goredis.WithReplBacklogSize(replBacklogSize),
+		goredis.WithPassword(config["requirepass"]),
+		goredis.WithDebugCommand(config["enable-debug-command"] == "yes"),
+		goredis.WithSnapshotFile(filepath.Join(config["dir"], config["dbfilename"])),
+	}
+	if config["tls-cert-file"] != "" {
+		tlsConfig, err := tlsConfig(config)
+		if err != nil {
+			logger.Error("cannot configure TLS", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		options = append(options, goredis.WithTLS(tlsConfig))
+	}
+	if host, port, ok := strings.Cut(config["replicaof"], " "); ok {
+		options = append(options, goredis.WithReplicaOf(host, port))
+	}
+	if config["unixsocket"] != "" {
+		options = append(options, goredis.WithUnixSocket(config["unixsocket"]))
+	}
+	if config["appendonly"] == "yes" {
+		options = append(options,
+			goredis.WithAppendOnlyFile(filepath.Join(config["dir"], config["appendfilename"])),
+			goredis.WithAppendFsync(goredis.FsyncPolicy(config["appendfsync"])),
+		)
+	}
+	server := goredis.NewServer(listener, logger, options...)
+
+	// Let clients finish their commands on SIGINT or SIGTERM.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		logger.Info("received shutdown signal")
+		server.Stop()
+	}()
+
+	if err := server.Start(); err != nil {
+		logger.Error("server stopped", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	<-server.Done()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsConfig builds the TLS settings from the tls-* directives, the way
+// redis-server interprets them: client certificates are verified against
+// tls-ca-cert-file and tls-auth-clients decides whether they are required.
+func tlsConfig(config map[string]string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(config["tls-cert-file"], config["tls-key-file"])
+	if err != nil {
+		return nil, fmt.Errorf("cannot load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config["tls-auth-clients"] == "no" {
+		return tlsConfig, nil
+	}
+	if config["tls-ca-cert-file"] == "" {
+		return nil, fmt.Errorf("tls-ca-cert-file is needed to authenticate clients")
+	}
+	pem, err := os.ReadFile(config["tls-ca-cert-file"])
+	if err != nil {
+		return nil, fmt.Errorf("cannot load CA certificates: %w", err)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", config["tls-ca-cert-file"])
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if config["tls-auth-clients"] == "optional" {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,298 @@
+package goredis
+
+import (
+	"slices"
+	"strings"
+)
+
+// hash is the value of a hash key, mapping fields to values. Hashes are
+// never empty; the key is deleted along with the last field.
+type hash map[string]string
+
+// fieldSize estimates the memory used by a field of a hash and its value.
+func fieldSize(field string, value string) int64 {
+	return elementSize(field) + int64(len(value))
+}
+
+// sortedFields returns the fields of h in order, so that replies listing
+// them do not depend on map iteration order.
+func (h hash) sortedFields() []string {
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// handleHsetCommand serves HSET key field value [field value ...] and replies
+// with the number of fields that were added rather than updated.
+func (s *server) handleHsetCommand(c *client, command []string) error {
+	key := command[1]
+
+	added := 0
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	if !wrongType {
+		if !ok {
+			h = make(hash)
+			s.storeValue(key, h)
+		}
+		for i := 2; i < len(command); i += 2 {
+			field, value := command[i], command[i+1]
+			if old, exists := h[field]; exists {
+				s.usedMemory -= fieldSize(field, old)
+			} else {
+				added += 1
+			}
+			h[field] = value
+			s.usedMemory += fieldSize(field, value)
+		}
+		s.touchKey(key)
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyHash, "hset", key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(added))
+}
+
+func (s *server) handleHgetCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	value, exists := h[command[2]]
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if !exists {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(value)
+}
+
+// handleHmgetCommand serves HMGET key field [field ...], replying with a null
+// for each field that does not exist.
+func (s *server) handleHmgetCommand(c *client, command []string) error {
+	key := command[1]
+
+	values := make([]any, len(command)-2)
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	for i, field := range command[2:] {
+		if value, exists := h[field]; exists {
+			values[i] = value
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(values)
+}
+
+// handleHdelCommand serves HDEL key field [field ...] and replies with the
+// number of fields that were removed.
+func (s *server) handleHdelCommand(c *client, command []string) error {
+	key := command[1]
+
+	deleted := 0
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	if ok {
+		for _, field := range command[2:] {
+			if value, exists := h[field]; exists {
+				s.usedMemory -= fieldSize(field, value)
+				delete(h, field)
+				deleted += 1
+			}
+		}
+		if deleted > 0 {
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyHash, "hdel", key)
+		}
+		if len(h) == 0 {
+			s.deleteKey(key)
+			s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		} else if deleted > 0 {
+			s.touchKey(key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(deleted))
+}
+
+// handleHgetallCommand serves HGETALL key, which replies with the fields of
+// the hash and their values, sorted by field.
+func (s *server) handleHgetallCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	pairs := make([]any, 0, 2*len(h))
+	for _, field := range h.sortedFields() {
+		pairs = append(pairs, field, h[field])
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteMap(pairs)
+}
+
+// handleHrandfieldCommand serves HRANDFIELD key [count [WITHVALUES]], which
+// replies with random fields, counted like SRANDMEMBER. WITHVALUES follows
+// each field with its value.
+func (s *server) handleHrandfieldCommand(c *client, command []string) error {
+	key := command[1]
+
+	count := 1
+	if len(command) >= 3 {
+		n, message := parseRandomCount(command[2])
+		if message != "" {
+			return c.writer.WriteError(message)
+		}
+		count = n
+	}
+	withValues := false
+	if len(command) == 4 {
+		if !strings.EqualFold(command[3], "withvalues") {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		withValues = true
+	}
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	indexes := randomIndexes(len(fields), count)
+	reply := make([]any, 0, len(indexes))
+	for _, index := range indexes {
+		reply = append(reply, fields[index])
+		if withValues {
+			reply = append(reply, h[fields[index]])
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if len(command) == 2 {
+		if len(reply) == 0 {
+			return c.writer.WriteNull()
+		}
+		return c.writer.WriteBulkString(reply[0].(string))
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleHkeysCommand serves HKEYS key, replying with the fields of the hash
+// in the same order as HGETALL.
+func (s *server) handleHkeysCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	fields := make([]any, 0, len(h))
+	for _, field := range h.sortedFields() {
+		fields = append(fields, field)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(fields)
+}
+
+// handleHvalsCommand serves HVALS key, replying with the values of the hash
+// in the same order as HGETALL.
+func (s *server) handleHvalsCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	values := make([]any, 0, len(h))
+	for _, field := range h.sortedFields() {
+		values = append(values, h[field])
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(values)
+}
+
+func (s *server) handleHlenCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	length := len(h)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(int64(length))
+}
+
+func (s *server) handleHexistsCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	h, ok, wrongType := lookupTyped[hash](s, key)
+	_, exists := h[command[2]]
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if !exists {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
@@ -0,0 +1,49 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestHash(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"HSET", "hash", "b", "2", "a", "1"}, ":2\r\n"},
+		// Only new fields are counted.
+		{[]string{"HSET", "hash", "a", "x", "c", "3"}, ":1\r\n"},
+		{[]string{"TYPE", "hash"}, "+hash\r\n"},
+		{[]string{"HGETALL", "hash"}, "*6\r\n$1\r\na\r\n$1\r\nx\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{[]string{"HKEYS", "hash"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"HVALS", "hash"}, "*3\r\n$1\r\nx\r\n$1\r\n2\r\n$1\r\n3\r\n"},
+		{[]string{"HGET", "hash", "a"}, "$1\r\nx\r\n"},
+		{[]string{"HGET", "hash", "z"}, "$-1\r\n"},
+		{[]string{"HGET", "missing", "a"}, "$-1\r\n"},
+		{[]string{"HMGET", "hash", "a", "z"}, "*2\r\n$1\r\nx\r\n$-1\r\n"},
+		{[]string{"HLEN", "hash"}, ":3\r\n"},
+		{[]string{"HLEN", "missing"}, ":0\r\n"},
+		{[]string{"HEXISTS", "hash", "a"}, ":1\r\n"},
+		{[]string{"HEXISTS", "hash", "z"}, ":0\r\n"},
+		{[]string{"HDEL", "hash", "a", "b", "z"}, ":2\r\n"},
+		// Deleting the last field deletes the key.
+		{[]string{"HDEL", "hash", "c"}, ":1\r\n"},
+		{[]string{"EXISTS", "hash"}, ":0\r\n"},
+		{[]string{"HGETALL", "hash"}, "*0\r\n"},
+		{[]string{"HSET", "hash", "a"}, "-ERR wrong number of arguments for 'hset' command\r\n"},
+
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"HSET", "string", "a", "b"}, wrongType},
+		{[]string{"HGET", "string", "a"}, wrongType},
+		{[]string{"HGETALL", "string"}, wrongType},
+	})
+
+	// RESP3 clients get HGETALL as a map.
+	c.Do("HELLO", "3")
+	run(t, c, []step{
+		{[]string{"HSET", "hash", "a", "1"}, ":1\r\n"},
+		{[]string{"HGETALL", "hash"}, "%1\r\n$1\r\na\r\n$1\r\n1\r\n"},
+		{[]string{"HGETALL", "missing"}, "%0\r\n"},
+	})
+}
@@ -0,0 +1,161 @@
+package goredis
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// infoField is a single name:value line of an INFO reply.
+type infoField struct {
+	name  string
+	value any
+}
+
+// infoSections lists the INFO sections in the order they are printed.
+// Sections that are not default are only printed when asked for by name or
+// with all or everything.
+var infoSections = []struct {
+	name       string
+	fields     func(s *server) []infoField
+	nonDefault bool
+}{
+	{"server", (*server).infoServer, false},
+	{"clients", (*server).infoClients, false},
+	{"memory", (*server).infoMemory, false},
+	{"persistence", (*server).infoPersistence, false},
+	{"stats", (*server).infoStats, false},
+	{"replication", (*server).infoReplication, false},
+	{"commandstats", (*server).infoCommandstats, true},
+	{"keyspace", (*server).infoKeyspace, false},
+}
+
+// handleInfoCommand serves INFO [section ...]. Without a section, or with
+// default, the default sections are included, and with all or everything
+// every section is.
+func (s *server) handleInfoCommand(c *client, command []string) error {
+	wanted := make(map[string]bool)
+	for _, section := range command[1:] {
+		wanted[strings.ToLower(section)] = true
+	}
+	all := wanted["all"] || wanted["everything"]
+	defaults := len(wanted) == 0 || wanted["default"]
+
+	var info strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section.name] && (section.nonDefault || !defaults) {
+			continue
+		}
+		if info.Len() > 0 {
+			info.WriteString("\r\n")
+		}
+		fmt.Fprintf(&info, "# %s\r\n", strings.ToUpper(section.name[:1])+section.name[1:])
+		for _, field := range section.fields(s) {
+			fmt.Fprintf(&info, "%s:%v\r\n", field.name, field.value)
+		}
+	}
+	return c.writer.WriteBulkString(info.String())
+}
+
+func (s *server) infoServer() []infoField {
+	uptime := time.Since(s.startTime)
+	port := ""
+	if _, p, err := net.SplitHostPort(s.listener.Addr().String()); err == nil {
+		port = p
+	}
+	return []infoField{
+		{"redis_version", serverVersion},
+		{"redis_mode", "standalone"},
+		{"os", runtime.GOOS + " " + runtime.GOARCH},
+		{"go_version", runtime.Version()},
+		{"process_id", os.Getpid()},
+		{"tcp_port", port},
+		{"uptime_in_seconds", int64(uptime.Seconds())},
+		{"uptime_in_days", int64(uptime.Hours() / 24)},
+	}
+}
+
+func (s *server) infoClients() []infoField {
+	s.clientsLock.Lock()
+	connected := len(s.clients)
+	s.clientsLock.Unlock()
+
+	// A client blocked on several keys waits in the list of each.
+	s.dbLock.RLock()
+	blocked := make(map[*blockedClient]struct{})
+	for _, waiters := range s.blockedKeys {
+		for _, b := range waiters {
+			blocked[b] = struct{}{}
+		}
+	}
+	s.dbLock.RUnlock()
+
+	return []infoField{
+		{"connected_clients", connected},
+		{"maxclients", s.getConfig().maxClients},
+		{"blocked_clients", len(blocked)},
+	}
+}
+
+func (s *server) infoMemory() []infoField {
+	s.dbLock.RLock()
+	usedMemory := s.usedMemory
+	s.dbLock.RUnlock()
+
+	config := s.getConfig()
+	return []infoField{
+		{"used_memory", usedMemory},
+		{"maxmemory", config.maxMemory},
+		{"maxmemory_policy", config.maxMemoryPolicy},
+	}
+}
+
+func (s *server) infoPersistence() []infoField {
+	aofEnabled := 0
+	if s.appendOnly != nil {
+		aofEnabled = 1
+	}
+	bgsaveInProgress := 0
+	if s.saving.Load() {
+		bgsaveInProgress = 1
+	}
+	return []infoField{
+		{"rdb_bgsave_in_progress", bgsaveInProgress},
+		{"rdb_last_save_time", s.lastSave.Load()},
+		{"aof_enabled", aofEnabled},
+	}
+}
+
+func (s *server) infoStats() []infoField {
+	return []infoField{
+		{"total_connections_received", s.totalConnections.Load()},
+		{"total_commands_processed", s.totalCommands.Load()},
+		{"total_net_input_bytes", s.netInputBytes.Load()},
+		{"total_net_output_bytes", s.netOutputBytes.Load()},
+		{"rejected_connections", s.rejectedConnections.Load()},
+		{"evicted_keys", s.evictedKeys.Load()},
+		{"client_output_buffer_limit_disconnections", s.outputLimitDisconnections.Load()},
+		{"sync_full", s.syncFull.Load()},
+		{"sync_partial_ok", s.syncPartialOk.Load()},
+		{"sync_partial_err", s.syncPartialErr.Load()},
+	}
+}
+
+// infoKeyspace reports the number of keys and keys with a timeout. Keys
+// that expired but were not removed yet are still counted, as in Redis.
+func (s *server) infoKeyspace() []infoField {
+	s.dbLock.RLock()
+	keys := len(s.database)
+	expires := len(s.expirations)
+	s.dbLock.RUnlock()
+
+	if keys == 0 {
+		return nil
+	}
+	return []infoField{
+		{"db0", fmt.Sprintf("keys=%d,expires=%d,avg_ttl=0", keys, expires)},
+	}
+}
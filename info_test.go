@@ -0,0 +1,68 @@
+package goredis_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// sections returns the section headers of an INFO reply in order.
+func sections(reply string) []string {
+	headers := []string{}
+	for _, line := range strings.Split(reply, "\r\n") {
+		if strings.HasPrefix(line, "# ") {
+			headers = append(headers, line[2:])
+		}
+	}
+	return headers
+}
+
+func TestInfo(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"INFO", "keyspace"}, "$12\r\n# Keyspace\r\n\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"SET", "b", "2", "EX", "100"}, "+OK\r\n"},
+		{[]string{"INFO", "KEYSPACE"}, "$44\r\n# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=0\r\n\r\n"},
+		// Unknown sections are left out.
+		{[]string{"INFO", "nosuchsection"}, "$0\r\n\r\n"},
+	})
+
+	tests := []struct {
+		sections []string
+		want     []string
+	}{
+		{nil, []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Replication", "Keyspace"}},
+		{[]string{"default"}, []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Replication", "Keyspace"}},
+		{[]string{"all"}, []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Replication", "Commandstats", "Keyspace"}},
+		// Sections are printed in their usual order whatever order they are
+		// asked for in.
+		{[]string{"stats", "clients"}, []string{"Clients", "Stats"}},
+		{[]string{"commandstats"}, []string{"Commandstats"}},
+	}
+	for _, test := range tests {
+		reply := c.Do(append([]string{"INFO"}, test.sections...)...)
+		if got := sections(reply); strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("INFO %s has sections %q, want %q", strings.Join(test.sections, " "), got, test.want)
+		}
+	}
+
+	info := c.Do("INFO", "clients", "stats", "server")
+	for _, field := range []string{
+		"connected_clients:2\r\n",
+		"total_connections_received:2\r\n",
+		// Every command so far, including this one.
+		fmt.Sprintf("total_commands_processed:%d\r\n", 6+len(tests)),
+		"redis_version:7.2.0\r\n",
+		"tcp_port:" + addr[strings.LastIndex(addr, ":")+1:] + "\r\n",
+	} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO lacks %q:\n%s", field, info)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package redistest runs a server for tests, the way net/http/httptest
+// does for HTTP handlers.
+//
+// It imports the server package, so tests of the server itself must use an
+// external test package such as goredis_test to import it.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+)
+
+// StartServer starts a server with options on an ephemeral port of the
+// loopback interface and returns its address. The server is stopped when
+// the test and its subtests finish; stop stops it earlier and may be called
+// more than once.
+func StartServer(tb testing.TB, options ...goredis.Option) (addr string, stop func()) {
+	tb.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("cannot listen: %v", err)
+	}
+	server := goredis.NewServer(listener, slog.New(slog.NewTextHandler(io.Discard, nil)), options...)
+
+	started := make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			// Start only returns before Stop if it failed.
+			select {
+			case err := <-started:
+				tb.Errorf("server failed: %v", err)
+			default:
+			}
+			if err := server.Stop(); err != nil && !errors.Is(err, net.ErrClosed) {
+				tb.Errorf("cannot stop server: %v", err)
+			}
+		})
+	}
+	tb.Cleanup(stop)
+	return listener.Addr().String(), stop
+}
+
+// Dial connects to the server at addr. The connection is closed when the
+// test and its subtests finish.
+func Dial(tb testing.TB, addr string) net.Conn {
+	tb.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		tb.Fatalf("cannot connect to %s: %v", addr, err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// replyTimeout bounds how long Client waits for a reply, so a server that
+// never answers fails the test instead of hanging it.
+const replyTimeout = 5 * time.Second
+
+// Client sends commands to a server and returns its replies in their raw
+// RESP form, such as "+OK\r\n", which tests compare against directly.
+type Client struct {
+	tb     testing.TB
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Connect returns a Client connected to the server at addr. The connection
+// is closed when the test and its subtests finish.
+func Connect(tb testing.TB, addr string) *Client {
+	tb.Helper()
+
+	return NewClient(tb, Dial(tb, addr))
+}
+
+// NewClient returns a Client that talks over conn, for connections Dial
+// cannot make, such as over a Unix socket or TLS.
+func NewClient(tb testing.TB, conn net.Conn) *Client {
+	return &Client{tb: tb, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Conn returns the connection of c, for tests that need to write malformed
+// requests or close it.
+func (c *Client) Conn() net.Conn {
+	return c.conn
+}
+
+// Do sends a command and returns its reply.
+func (c *Client) Do(args ...string) string {
+	c.tb.Helper()
+
+	c.Send(args...)
+	return c.Receive()
+}
+
+// Send sends a command without waiting for its reply, for commands that
+// block or whose reply is read later with Receive.
+func (c *Client) Send(args ...string) {
+	c.tb.Helper()
+
+	var request strings.Builder
+	fmt.Fprintf(&request, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, request.String()); err != nil {
+		c.tb.Fatalf("cannot send %q: %v", args, err)
+	}
+}
+
+// Receive reads the next reply or push message.
+func (c *Client) Receive() string {
+	c.tb.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(replyTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	var reply strings.Builder
+	if err := readReply(c.reader, &reply); err != nil {
+		c.tb.Fatalf("cannot read reply: %v (read so far: %q)", err, reply.String())
+	}
+	return reply.String()
+}
+
+// readReply copies one RESP value from reader to reply.
+func readReply(reader *bufio.Reader, reply *strings.Builder) error {
+	line, err := reader.ReadString('\n')
+	reply.WriteString(line)
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return fmt.Errorf("malformed line %q", line)
+	}
+
+	n, _ := strconv.Atoi(line[1 : len(line)-2])
+	switch line[0] {
+	case '$', '=', '!':
+		if n < 0 {
+			return nil
+		}
+		data := make([]byte, n+2)
+		_, err := io.ReadFull(reader, data)
+		reply.Write(data)
+		return err
+	case '*', '>', '~':
+		// A null array has no elements.
+	case '%', '|':
+		n *= 2
+	default:
+		return nil
+	}
+	for i := 0; i < n; i += 1 {
+		if err := readReply(reader, reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
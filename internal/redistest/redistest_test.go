@@ -0,0 +1,32 @@
+package redistest
+
+import (
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	addr, stop := StartServer(t)
+	c := Connect(t, addr)
+
+	tests := []struct {
+		command []string
+		want    string
+	}{
+		{[]string{"PING"}, "+PONG\r\n"},
+		{[]string{"SET", "key", "a\r\nb"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$4\r\na\r\nb\r\n"},
+		{[]string{"GET", "missing"}, "$-1\r\n"},
+		{[]string{"RPUSH", "list", "a", "b"}, ":2\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"NOSUCHCOMMAND"}, "-ERR unknown command 'NOSUCHCOMMAND', with args beginning with: \r\n"},
+	}
+	for _, test := range tests {
+		if got := c.Do(test.command...); got != test.want {
+			t.Errorf("%q = %q, want %q", test.command, got, test.want)
+		}
+	}
+
+	// Stopping early leaves nothing for the cleanup to do.
+	stop()
+	stop()
+}
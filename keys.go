@@ -0,0 +1,477 @@
+package goredis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *server) handleDelCommand(c *client, command []string) error {
+	deleted := 0
+	s.dbLock.Lock()
+	for _, key := range command[1:] {
+		if s.deleteIfExpired(key) {
+			// Expired keys no longer exist, so they are not counted.
+			continue
+		}
+		if s.deleteKey(key) {
+			s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+			deleted += 1
+		}
+	}
+	if deleted > 0 {
+		s.propagate(command...)
+	}
+	s.dbLock.Unlock()
+
+	return c.writer.WriteInteger(int64(deleted))
+}
+
+func (s *server) handleExistsCommand(c *client, command []string) error {
+	// Keys are counted once per occurrence, so EXISTS foo foo returns 2.
+	found := 0
+	s.dbLock.RLock()
+	for _, key := range command[1:] {
+		if _, ok := s.lookupKey(key); ok {
+			found += 1
+		}
+	}
+	s.dbLock.RUnlock()
+
+	return c.writer.WriteInteger(int64(found))
+}
+
+// handleExpireCommand serves EXPIRE key seconds [NX|XX|GT|LT], which
+// replies 1 if the timeout was set and 0 if key does not exist or the
+// option's condition on the current timeout was not met.
+func (s *server) handleExpireCommand(c *client, command []string) error {
+	key := command[1]
+
+	seconds, err := strconv.ParseInt(command[2], 10, 64)
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	if seconds > math.MaxInt64/int64(time.Second) || seconds < math.MinInt64/int64(time.Second) {
+		return c.writer.WriteError("ERR invalid expire time in 'expire' command")
+	}
+	flags, message := parseExpireFlags(command[3:])
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	deadline := time.Now().Add(time.Duration(seconds) * time.Second)
+	ok := s.expireAllowed(key, deadline, flags) && s.expireAt(key, deadline)
+	s.dbLock.Unlock()
+
+	if !ok {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+// expireFlags are the NX, XX, GT and LT options of EXPIRE and its variants,
+// which make setting the timeout depend on the current one.
+type expireFlags struct {
+	nx, xx, gt, lt bool
+}
+
+// parseExpireFlags parses the options after the time argument. The returned
+// string is an error message to reply with when they are invalid.
+func parseExpireFlags(args []string) (expireFlags, string) {
+	var flags expireFlags
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			flags.nx = true
+		case "XX":
+			flags.xx = true
+		case "GT":
+			flags.gt = true
+		case "LT":
+			flags.lt = true
+		default:
+			return flags, fmt.Sprintf("ERR Unsupported option %s", arg)
+		}
+	}
+	if flags.nx && (flags.xx || flags.gt || flags.lt) {
+		return flags, "ERR NX and XX, GT or LT options at the same time are not compatible"
+	}
+	if flags.gt && flags.lt {
+		return flags, "ERR GT and LT options at the same time are not compatible"
+	}
+	return flags, ""
+}
+
+// expireAllowed reports whether flags allow deadline to replace the timeout
+// of key. A key without a timeout counts as expiring never, so GT never
+// applies to it and LT always does. Callers must hold dbLock.
+func (s *server) expireAllowed(key string, deadline time.Time, flags expireFlags) bool {
+	current, hasDeadline := s.expirations[key]
+	switch {
+	case flags.nx:
+		return !hasDeadline
+	case flags.xx && !hasDeadline:
+		return false
+	case flags.gt:
+		return hasDeadline && deadline.After(current)
+	case flags.lt:
+		return !hasDeadline || deadline.Before(current)
+	}
+	return true
+}
+
+// handleExpireatCommand serves both EXPIREAT key unix-time-seconds and
+// PEXPIREAT key unix-time-milliseconds, which take the same options as
+// EXPIRE. PEXPIREAT is also the form EXPIRE is propagated in.
+func (s *server) handleExpireatCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+
+	timestamp, err := strconv.ParseInt(command[2], 10, 64)
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	milliseconds := timestamp
+	if name == "expireat" {
+		if timestamp > math.MaxInt64/1000 || timestamp < math.MinInt64/1000 {
+			return c.writer.WriteError("ERR invalid expire time in 'expireat' command")
+		}
+		milliseconds = timestamp * 1000
+	}
+	flags, message := parseExpireFlags(command[3:])
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	deadline := time.UnixMilli(milliseconds)
+	ok := s.expireAllowed(key, deadline, flags) && s.expireAt(key, deadline)
+	s.dbLock.Unlock()
+
+	if !ok {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+// expireAt sets the expiration time of key, deleting it right away when the
+// deadline has already passed, and reports whether the key exists. The
+// change is propagated as PEXPIREAT or DEL. Callers must hold dbLock for
+// writing.
+func (s *server) expireAt(key string, deadline time.Time) bool {
+	s.deleteIfExpired(key)
+	if _, ok := s.database[key]; !ok {
+		return false
+	}
+
+	if !deadline.After(time.Now()) {
+		s.deleteKey(key)
+		s.propagate("DEL", key)
+		s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		return true
+	}
+	s.expirations[key] = deadline
+	s.touchKey(key)
+	s.propagate("PEXPIREAT", key, strconv.FormatInt(deadline.UnixMilli(), 10))
+	s.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	return true
+}
+
+// handlePersistCommand serves PERSIST key, which removes the timeout of key
+// and replies 1 if it had one.
+func (s *server) handlePersistCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	_, persisted := s.expirations[key]
+	if persisted {
+		delete(s.expirations, key)
+		s.touchKey(key)
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	}
+	s.dbLock.Unlock()
+
+	if !persisted {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+// handleTtlCommand serves both TTL, which replies in seconds, and PTTL, which
+// replies in milliseconds.
+func (s *server) handleTtlCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+
+	s.dbLock.RLock()
+	_, ok := s.lookupKey(key)
+	deadline, hasDeadline := s.expirations[key]
+	s.dbLock.RUnlock()
+
+	var ttl int64
+	switch {
+	case !ok:
+		s.expireKey(key)
+		ttl = -2
+	case !hasDeadline:
+		ttl = -1
+	default:
+		remaining := time.Until(deadline).Milliseconds()
+		if name == "ttl" {
+			// Round to the nearest second like Redis does.
+			remaining = (remaining + 500) / 1000
+		}
+		ttl = remaining
+	}
+
+	return c.writer.WriteInteger(ttl)
+}
+
+// handleKeysCommand replies with every key matching the pattern. It walks the
+// whole keyspace while holding the read lock, so it is O(N) and blocks writers
+// for the duration; SCAN is the incremental alternative.
+func (s *server) handleKeysCommand(c *client, command []string) error {
+	pattern := command[1]
+
+	keys := []any{}
+	now := time.Now()
+	s.dbLock.RLock()
+	for key := range s.database {
+		if !s.isExpired(key, now) && matchGlob(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	s.dbLock.RUnlock()
+
+	return c.writer.WriteArray(keys)
+}
+
+// handleScanCommand serves SCAN cursor [MATCH pattern] [COUNT count]. COUNT is
+// how many keys to visit, and MATCH filters the visited keys afterwards, so a
+// call may return fewer keys than COUNT or none at all before the scan ends.
+func (s *server) handleScanCommand(c *client, command []string) error {
+	cursor, err := strconv.ParseUint(command[1], 10, 64)
+	if err != nil {
+		return c.writer.WriteError("ERR invalid cursor")
+	}
+
+	pattern := ""
+	count := scanDefaultCount
+	for i := 2; i < len(command); i += 2 {
+		if i+1 == len(command) {
+			// Every option takes a value.
+			return c.writer.WriteError("ERR syntax error")
+		}
+		switch strings.ToUpper(command[i]) {
+		case "MATCH":
+			pattern = command[i+1]
+		case "COUNT":
+			n, err := strconv.Atoi(command[i+1])
+			if err != nil {
+				return c.writer.WriteError("ERR value is not an integer or out of range")
+			}
+			if n < 1 {
+				return c.writer.WriteError("ERR syntax error")
+			}
+			count = n
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.RLock()
+	keys, next := s.scanKeys(cursor, count)
+	s.dbLock.RUnlock()
+
+	matched := []any{}
+	for _, key := range keys {
+		if pattern == "" || matchGlob(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	return c.writer.WriteArray([]any{strconv.FormatUint(next, 10), matched})
+}
+
+func (s *server) handleTypeCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	value, ok := s.lookupKey(key)
+	s.dbLock.RUnlock()
+
+	if !ok {
+		s.expireKey(key)
+		return c.writer.WriteSimpleString("none")
+	}
+	return c.writer.WriteSimpleString(typeName(value))
+}
+
+// handleFlushCommand serves FLUSHDB and FLUSHALL, which are the same thing
+// while there is a single database. The ASYNC and SYNC modes are accepted but
+// both flush synchronously.
+func (s *server) handleFlushCommand(c *client, command []string) error {
+	if len(command) == 2 {
+		mode := strings.ToUpper(command[1])
+		if mode != "ASYNC" && mode != "SYNC" {
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.Lock()
+	s.flushKeys()
+	s.propagate(command...)
+	s.dbLock.Unlock()
+
+	return c.writer.WriteSimpleString("OK")
+}
+
+func (s *server) handleDbsizeCommand(c *client, command []string) error {
+	// Keys past their deadline that the reaper has not removed yet are not
+	// counted.
+	now := time.Now()
+	s.dbLock.RLock()
+	size := len(s.database)
+	for _, deadline := range s.expirations {
+		if !now.Before(deadline) {
+			size -= 1
+		}
+	}
+	s.dbLock.RUnlock()
+
+	return c.writer.WriteInteger(int64(size))
+}
+
+// handleRandomkeyCommand serves RANDOMKEY. The key is the first one map
+// iteration yields, which starts at a random position but is not uniform:
+// keys following a run of empty buckets come up more often. That is good
+// enough for sampling and costs O(1) instead of a walk of the keyspace.
+func (s *server) handleRandomkeyCommand(c *client, command []string) error {
+	found := false
+	key := ""
+	now := time.Now()
+	s.dbLock.RLock()
+	for k := range s.database {
+		if !s.isExpired(k, now) {
+			key = k
+			found = true
+			break
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if !found {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(key)
+}
+
+// renameKey moves the value and timeout of src to dst, replacing dst.
+// Callers must hold dbLock for writing and have checked that src exists.
+func (s *server) renameKey(src string, dst string) {
+	if src == dst {
+		return
+	}
+	value := s.database[src].value
+	deadline, hasDeadline := s.expirations[src]
+	s.deleteKey(src)
+	s.deleteKey(dst)
+
+	s.storeValue(dst, value)
+	if hasDeadline {
+		s.expirations[dst] = deadline
+	}
+	s.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	s.notifyKeyspaceEvent(notifyGeneric, "rename_to", dst)
+}
+
+func (s *server) handleRenameCommand(c *client, command []string) error {
+	src := command[1]
+	dst := command[2]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(src)
+	_, ok := s.database[src]
+	if ok {
+		s.renameKey(src, dst)
+		s.propagate(command...)
+	}
+	s.dbLock.Unlock()
+
+	if !ok {
+		return c.writer.WriteError("ERR no such key")
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+func (s *server) handleRenamenxCommand(c *client, command []string) error {
+	src := command[1]
+	dst := command[2]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(src)
+	s.deleteIfExpired(dst)
+	_, ok := s.database[src]
+	_, dstExists := s.database[dst]
+	renamed := ok && !dstExists
+	if renamed {
+		s.renameKey(src, dst)
+		s.propagate(command...)
+	}
+	s.dbLock.Unlock()
+
+	if !ok {
+		return c.writer.WriteError("ERR no such key")
+	}
+	if !renamed {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+// handleCopyCommand serves COPY source destination [REPLACE], which copies
+// the value and timeout of source. Without REPLACE an existing destination
+// is left alone.
+func (s *server) handleCopyCommand(c *client, command []string) error {
+	src := command[1]
+	dst := command[2]
+
+	replace := false
+	for _, option := range command[3:] {
+		if strings.ToUpper(option) != "REPLACE" {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		replace = true
+	}
+	if src == dst {
+		return c.writer.WriteError("ERR source and destination objects are the same")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(src)
+	s.deleteIfExpired(dst)
+	e, ok := s.database[src]
+	_, dstExists := s.database[dst]
+	copied := ok && (replace || !dstExists)
+	if copied {
+		deadline, hasDeadline := s.expirations[src]
+		s.deleteKey(dst)
+		s.storeValue(dst, copyValue(e.value))
+		if hasDeadline {
+			s.expirations[dst] = deadline
+		}
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyGeneric, "copy_to", dst)
+	}
+	s.dbLock.Unlock()
+
+	if !copied {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
@@ -0,0 +1,457 @@
+package goredis_test
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// step is a command and the raw reply a test expects for it.
+type step struct {
+	command []string
+	want    string
+}
+
+// run sends each command in turn and checks its reply.
+func run(t *testing.T, c *redistest.Client, steps []step) {
+	t.Helper()
+	for _, step := range steps {
+		if got := c.Do(step.command...); got != step.want {
+			t.Errorf("%q = %q, want %q", step.command, got, step.want)
+		}
+	}
+}
+
+func TestDel(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"SET", "b", "2"}, "+OK\r\n"},
+		{[]string{"SET", "c", "3"}, "+OK\r\n"},
+		// An existing key.
+		{[]string{"DEL", "a"}, ":1\r\n"},
+		{[]string{"GET", "a"}, "$-1\r\n"},
+		// A missing key.
+		{[]string{"DEL", "a"}, ":0\r\n"},
+		// Existing and missing keys together, counting each key once.
+		{[]string{"DEL", "b", "missing", "c", "b"}, ":2\r\n"},
+		{[]string{"DBSIZE"}, ":0\r\n"},
+		{[]string{"DEL"}, "-ERR wrong number of arguments for 'del' command\r\n"},
+	})
+}
+
+func TestExists(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "foo", "1"}, "+OK\r\n"},
+		{[]string{"SET", "bar", "2"}, "+OK\r\n"},
+		{[]string{"EXISTS", "foo"}, ":1\r\n"},
+		{[]string{"EXISTS", "missing"}, ":0\r\n"},
+		{[]string{"EXISTS", "foo", "bar", "missing"}, ":2\r\n"},
+		// A key listed twice is counted twice.
+		{[]string{"EXISTS", "foo", "foo"}, ":2\r\n"},
+		{[]string{"EXISTS"}, "-ERR wrong number of arguments for 'exists' command\r\n"},
+	})
+}
+
+func TestTtl(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"TTL", "missing"}, ":-2\r\n"},
+		{[]string{"PTTL", "missing"}, ":-2\r\n"},
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":-1\r\n"},
+		{[]string{"PTTL", "key"}, ":-1\r\n"},
+		// TTL rounds to the nearest second.
+		{[]string{"SET", "key", "value", "PX", "100400"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"TTL"}, "-ERR wrong number of arguments for 'ttl' command\r\n"},
+		{[]string{"PTTL", "a", "b"}, "-ERR wrong number of arguments for 'pttl' command\r\n"},
+	})
+
+	reply := c.Do("PTTL", "key")
+	ttl, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(reply, ":"), "\r\n"))
+	if err != nil || ttl <= 99000 || ttl > 100400 {
+		t.Errorf("PTTL = %q, want about 100400", reply)
+	}
+
+	// A key past its deadline is gone.
+	c.Do("SET", "key", "value", "PX", "1")
+	time.Sleep(10 * time.Millisecond)
+	run(t, c, []step{
+		{[]string{"TTL", "key"}, ":-2\r\n"},
+		{[]string{"PTTL", "key"}, ":-2\r\n"},
+	})
+}
+
+func TestKeys(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	c.Do("MSET", "user:1", "a", "user:2", "b", "hello", "c", "hallo", "d", "hillo", "e")
+	c.Do("SET", "user:expired", "f", "PX", "1")
+	time.Sleep(10 * time.Millisecond)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"user:*", []string{"user:1", "user:2"}},
+		{"h[ae]llo", []string{"hallo", "hello"}},
+		{"h?llo", []string{"hallo", "hello", "hillo"}},
+		{"*", []string{"hallo", "hello", "hillo", "user:1", "user:2"}},
+		{"nothing*", []string{}},
+	}
+	for _, test := range tests {
+		got := bulkStrings(t, c.Do("KEYS", test.pattern))
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("KEYS %s = %q, want %q", test.pattern, got, test.want)
+		}
+	}
+	run(t, c, []step{{[]string{"KEYS"}, "-ERR wrong number of arguments for 'keys' command\r\n"}})
+}
+
+// scanReply splits a raw SCAN reply into its cursor and keys.
+func scanReply(t *testing.T, reply string) (string, []string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(reply, "\r\n"), "\r\n")
+	if len(lines) < 4 || lines[0] != "*2" {
+		t.Fatalf("reply %q is not a SCAN reply", reply)
+	}
+	return lines[2], bulkStrings(t, strings.Join(lines[3:], "\r\n")+"\r\n")
+}
+
+func TestScan(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	for i := range 100 {
+		c.Do("SET", fmt.Sprintf("key:%d", i), "value")
+	}
+
+	// Every key that exists for the whole scan is returned, even as other
+	// keys come and go in between, and each is returned once.
+	seen := map[string]int{}
+	cursor := "0"
+	for calls := 0; ; calls += 1 {
+		if calls > 100 {
+			t.Fatal("SCAN did not finish")
+		}
+		next, keys := scanReply(t, c.Do("SCAN", cursor, "COUNT", "7"))
+		if len(keys) > 7 {
+			t.Errorf("SCAN COUNT 7 returned %d keys", len(keys))
+		}
+		for _, key := range keys {
+			seen[key] += 1
+		}
+		c.Do("SET", fmt.Sprintf("new:%d", calls), "value")
+		c.Do("DEL", fmt.Sprintf("new:%d", calls-1))
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+	for i := range 100 {
+		key := fmt.Sprintf("key:%d", i)
+		if seen[key] != 1 {
+			t.Errorf("%s was returned %d times, want once", key, seen[key])
+		}
+	}
+
+	// MATCH filters the keys of each batch.
+	matched := []string{}
+	cursor = "0"
+	for {
+		next, keys := scanReply(t, c.Do("SCAN", cursor, "MATCH", "key:1?", "COUNT", "10"))
+		matched = append(matched, keys...)
+		if cursor = next; cursor == "0" {
+			break
+		}
+	}
+	slices.Sort(matched)
+	want := []string{}
+	for i := 10; i < 20; i += 1 {
+		want = append(want, fmt.Sprintf("key:%d", i))
+	}
+	if !slices.Equal(matched, want) {
+		t.Errorf("SCAN MATCH key:1? returned %q, want %q", matched, want)
+	}
+
+	run(t, c, []step{
+		{[]string{"SCAN", "x"}, "-ERR invalid cursor\r\n"},
+		{[]string{"SCAN", "0", "COUNT", "0"}, "-ERR syntax error\r\n"},
+		{[]string{"SCAN", "0", "COUNT", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"SCAN", "0", "FOO", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"SCAN"}, "-ERR wrong number of arguments for 'scan' command\r\n"},
+	})
+}
+
+func TestType(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "a"}, ":1\r\n"},
+		{[]string{"SADD", "set", "a"}, ":1\r\n"},
+		{[]string{"HSET", "hash", "field", "value"}, ":1\r\n"},
+		{[]string{"ZADD", "zset", "1", "a"}, ":1\r\n"},
+		{[]string{"TYPE", "string"}, "+string\r\n"},
+		{[]string{"TYPE", "list"}, "+list\r\n"},
+		{[]string{"TYPE", "set"}, "+set\r\n"},
+		{[]string{"TYPE", "hash"}, "+hash\r\n"},
+		{[]string{"TYPE", "zset"}, "+zset\r\n"},
+		{[]string{"TYPE", "missing"}, "+none\r\n"},
+		// Removing the last element removes the key.
+		{[]string{"SREM", "set", "a"}, ":1\r\n"},
+		{[]string{"TYPE", "set"}, "+none\r\n"},
+		{[]string{"TYPE"}, "-ERR wrong number of arguments for 'type' command\r\n"},
+	})
+}
+
+func TestFlush(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	for _, command := range [][]string{{"FLUSHDB"}, {"FLUSHALL"}, {"FLUSHDB", "ASYNC"}, {"flushall", "sync"}} {
+		c.Do("MSET", "a", "1", "b", "2")
+		c.Do("SET", "c", "3", "EX", "100")
+		c.Do("RPUSH", "list", "x")
+		run(t, c, []step{
+			{[]string{"DBSIZE"}, ":4\r\n"},
+			{command, "+OK\r\n"},
+			{[]string{"DBSIZE"}, ":0\r\n"},
+			{[]string{"GET", "a"}, "$-1\r\n"},
+			// The expiration time went with the key.
+			{[]string{"SET", "c", "3"}, "+OK\r\n"},
+			{[]string{"TTL", "c"}, ":-1\r\n"},
+			{[]string{"DEL", "c"}, ":1\r\n"},
+		})
+	}
+	run(t, c, []step{
+		{[]string{"FLUSHDB", "NOW"}, "-ERR syntax error\r\n"},
+		{[]string{"FLUSHALL", "SYNC", "ASYNC"}, "-ERR wrong number of arguments for 'flushall' command\r\n"},
+	})
+}
+
+func TestDbsize(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"DBSIZE"}, ":0\r\n"},
+		{[]string{"MSET", "a", "1", "b", "2", "c", "3"}, "+OK\r\n"},
+		{[]string{"SADD", "set", "x"}, ":1\r\n"},
+		{[]string{"DBSIZE"}, ":4\r\n"},
+		{[]string{"DEL", "a"}, ":1\r\n"},
+		{[]string{"DBSIZE"}, ":3\r\n"},
+		{[]string{"DBSIZE", "extra"}, "-ERR wrong number of arguments for 'dbsize' command\r\n"},
+		// Keys past their expiration time are not counted even before they
+		// are removed.
+		{[]string{"DEBUG", "SET-ACTIVE-EXPIRE", "0"}, "+OK\r\n"},
+		{[]string{"SET", "short", "value", "PX", "1"}, "+OK\r\n"},
+		{[]string{"SET", "long", "value", "EX", "100"}, "+OK\r\n"},
+	})
+	time.Sleep(10 * time.Millisecond)
+	run(t, c, []step{{[]string{"DBSIZE"}, ":4\r\n"}})
+}
+
+func TestRename(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RENAME", "missing", "b"}, "-ERR no such key\r\n"},
+		{[]string{"SET", "a", "1", "EX", "100"}, "+OK\r\n"},
+		{[]string{"RENAME", "a", "b"}, "+OK\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"GET", "b"}, "$1\r\n1\r\n"},
+		// The expiration time moves with the value.
+		{[]string{"TTL", "b"}, ":100\r\n"},
+		// An existing destination is overwritten, whatever its type, and
+		// loses its own expiration time.
+		{[]string{"SADD", "a", "x"}, ":1\r\n"},
+		{[]string{"RENAME", "a", "b"}, "+OK\r\n"},
+		{[]string{"TYPE", "b"}, "+set\r\n"},
+		{[]string{"TTL", "b"}, ":-1\r\n"},
+		// Renaming a key to itself keeps it.
+		{[]string{"RENAME", "b", "b"}, "+OK\r\n"},
+		{[]string{"TYPE", "b"}, "+set\r\n"},
+		{[]string{"RENAME", "a"}, "-ERR wrong number of arguments for 'rename' command\r\n"},
+	})
+}
+
+func TestRenamenx(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RENAMENX", "missing", "b"}, "-ERR no such key\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"SET", "b", "2"}, "+OK\r\n"},
+		// An existing destination is left alone.
+		{[]string{"RENAMENX", "a", "b"}, ":0\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+		{[]string{"GET", "b"}, "$1\r\n2\r\n"},
+		{[]string{"RENAMENX", "a", "c"}, ":1\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"GET", "c"}, "$1\r\n1\r\n"},
+		{[]string{"RENAMENX", "c", "c"}, ":0\r\n"},
+		// A destination past its expiration time no longer exists.
+		{[]string{"SET", "d", "4", "PX", "1"}, "+OK\r\n"},
+	})
+	time.Sleep(10 * time.Millisecond)
+	run(t, c, []step{
+		{[]string{"RENAMENX", "c", "d"}, ":1\r\n"},
+		{[]string{"GET", "d"}, "$1\r\n1\r\n"},
+	})
+}
+
+func TestCopy(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"COPY", "a", "b"}, ":0\r\n"},
+		{[]string{"SET", "a", "1", "EX", "100"}, "+OK\r\n"},
+		{[]string{"SET", "b", "2"}, "+OK\r\n"},
+		// An existing destination is kept unless REPLACE is given.
+		{[]string{"COPY", "a", "b"}, ":0\r\n"},
+		{[]string{"GET", "b"}, "$1\r\n2\r\n"},
+		{[]string{"COPY", "a", "b", "replace"}, ":1\r\n"},
+		{[]string{"GET", "b"}, "$1\r\n1\r\n"},
+		// The expiration time is copied with the value.
+		{[]string{"TTL", "b"}, ":100\r\n"},
+		// Collections are copied, not shared.
+		{[]string{"RPUSH", "list", "x"}, ":1\r\n"},
+		{[]string{"COPY", "list", "copy"}, ":1\r\n"},
+		{[]string{"RPUSH", "copy", "y"}, ":2\r\n"},
+		{[]string{"LLEN", "list"}, ":1\r\n"},
+		{[]string{"COPY", "a", "a"}, "-ERR source and destination objects are the same\r\n"},
+		{[]string{"COPY", "a", "c", "x"}, "-ERR syntax error\r\n"},
+		{[]string{"COPY", "a"}, "-ERR wrong number of arguments for 'copy' command\r\n"},
+	})
+}
+
+func TestPersist(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"PERSIST", "a"}, ":0\r\n"},
+		{[]string{"SET", "a", "1", "EX", "100"}, "+OK\r\n"},
+		{[]string{"PERSIST", "a"}, ":1\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		// A key without an expiration time is left alone.
+		{[]string{"PERSIST", "a"}, ":0\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+		{[]string{"PERSIST"}, "-ERR wrong number of arguments for 'persist' command\r\n"},
+	})
+}
+
+func TestExpireat(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	future := time.Now().Add(100 * time.Second)
+
+	run(t, c, []step{
+		{[]string{"EXPIREAT", "a", "1"}, ":0\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"EXPIREAT", "a", strconv.FormatInt(future.Unix(), 10)}, ":1\r\n"},
+		{[]string{"EXISTS", "a"}, ":1\r\n"},
+		{[]string{"PEXPIREAT", "a", strconv.FormatInt(future.UnixMilli(), 10)}, ":1\r\n"},
+		{[]string{"EXISTS", "a"}, ":1\r\n"},
+		{[]string{"EXPIREAT", "a", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"EXPIREAT", "a", "9223372036854775807"}, "-ERR invalid expire time in 'expireat' command\r\n"},
+		// A time in the past deletes the key.
+		{[]string{"EXPIREAT", "a", "1"}, ":1\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"PEXPIREAT", "a", "1"}, ":1\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"EXPIREAT", "a"}, "-ERR wrong number of arguments for 'expireat' command\r\n"},
+	})
+	if got := c.Do("SET", "a", "1"); got != "+OK\r\n" {
+		t.Fatalf("SET = %q", got)
+	}
+	c.Do("EXPIREAT", "a", strconv.FormatInt(future.Unix(), 10))
+	if ttl := c.Do("TTL", "a"); ttl != ":100\r\n" && ttl != ":99\r\n" {
+		t.Errorf("TTL = %q after EXPIREAT 100 seconds ahead", ttl)
+	}
+}
+
+func TestRandomkey(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RANDOMKEY"}, "$-1\r\n"},
+		// Keep the expired key in the keyspace, where RANDOMKEY must skip
+		// it.
+		{[]string{"DEBUG", "SET-ACTIVE-EXPIRE", "0"}, "+OK\r\n"},
+		{[]string{"SET", "gone", "x", "PX", "1"}, "+OK\r\n"},
+	})
+	time.Sleep(10 * time.Millisecond)
+	run(t, c, []step{
+		{[]string{"RANDOMKEY"}, "$-1\r\n"},
+		{[]string{"MSET", "a", "1", "b", "2", "c", "3"}, "+OK\r\n"},
+	})
+
+	seen := map[string]bool{}
+	for range 100 {
+		switch reply := c.Do("RANDOMKEY"); reply {
+		case "$1\r\na\r\n", "$1\r\nb\r\n", "$1\r\nc\r\n":
+			seen[reply] = true
+		default:
+			t.Fatalf("RANDOMKEY = %q", reply)
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("RANDOMKEY returned only %v in 100 calls", seen)
+	}
+}
+
+func TestExpireOptions(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		// A key without an expiration time counts as expiring never, so XX
+		// and GT leave it alone while LT sets one.
+		{[]string{"EXPIRE", "key", "100", "XX"}, ":0\r\n"},
+		{[]string{"EXPIRE", "key", "100", "GT"}, ":0\r\n"},
+		{[]string{"TTL", "key"}, ":-1\r\n"},
+		{[]string{"EXPIRE", "key", "100", "NX"}, ":1\r\n"},
+		{[]string{"EXPIRE", "key", "200", "NX"}, ":0\r\n"},
+		{[]string{"EXPIRE", "key", "50", "GT"}, ":0\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"EXPIRE", "key", "200", "gt"}, ":1\r\n"},
+		{[]string{"EXPIRE", "key", "300", "LT"}, ":0\r\n"},
+		{[]string{"EXPIRE", "key", "150", "LT", "XX"}, ":1\r\n"},
+		{[]string{"TTL", "key"}, ":150\r\n"},
+		// The options work for absolute times too.
+		{[]string{"PEXPIREAT", "key", "1", "GT"}, ":0\r\n"},
+		{[]string{"EXPIREAT", "key", "1", "LT"}, ":1\r\n"},
+		{[]string{"EXISTS", "key"}, ":0\r\n"},
+		{[]string{"SET", "other", "value"}, "+OK\r\n"},
+		{[]string{"EXPIRE", "other", "100", "LT"}, ":1\r\n"},
+		{[]string{"TTL", "other"}, ":100\r\n"},
+
+		{[]string{"EXPIRE", "other", "1", "NX", "GT"}, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{[]string{"EXPIRE", "other", "1", "NX", "XX"}, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{[]string{"EXPIRE", "other", "1", "GT", "LT"}, "-ERR GT and LT options at the same time are not compatible\r\n"},
+		{[]string{"EXPIRE", "other", "1", "FOO"}, "-ERR Unsupported option FOO\r\n"},
+		{[]string{"TTL", "other"}, ":100\r\n"},
+	})
+}
@@ -0,0 +1,673 @@
+package goredis
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// list is the value of a list key. Lists are never empty; the key is
+// deleted along with the last element.
+type list struct {
+	elements []string
+}
+
+// handlePushCommand serves both LPUSH and RPUSH key element [element ...],
+// which insert the elements one by one at the head or the tail of the list,
+// creating it if needed, and reply with its new length.
+func (s *server) handlePushCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+	elements := command[2:]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	length := 0
+	if !wrongType {
+		if !ok {
+			l = &list{}
+			s.storeValue(key, l)
+		}
+		if name == "lpush" {
+			// Pushing one by one to the head leaves them in reverse order.
+			head := slices.Clone(elements)
+			slices.Reverse(head)
+			l.elements = append(head, l.elements...)
+		} else {
+			l.elements = append(l.elements, elements...)
+		}
+		for _, element := range elements {
+			s.usedMemory += elementSize(element)
+		}
+		// Other clients may pop as soon as dbLock is released.
+		length = len(l.elements)
+		s.touchKey(key)
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyList, name, key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(length))
+}
+
+// handlePopCommand serves both LPOP and RPOP key [count], which remove
+// elements from the head or the tail of the list. Without count the reply
+// is a single element, and with it an array of up to count elements.
+func (s *server) handlePopCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+
+	count := 1
+	if len(command) == 3 {
+		n, err := strconv.Atoi(command[2])
+		if err != nil || n < 0 {
+			return c.writer.WriteError("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	var popped []string
+	if ok {
+		popped = s.popElements(key, l, name == "lpop", count)
+		if len(popped) > 0 {
+			s.propagate(command...)
+		}
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case len(command) == 2 && !ok:
+		return c.writer.WriteNull()
+	case len(command) == 2:
+		return c.writer.WriteBulkString(popped[0])
+	case !ok:
+		return c.writer.WriteNullArray()
+	}
+	reply := make([]any, len(popped))
+	for i, element := range popped {
+		reply[i] = element
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// popElements removes up to count elements from the head of l, or from its
+// tail, and returns them in the order they were removed. key is deleted
+// when l becomes empty. The removal is notified as lpop or rpop. Callers
+// must hold dbLock for writing.
+func (s *server) popElements(key string, l *list, head bool, count int) []string {
+	count = min(count, len(l.elements))
+	var popped []string
+	if head {
+		popped = l.elements[:count]
+		l.elements = l.elements[count:]
+	} else {
+		popped = slices.Clone(l.elements[len(l.elements)-count:])
+		slices.Reverse(popped)
+		l.elements = l.elements[:len(l.elements)-count]
+	}
+	for _, element := range popped {
+		s.usedMemory -= elementSize(element)
+	}
+	if count > 0 {
+		if head {
+			s.notifyKeyspaceEvent(notifyList, "lpop", key)
+		} else {
+			s.notifyKeyspaceEvent(notifyList, "rpop", key)
+		}
+	}
+
+	if len(l.elements) == 0 {
+		s.deleteKey(key)
+		s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	} else if count > 0 {
+		s.touchKey(key)
+	}
+	return popped
+}
+
+// parseBlockingPop parses BLPOP and BRPOP key [key ...] timeout, where
+// timeout is in seconds and 0 waits forever. message is the error to reply
+// with if the timeout is invalid.
+func parseBlockingPop(command []string) (keys []string, timeout time.Duration, message string) {
+	seconds, err := strconv.ParseFloat(command[len(command)-1], 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return nil, 0, "ERR timeout is not a float or out of range"
+	}
+	if seconds < 0 {
+		return nil, 0, "ERR timeout is negative"
+	}
+	return command[1 : len(command)-1], time.Duration(seconds * float64(time.Second)), ""
+}
+
+// popFirst pops an element from the first of keys holding a list, from its
+// head or its tail, and propagates it as LPOP or RPOP. ok is false if none
+// of the keys holds a list, and wrongType reports a key holding another type
+// before any list. Callers must hold dbLock for writing.
+func (s *server) popFirst(keys []string, head bool) (key, element string, ok, wrongType bool) {
+	for _, key := range keys {
+		s.deleteIfExpired(key)
+		l, ok, wrongType := lookupTyped[*list](s, key)
+		if wrongType {
+			return "", "", false, true
+		}
+		if ok {
+			element := s.popElements(key, l, head, 1)[0]
+			if head {
+				s.propagate("LPOP", key)
+			} else {
+				s.propagate("RPOP", key)
+			}
+			return key, element, true, false
+		}
+	}
+	return "", "", false, false
+}
+
+// handleBlockingPopCommand serves BLPOP and BRPOP inside a transaction.
+// Nothing else runs until the transaction is over, so instead of blocking
+// it replies right away as if the timeout had elapsed.
+func (s *server) handleBlockingPopCommand(c *client, command []string) error {
+	keys, _, message := parseBlockingPop(command)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	key, element, ok, wrongType := s.popFirst(keys, strings.ToLower(command[0]) == "blpop")
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case !ok:
+		return c.writer.WriteNullArray()
+	}
+	return c.writer.WriteArray([]any{key, element})
+}
+
+// waitForPop serves BLPOP and BRPOP key [key ...] timeout, which pop an
+// element from the first of the keys holding a list, like LPOP and RPOP. If
+// all of them are empty, the connection blocks until another client pushes
+// to one of them or the timeout elapses, which replies with a null array.
+// It takes execLock only while checking the keys, so the wait does not hold
+// up transactions. A client that disconnects while blocked is removed from
+// the waiters of its keys before the connection is cleaned up.
+func (s *server) waitForPop(c *client, command []string) error {
+	keys, timeout, message := parseBlockingPop(command)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+	head := strings.ToLower(command[0]) == "blpop"
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var blocked *blockedClient
+	var gone <-chan struct{}
+	for {
+		s.execLock.RLock()
+		s.dbLock.Lock()
+		// Clients blocked on a key are served in the order they blocked,
+		// so only keys this client is first in line for are popped from.
+		key, element, ok, wrongType := s.popFirst(s.firstInLine(blocked, keys), head)
+		switch {
+		case ok && blocked != nil:
+			s.unblock(blocked, keys)
+		case !ok && !wrongType && blocked == nil:
+			blocked = s.blockOnKeys(keys)
+			defer func() {
+				s.dbLock.Lock()
+				s.unblock(blocked, keys)
+				s.dbLock.Unlock()
+			}()
+		}
+		s.dbLock.Unlock()
+		s.execLock.RUnlock()
+
+		switch {
+		case wrongType:
+			return c.writer.WriteError(wrongTypeError)
+		case ok:
+			return c.writer.WriteArray([]any{key, element})
+		}
+
+		if gone == nil {
+			var stop func()
+			gone, stop = watchDisconnect(c)
+			defer stop()
+		}
+		select {
+		case <-blocked.ready:
+		case <-expired:
+			return c.writer.WriteNullArray()
+		case <-gone:
+			c.closeAfterReply = true
+			return nil
+		case <-s.ctx.Done():
+			return c.writer.WriteNullArray()
+		}
+	}
+}
+
+// handleLrangeCommand serves LRANGE key start stop, where start and stop are
+// inclusive and negative indexes count from the end.
+func (s *server) handleLrangeCommand(c *client, command []string) error {
+	key := command[1]
+
+	start, err := strconv.Atoi(command[2])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.Atoi(command[3])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+
+	s.dbLock.RLock()
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	var reply []any
+	if ok {
+		if lo, hi, empty := normalizeRange(start, stop, len(l.elements)); !empty {
+			reply = make([]any, 0, hi-lo+1)
+			for _, element := range l.elements[lo : hi+1] {
+				reply = append(reply, element)
+			}
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if reply == nil {
+		reply = []any{}
+	}
+	return c.writer.WriteArray(reply)
+}
+
+func (s *server) handleLlenCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	length := 0
+	if ok {
+		length = len(l.elements)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(int64(length))
+}
+
+// handleLposCommand serves LPOS key element [RANK rank] [COUNT num] [MAXLEN
+// len], which replies with the index of the first match, or with an array
+// of up to num matches when COUNT is given (0 meaning all of them). A
+// negative rank searches from the tail and skips the first -rank-1
+// matches, and MAXLEN limits how many elements are compared.
+func (s *server) handleLposCommand(c *client, command []string) error {
+	key := command[1]
+	element := command[2]
+
+	rank, count, maxLen := 1, 1, 0
+	hasCount := false
+	for i := 3; i < len(command); i += 2 {
+		option := strings.ToUpper(command[i])
+		if (option != "RANK" && option != "COUNT" && option != "MAXLEN") || i+1 == len(command) {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		n, err := strconv.Atoi(command[i+1])
+		if err != nil {
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+		switch option {
+		case "RANK":
+			if n == 0 || n == math.MinInt {
+				return c.writer.WriteError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return c.writer.WriteError("ERR COUNT can't be negative")
+			}
+			count = n
+			hasCount = true
+		case "MAXLEN":
+			if n < 0 {
+				return c.writer.WriteError("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		}
+	}
+
+	s.dbLock.RLock()
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	matches := []any{}
+	if ok {
+		n := len(l.elements)
+		skip := rank - 1
+		if rank < 0 {
+			skip = -rank - 1
+		}
+		for compared := 0; compared < n && (maxLen == 0 || compared < maxLen); compared += 1 {
+			index := compared
+			if rank < 0 {
+				index = n - 1 - compared
+			}
+			if l.elements[index] != element {
+				continue
+			}
+			if skip > 0 {
+				skip -= 1
+				continue
+			}
+			matches = append(matches, int64(index))
+			if count > 0 && len(matches) == count {
+				break
+			}
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if hasCount {
+		return c.writer.WriteArray(matches)
+	}
+	if len(matches) == 0 {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteInteger(matches[0].(int64))
+}
+
+// handleLinsertCommand serves LINSERT key BEFORE|AFTER pivot element, which
+// inserts element next to the first occurrence of pivot and replies with the
+// new length, -1 if pivot was not found or 0 if key does not exist.
+func (s *server) handleLinsertCommand(c *client, command []string) error {
+	key := command[1]
+	pivot := command[3]
+	element := command[4]
+
+	where := strings.ToUpper(command[2])
+	if where != "BEFORE" && where != "AFTER" {
+		return c.writer.WriteError("ERR syntax error")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	length := 0
+	if ok {
+		index := slices.Index(l.elements, pivot)
+		if index < 0 {
+			length = -1
+		} else {
+			if where == "AFTER" {
+				index += 1
+			}
+			l.elements = slices.Insert(l.elements, index, element)
+			s.usedMemory += elementSize(element)
+			length = len(l.elements)
+			s.touchKey(key)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyList, "linsert", key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(length))
+}
+
+// handleLsetCommand serves LSET key index element, which replaces the
+// element at index. Negative indexes count from the end.
+func (s *server) handleLsetCommand(c *client, command []string) error {
+	key := command[1]
+	element := command[3]
+
+	index, err := strconv.Atoi(command[2])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	inRange := false
+	if ok {
+		if index < 0 {
+			index += len(l.elements)
+		}
+		inRange = index >= 0 && index < len(l.elements)
+		if inRange {
+			s.usedMemory += elementSize(element) - elementSize(l.elements[index])
+			l.elements[index] = element
+			s.touchKey(key)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyList, "lset", key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case !ok:
+		return c.writer.WriteError("ERR no such key")
+	case !inRange:
+		return c.writer.WriteError("ERR index out of range")
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+// handleLremCommand serves LREM key count element, which removes the first
+// count occurrences of element from the head, the last -count from the tail
+// if count is negative, or all of them if it is 0, and replies with the
+// number removed.
+func (s *server) handleLremCommand(c *client, command []string) error {
+	key := command[1]
+	element := command[3]
+
+	count, err := strconv.Atoi(command[2])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	removed := 0
+	if ok {
+		limit := count
+		if count < 0 {
+			limit = -count
+			slices.Reverse(l.elements)
+		}
+		l.elements = slices.DeleteFunc(l.elements, func(e string) bool {
+			if e != element || (limit > 0 && removed == limit) {
+				return false
+			}
+			removed += 1
+			return true
+		})
+		if count < 0 {
+			slices.Reverse(l.elements)
+		}
+
+		if removed > 0 {
+			s.usedMemory -= int64(removed) * elementSize(element)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyList, "lrem", key)
+		}
+		if len(l.elements) == 0 {
+			s.deleteKey(key)
+			s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		} else if removed > 0 {
+			s.touchKey(key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(removed))
+}
+
+// handleLtrimCommand serves LTRIM key start stop, which keeps only the
+// elements in the inclusive range, with the same index rules as LRANGE.
+func (s *server) handleLtrimCommand(c *client, command []string) error {
+	key := command[1]
+
+	start, err := strconv.Atoi(command[2])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.Atoi(command[3])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	l, ok, wrongType := lookupTyped[*list](s, key)
+	if ok {
+		lo, hi, empty := normalizeRange(start, stop, len(l.elements))
+		if empty {
+			lo, hi = 0, -1
+		}
+		if lo > 0 || hi < len(l.elements)-1 {
+			for _, element := range l.elements[:lo] {
+				s.usedMemory -= elementSize(element)
+			}
+			for _, element := range l.elements[hi+1:] {
+				s.usedMemory -= elementSize(element)
+			}
+			l.elements = slices.Clone(l.elements[lo : hi+1])
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyList, "ltrim", key)
+			if len(l.elements) == 0 {
+				s.deleteKey(key)
+				s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+			} else {
+				s.touchKey(key)
+			}
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+// moveElement pops an element from the head or the tail of the list at src
+// and pushes it to the head or the tail of the list at dst, creating it if
+// needed. src and dst may be the same key, which rotates the list. ok is
+// false if src does not exist, and wrongType reports that src or dst holds
+// another type, in which case nothing is moved. Callers must hold dbLock for
+// writing.
+func (s *server) moveElement(src string, dst string, fromHead bool, toHead bool) (element string, ok bool, wrongType bool) {
+	s.deleteIfExpired(src)
+	s.deleteIfExpired(dst)
+	l, ok, wrongType := lookupTyped[*list](s, src)
+	if !ok {
+		return "", false, wrongType
+	}
+	if _, _, wrongType := lookupTyped[*list](s, dst); wrongType {
+		return "", false, true
+	}
+
+	element = s.popElements(src, l, fromHead, 1)[0]
+	// Popping the last element deleted src, which may also be dst.
+	d, exists, _ := lookupTyped[*list](s, dst)
+	if !exists {
+		d = &list{}
+		s.storeValue(dst, d)
+	}
+	if toHead {
+		d.elements = slices.Insert(d.elements, 0, element)
+		s.notifyKeyspaceEvent(notifyList, "lpush", dst)
+	} else {
+		d.elements = append(d.elements, element)
+		s.notifyKeyspaceEvent(notifyList, "rpush", dst)
+	}
+	s.usedMemory += elementSize(element)
+	s.touchKey(dst)
+	return element, true, false
+}
+
+// handleRpoplpushCommand serves RPOPLPUSH source destination, which is LMOVE
+// source destination RIGHT LEFT.
+func (s *server) handleRpoplpushCommand(c *client, command []string) error {
+	s.dbLock.Lock()
+	element, ok, wrongType := s.moveElement(command[1], command[2], false, true)
+	if ok {
+		s.propagate(command...)
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case !ok:
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(element)
+}
+
+// handleLmoveCommand serves LMOVE source destination LEFT|RIGHT LEFT|RIGHT,
+// which atomically pops an element from one end of source, pushes it to one
+// end of destination and replies with it, or with null if source is empty.
+func (s *server) handleLmoveCommand(c *client, command []string) error {
+	from := strings.ToUpper(command[3])
+	to := strings.ToUpper(command[4])
+	if (from != "LEFT" && from != "RIGHT") || (to != "LEFT" && to != "RIGHT") {
+		return c.writer.WriteError("ERR syntax error")
+	}
+
+	s.dbLock.Lock()
+	element, ok, wrongType := s.moveElement(command[1], command[2], from == "LEFT", to == "LEFT")
+	if ok {
+		s.propagate(command...)
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case !ok:
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(element)
+}
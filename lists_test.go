@@ -0,0 +1,185 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+func TestList(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a", "b"}, ":2\r\n"},
+		// LPUSH pushes its elements one at a time, so they end up reversed.
+		{[]string{"LPUSH", "list", "x", "y"}, ":4\r\n"},
+		{[]string{"TYPE", "list"}, "+list\r\n"},
+		{[]string{"LLEN", "list"}, ":4\r\n"},
+		{[]string{"LLEN", "missing"}, ":0\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*4\r\n$1\r\ny\r\n$1\r\nx\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"LRANGE", "list", "-2", "-1"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"LRANGE", "list", "-100", "0"}, "*1\r\n$1\r\ny\r\n"},
+		{[]string{"LRANGE", "list", "5", "10"}, "*0\r\n"},
+		{[]string{"LRANGE", "list", "2", "1"}, "*0\r\n"},
+		{[]string{"LRANGE", "missing", "0", "-1"}, "*0\r\n"},
+		{[]string{"LRANGE", "list", "a", "1"}, "-ERR value is not an integer or out of range\r\n"},
+
+		{[]string{"LPOP", "list"}, "$1\r\ny\r\n"},
+		{[]string{"RPOP", "list", "2"}, "*2\r\n$1\r\nb\r\n$1\r\na\r\n"},
+		{[]string{"LPOP", "list", "0"}, "*0\r\n"},
+		{[]string{"LPOP", "list", "-1"}, "-ERR value is out of range, must be positive\r\n"},
+		{[]string{"RPOP", "list", "5"}, "*1\r\n$1\r\nx\r\n"},
+		// Popping the last element deletes the key.
+		{[]string{"EXISTS", "list"}, ":0\r\n"},
+		{[]string{"LPOP", "list"}, "$-1\r\n"},
+		{[]string{"LPOP", "list", "1"}, "*-1\r\n"},
+		{[]string{"LPUSH", "list"}, "-ERR wrong number of arguments for 'lpush' command\r\n"},
+	})
+}
+
+func TestListWrongType(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"LPUSH", "string", "v"}, wrongType},
+		{[]string{"RPUSH", "string", "v"}, wrongType},
+		{[]string{"LPOP", "string"}, wrongType},
+		{[]string{"LLEN", "string"}, wrongType},
+		{[]string{"LRANGE", "string", "0", "-1"}, wrongType},
+		{[]string{"RPUSH", "list", "1"}, ":1\r\n"},
+		{[]string{"GET", "list"}, wrongType},
+		{[]string{"INCR", "list"}, wrongType},
+		{[]string{"APPEND", "list", "x"}, wrongType},
+		// MGET reports keys of other types as missing.
+		{[]string{"MGET", "list", "string"}, "*2\r\n$-1\r\n$1\r\nv\r\n"},
+	})
+}
+
+func TestLpos(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a", "b", "c", "1", "2", "3", "c", "c"}, ":8\r\n"},
+		{[]string{"LPOS", "list", "c"}, ":2\r\n"},
+		{[]string{"LPOS", "list", "x"}, "$-1\r\n"},
+		// RANK skips matches, and a negative one searches from the end.
+		{[]string{"LPOS", "list", "c", "RANK", "2"}, ":6\r\n"},
+		{[]string{"LPOS", "list", "c", "RANK", "-1"}, ":7\r\n"},
+		{[]string{"LPOS", "list", "c", "RANK", "4"}, "$-1\r\n"},
+		// COUNT 0 returns every match.
+		{[]string{"LPOS", "list", "c", "COUNT", "2"}, "*2\r\n:2\r\n:6\r\n"},
+		{[]string{"LPOS", "list", "c", "COUNT", "0"}, "*3\r\n:2\r\n:6\r\n:7\r\n"},
+		{[]string{"LPOS", "list", "c", "RANK", "-1", "COUNT", "2"}, "*2\r\n:7\r\n:6\r\n"},
+		// MAXLEN limits how many elements are compared.
+		{[]string{"LPOS", "list", "c", "COUNT", "0", "MAXLEN", "3"}, "*1\r\n:2\r\n"},
+		{[]string{"LPOS", "list", "x", "COUNT", "1"}, "*0\r\n"},
+		{[]string{"LPOS", "missing", "x"}, "$-1\r\n"},
+		{[]string{"LPOS", "missing", "x", "COUNT", "1"}, "*0\r\n"},
+
+		{[]string{"LPOS", "list", "c", "RANK", "0"}, "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n"},
+		{[]string{"LPOS", "list", "c", "COUNT", "-1"}, "-ERR COUNT can't be negative\r\n"},
+		{[]string{"LPOS", "list", "c", "FOO", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"LPOS", "list", "c", "RANK"}, "-ERR syntax error\r\n"},
+		{[]string{"LPOS", "list", "c", "RANK", "x"}, "-ERR value is not an integer or out of range\r\n"},
+	})
+}
+
+func TestLinsert(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"LINSERT", "list", "BEFORE", "b", "x"}, ":4\r\n"},
+		{[]string{"LINSERT", "list", "after", "c", "y"}, ":5\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*5\r\n$1\r\na\r\n$1\r\nx\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\ny\r\n"},
+		{[]string{"LINSERT", "list", "AFTER", "missing", "z"}, ":-1\r\n"},
+		{[]string{"LINSERT", "missing", "AFTER", "a", "z"}, ":0\r\n"},
+		{[]string{"LINSERT", "list", "MIDDLE", "c", "z"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestLset(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"LSET", "list", "0", "A"}, "+OK\r\n"},
+		{[]string{"LSET", "list", "-1", "C"}, "+OK\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*3\r\n$1\r\nA\r\n$1\r\nb\r\n$1\r\nC\r\n"},
+		{[]string{"LSET", "list", "3", "x"}, "-ERR index out of range\r\n"},
+		{[]string{"LSET", "list", "-4", "x"}, "-ERR index out of range\r\n"},
+		{[]string{"LSET", "missing", "0", "x"}, "-ERR no such key\r\n"},
+	})
+}
+
+func TestLrem(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "x", "a", "x", "b", "x", "c", "x"}, ":7\r\n"},
+		// A positive count removes from the head, a negative one from the
+		// tail and 0 removes every match.
+		{[]string{"LREM", "list", "1", "x"}, ":1\r\n"},
+		{[]string{"LREM", "list", "-2", "x"}, ":2\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*4\r\n$1\r\na\r\n$1\r\nx\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"LREM", "list", "0", "missing"}, ":0\r\n"},
+		{[]string{"LREM", "missing", "0", "x"}, ":0\r\n"},
+		{[]string{"RPUSH", "same", "a", "a", "a"}, ":3\r\n"},
+		{[]string{"LREM", "same", "0", "a"}, ":3\r\n"},
+		{[]string{"EXISTS", "same"}, ":0\r\n"},
+	})
+}
+
+func TestLtrim(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "list", "a", "b", "c", "d", "e"}, ":5\r\n"},
+		{[]string{"LTRIM", "list", "-4", "-2"}, "+OK\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{[]string{"LTRIM", "list", "0", "100"}, "+OK\r\n"},
+		{[]string{"LLEN", "list"}, ":3\r\n"},
+		{[]string{"LTRIM", "missing", "0", "1"}, "+OK\r\n"},
+		// An empty range deletes the key.
+		{[]string{"LTRIM", "list", "5", "10"}, "+OK\r\n"},
+		{[]string{"EXISTS", "list"}, ":0\r\n"},
+	})
+}
+
+func TestLmove(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"RPUSH", "src", "1", "2", "3"}, ":3\r\n"},
+		{[]string{"RPOPLPUSH", "src", "dst"}, "$1\r\n3\r\n"},
+		{[]string{"LMOVE", "src", "dst", "LEFT", "RIGHT"}, "$1\r\n1\r\n"},
+		{[]string{"LRANGE", "dst", "0", "-1"}, "*2\r\n$1\r\n3\r\n$1\r\n1\r\n"},
+		// The source and destination may be the same list, which then
+		// rotates.
+		{[]string{"RPOPLPUSH", "dst", "dst"}, "$1\r\n1\r\n"},
+		{[]string{"LRANGE", "dst", "0", "-1"}, "*2\r\n$1\r\n1\r\n$1\r\n3\r\n"},
+		{[]string{"LMOVE", "src", "src", "left", "right"}, "$1\r\n2\r\n"},
+		// Moving the last element deletes the source.
+		{[]string{"LMOVE", "src", "dst", "LEFT", "LEFT"}, "$1\r\n2\r\n"},
+		{[]string{"EXISTS", "src"}, ":0\r\n"},
+		{[]string{"LRANGE", "dst", "0", "-1"}, "*3\r\n$1\r\n2\r\n$1\r\n1\r\n$1\r\n3\r\n"},
+		{[]string{"RPOPLPUSH", "src", "dst"}, "$-1\r\n"},
+
+		// A destination of another type leaves the source alone.
+		{[]string{"SET", "string", "x"}, "+OK\r\n"},
+		{[]string{"RPOPLPUSH", "dst", "string"}, wrongType},
+		{[]string{"LLEN", "dst"}, ":3\r\n"},
+		{[]string{"LMOVE", "dst", "other", "UP", "LEFT"}, "-ERR syntax error\r\n"},
+	})
+}
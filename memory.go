@@ -0,0 +1,41 @@
+package goredis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// handleMemoryCommand serves MEMORY USAGE key [SAMPLES count], which replies
+// with the estimate of the memory used by key that maxmemory is enforced
+// against, or null if the key does not exist. The estimate always covers
+// every element, so SAMPLES is accepted but has no effect.
+func (s *server) handleMemoryCommand(c *client, command []string) error {
+	if strings.ToUpper(command[1]) != "USAGE" || len(command) < 3 {
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try MEMORY HELP.", command[1]))
+	}
+	key := command[2]
+
+	for i := 3; i < len(command); i += 2 {
+		if strings.ToUpper(command[i]) != "SAMPLES" || i+1 == len(command) {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		if samples, err := strconv.ParseInt(command[i+1], 10, 64); err != nil || samples < 0 {
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+	}
+
+	s.dbLock.RLock()
+	value, ok := s.lookupKey(key)
+	size := int64(0)
+	if ok {
+		size = entrySize(key, value)
+	}
+	s.dbLock.RUnlock()
+
+	if !ok {
+		s.expireKey(key)
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteInteger(size)
+}
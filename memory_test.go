@@ -0,0 +1,65 @@
+package goredis_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		// The key, the value and 64 bytes of overhead.
+		{[]string{"SET", "key", "abc"}, "+OK\r\n"},
+		{[]string{"MEMORY", "USAGE", "key"}, ":70\r\n"},
+		{[]string{"APPEND", "key", "defg"}, ":7\r\n"},
+		{[]string{"MEMORY", "USAGE", "key", "SAMPLES", "0"}, ":74\r\n"},
+		{[]string{"MEMORY", "USAGE", "missing"}, "$-1\r\n"},
+		{[]string{"MEMORY", "USAGE", "key", "SAMPLES"}, "-ERR syntax error\r\n"},
+		{[]string{"MEMORY", "FOO"}, "-ERR unknown subcommand or wrong number of arguments for 'FOO'. Try MEMORY HELP.\r\n"},
+	})
+}
+
+func TestUsedMemory(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// used_memory follows every change to the keyspace, so it is always
+	// the sum of the usage of each key.
+	for _, command := range [][]string{
+		{"ZADD", "zset", "1", "a", "2", "b"},
+		{"ZINCRBY", "zset", "1", "c"},
+		{"ZADD", "zset", "5", "a"},
+		{"HSET", "hash", "a", "1", "b", "22"},
+		{"HSET", "hash", "a", "333"},
+		{"HDEL", "hash", "b"},
+		{"SADD", "set", "a", "b", "c"},
+		{"SREM", "set", "a"},
+		{"SPOP", "set"},
+		{"RPUSH", "list", "a", "b", "c"},
+		{"LPOP", "list"},
+		{"LSET", "list", "0", "bb"},
+		{"SET", "string", "abc"},
+		{"SETRANGE", "string", "5", "x"},
+		{"COPY", "zset", "zset2"},
+		{"RENAME", "hash", "hash2"},
+	} {
+		c.Do(command...)
+	}
+	var sum int64
+	for _, key := range []string{"zset", "zset2", "hash2", "set", "list", "string"} {
+		reply := c.Do("MEMORY", "USAGE", key)
+		n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(reply, ":"), "\r\n"), 10, 64)
+		if err != nil {
+			t.Fatalf("MEMORY USAGE %s = %q", key, reply)
+		}
+		sum += n
+	}
+	if used := infoField(t, c, "memory", "used_memory"); used != sum {
+		t.Errorf("used_memory = %d, want the %d bytes the keys use", used, sum)
+	}
+}
@@ -0,0 +1,98 @@
+package goredis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// handleMonitorCommand serves MONITOR, which turns c into a monitor: it
+// receives a line for every command the server runs, and its own commands
+// other than QUIT are ignored.
+func (s *server) handleMonitorCommand(c *client, command []string) error {
+	if c.isMonitor {
+		return nil
+	}
+	c.isMonitor = true
+	s.monitorsLock.Lock()
+	s.monitors[c] = struct{}{}
+	s.monitorsLock.Unlock()
+	return c.writer.WriteSimpleString("OK")
+}
+
+// removeMonitor stops sending commands to c.
+func (s *server) removeMonitor(c *client) {
+	if !c.isMonitor {
+		return
+	}
+	s.monitorsLock.Lock()
+	delete(s.monitors, c)
+	s.monitorsLock.Unlock()
+}
+
+// feedMonitors sends command, about to be run for c, to every monitor in the
+// format of Redis:
+//
+//	+1700000000.123456 [0 127.0.0.1:50000] "SET" "key" "value"
+//
+// As with published messages, no lock is held while writing to monitors.
+func (s *server) feedMonitors(c *client, command []string) {
+	s.monitorsLock.Lock()
+	monitors := make([]*client, 0, len(s.monitors))
+	for monitor := range s.monitors {
+		monitors = append(monitors, monitor)
+	}
+	s.monitorsLock.Unlock()
+	if len(monitors) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var line strings.Builder
+	fmt.Fprintf(&line, "%d.%06d [%d %s]", now.Unix(), now.Nanosecond()/1000, c.db, c.conn.RemoteAddr())
+	for i, arg := range command {
+		if i > 0 && strings.EqualFold(command[0], "auth") {
+			arg = "(redacted)"
+		}
+		line.WriteByte(' ')
+		quoteArg(&line, arg)
+	}
+
+	for _, monitor := range monitors {
+		// The monitor's own goroutine notices a broken connection and
+		// removes it.
+		if monitor.writer.WriteSimpleString(line.String()) == nil {
+			monitor.writer.Flush()
+		}
+	}
+}
+
+// quoteArg writes arg to line in double quotes, escaping it the way Redis
+// does so that every byte is printable.
+func quoteArg(line *strings.Builder, arg string) {
+	line.WriteByte('"')
+	for i := 0; i < len(arg); i += 1 {
+		switch b := arg[i]; b {
+		case '\\', '"':
+			line.WriteByte('\\')
+			line.WriteByte(b)
+		case '\n':
+			line.WriteString(`\n`)
+		case '\r':
+			line.WriteString(`\r`)
+		case '\t':
+			line.WriteString(`\t`)
+		case '\a':
+			line.WriteString(`\a`)
+		case '\b':
+			line.WriteString(`\b`)
+		default:
+			if b < 0x20 || b >= 0x7f {
+				fmt.Fprintf(line, `\x%02x`, b)
+			} else {
+				line.WriteByte(b)
+			}
+		}
+	}
+	line.WriteByte('"')
+}
@@ -0,0 +1,50 @@
+package goredis_test
+
+import (
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestMonitor(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	monitor := redistest.Connect(t, addr)
+	c := redistest.Connect(t, addr)
+
+	run(t, monitor, []step{{[]string{"MONITOR"}, "+OK\r\n"}})
+	run(t, c, []step{
+		{[]string{"SET", "key", "a \"b\"\n\x01"}, "+OK\r\n"},
+		{[]string{"AUTH", "password"}, "-ERR Client sent AUTH, but no password is set\r\n"},
+	})
+
+	// Each line holds the time, the database and the client's address, and
+	// the arguments quoted so that they are printable. Passwords are
+	// hidden.
+	prefix := `^\+\d+\.\d{6} \[0 ` + regexp.QuoteMeta(c.Conn().LocalAddr().String()) + `\] `
+	for _, want := range []string{
+		prefix + `"SET" "key" "a \\"b\\"\\n\\x01"\r\n$`,
+		prefix + `"AUTH" "\(redacted\)"\r\n$`,
+	} {
+		if line := monitor.Receive(); !regexp.MustCompile(want).MatchString(line) {
+			t.Errorf("monitor received %q, want it to match %q", line, want)
+		}
+	}
+
+	// The monitor's own commands are ignored, so the next line it reads
+	// is for the PING of the other client.
+	monitor.Send("GET", "key")
+	run(t, c, []step{{[]string{"PING"}, "+PONG\r\n"}})
+	if line, want := monitor.Receive(), prefix+`"PING"\r\n$`; !regexp.MustCompile(want).MatchString(line) {
+		t.Errorf("monitor received %q, want it to match %q", line, want)
+	}
+
+	run(t, monitor, []step{{[]string{"QUIT"}, "+OK\r\n"}})
+	monitor.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := monitor.Conn().Read(make([]byte, 64)); err != io.EOF {
+		t.Errorf("read %d bytes, %v after QUIT, want EOF", n, err)
+	}
+	run(t, c, []step{{[]string{"GET", "missing"}, "$-1\r\n"}})
+}
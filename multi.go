@@ -0,0 +1,177 @@
+package goredis
+
+import (
+	"strings"
+)
+
+// watchedKey tracks a key that at least one client is watching. version is
+// bumped by every change to the key.
+type watchedKey struct {
+	version  int64
+	watchers int
+}
+
+// touchKey records that key was modified, which makes transactions of
+// clients watching it abort and wakes clients blocked on it. Every write to
+// the keyspace calls it. Callers must hold dbLock for writing.
+func (s *server) touchKey(key string) {
+	if w, ok := s.watchedKeys[key]; ok {
+		w.version += 1
+	}
+	s.signalBlocked(key)
+}
+
+// touchAllKeys is touchKey for every watched key, used when the whole
+// keyspace is replaced. Callers must hold dbLock for writing.
+func (s *server) touchAllKeys() {
+	for _, w := range s.watchedKeys {
+		w.version += 1
+	}
+}
+
+// handleWatchCommand serves WATCH key [key ...], which makes the next EXEC
+// of the connection abort if any of the keys is modified in the meantime.
+// Inside MULTI it is an error that aborts the transaction.
+func (s *server) handleWatchCommand(c *client, command []string) error {
+	if c.inMulti {
+		c.multiFailed = true
+		return c.writer.WriteError("ERR WATCH inside MULTI is not allowed")
+	}
+
+	s.dbLock.Lock()
+	for _, key := range command[1:] {
+		if _, ok := c.watching[key]; ok {
+			continue
+		}
+		w, ok := s.watchedKeys[key]
+		if !ok {
+			w = &watchedKey{}
+			s.watchedKeys[key] = w
+		}
+		w.watchers += 1
+		c.watching[key] = w.version
+	}
+	s.dbLock.Unlock()
+
+	return c.writer.WriteSimpleString("OK")
+}
+
+func (s *server) handleUnwatchCommand(c *client, command []string) error {
+	s.unwatchAll(c)
+	return c.writer.WriteSimpleString("OK")
+}
+
+// watchedKeysModified reports whether any key c watches changed since the
+// WATCH. Callers must hold dbLock.
+func (s *server) watchedKeysModified(c *client) bool {
+	for key, version := range c.watching {
+		if s.watchedKeys[key].version != version {
+			return true
+		}
+	}
+	return false
+}
+
+// unwatchAll forgets every key c watches. handleConn calls it when the
+// connection ends.
+func (s *server) unwatchAll(c *client) {
+	if len(c.watching) == 0 {
+		return
+	}
+	s.dbLock.Lock()
+	s.unwatchKeys(c)
+	s.dbLock.Unlock()
+}
+
+// unwatchKeys is unwatchAll for callers that hold dbLock for writing.
+func (s *server) unwatchKeys(c *client) {
+	for key := range c.watching {
+		w := s.watchedKeys[key]
+		w.watchers -= 1
+		if w.watchers == 0 {
+			delete(s.watchedKeys, key)
+		}
+	}
+	clear(c.watching)
+}
+
+func (s *server) handleMultiCommand(c *client, command []string) error {
+	if c.inMulti {
+		return c.writer.WriteError("ERR MULTI calls can not be nested")
+	}
+	c.inMulti = true
+	return c.writer.WriteSimpleString("OK")
+}
+
+// queueCommand adds command to the transaction of c. dispatch has already
+// rejected commands that could never run.
+func (s *server) queueCommand(c *client, command []string) error {
+	c.queued = append(c.queued, command)
+	return c.writer.WriteSimpleString("QUEUED")
+}
+
+// handleExecCommand runs the queued commands while holding execLock for
+// writing, so other clients see the transaction as a single step, and
+// replies with an array of their replies.
+func (s *server) handleExecCommand(c *client, command []string) error {
+	if !c.inMulti {
+		return c.writer.WriteError("ERR EXEC without MULTI")
+	}
+	queued, failed := c.queued, c.multiFailed
+	discardTransaction(c)
+	if failed {
+		s.unwatchAll(c)
+		return c.writer.WriteError("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	s.execLock.Lock()
+	defer s.execLock.Unlock()
+
+	s.dbLock.Lock()
+	modified := s.watchedKeysModified(c)
+	s.unwatchKeys(c)
+	s.inExec = !modified
+	s.dbLock.Unlock()
+	if modified {
+		return c.writer.WriteNullArray()
+	}
+	defer func() {
+		s.dbLock.Lock()
+		if s.multiPropagated {
+			s.writePropagated([]string{"EXEC"})
+		}
+		s.inExec = false
+		s.multiPropagated = false
+		s.dbLock.Unlock()
+	}()
+
+	// Messages published meanwhile, including by the transaction itself,
+	// follow the reply instead of landing between its elements.
+	c.writer.HoldMessages()
+	defer c.writer.ReleaseMessages()
+	if err := c.writer.WriteArrayHeader(len(queued)); err != nil {
+		return err
+	}
+	for _, queuedCommand := range queued {
+		if err := s.call(c, strings.ToUpper(queuedCommand[0]), queuedCommand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *server) handleDiscardCommand(c *client, command []string) error {
+	if !c.inMulti {
+		return c.writer.WriteError("ERR DISCARD without MULTI")
+	}
+	discardTransaction(c)
+	s.unwatchAll(c)
+	return c.writer.WriteSimpleString("OK")
+}
+
+// discardTransaction leaves MULTI and drops the queued commands.
+func discardTransaction(c *client) {
+	c.inMulti = false
+	c.queued = nil
+	c.multiFailed = false
+}
@@ -0,0 +1,118 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestTransaction(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"SET", "key", "1"}, "+QUEUED\r\n"},
+		{[]string{"INCR", "key"}, "+QUEUED\r\n"},
+		{[]string{"GET", "key"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*3\r\n+OK\r\n:2\r\n$1\r\n2\r\n"},
+	})
+}
+
+func TestTransactionAbort(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	tests := []struct {
+		name  string
+		error []string
+		want  string
+	}{
+		{"unknown command", []string{"NOSUCHCOMMAND"}, "-ERR unknown command 'NOSUCHCOMMAND', with args beginning with: \r\n"},
+		{"wrong arity", []string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{"watch", []string{"WATCH", "key"}, "-ERR WATCH inside MULTI is not allowed\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			run(t, c, []step{
+				{[]string{"MULTI"}, "+OK\r\n"},
+				{[]string{"SET", "key", "1"}, "+QUEUED\r\n"},
+				{test.error, test.want},
+				{[]string{"EXEC"}, "-EXECABORT Transaction discarded because of previous errors.\r\n"},
+				{[]string{"EXISTS", "key"}, ":0\r\n"},
+			})
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	other := redistest.Connect(t, addr)
+
+	// A write to a watched key by another client aborts EXEC.
+	run(t, c, []step{
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"WATCH", "a", "b"}, "+OK\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"INCR", "a"}, "+QUEUED\r\n"},
+	})
+	run(t, other, []step{{[]string{"SET", "b", "x"}, "+OK\r\n"}})
+	run(t, c, []step{
+		{[]string{"EXEC"}, "*-1\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+	})
+
+	// EXEC unwatches every key, whether or not it ran.
+	run(t, c, []step{
+		{[]string{"WATCH", "a"}, "+OK\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"INCR", "a"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n:2\r\n"},
+	})
+	run(t, other, []step{{[]string{"SET", "a", "x"}, "+OK\r\n"}})
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"GET", "a"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n$1\r\nx\r\n"},
+	})
+
+	// So do UNWATCH and DISCARD.
+	run(t, c, []step{
+		{[]string{"WATCH", "a"}, "+OK\r\n"},
+		{[]string{"UNWATCH"}, "+OK\r\n"},
+	})
+	run(t, other, []step{{[]string{"SET", "a", "y"}, "+OK\r\n"}})
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"EXEC"}, "*0\r\n"},
+		{[]string{"WATCH", "a"}, "+OK\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"DISCARD"}, "+OK\r\n"},
+	})
+	run(t, other, []step{{[]string{"SET", "a", "z"}, "+OK\r\n"}})
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"EXEC"}, "*0\r\n"},
+	})
+
+	// Deleting a watched key counts as a write, and so does FLUSHALL.
+	for _, write := range [][]string{{"DEL", "a"}, {"FLUSHALL"}} {
+		c.Do("SET", "a", "1")
+		run(t, c, []step{
+			{[]string{"WATCH", "a"}, "+OK\r\n"},
+			{[]string{"MULTI"}, "+OK\r\n"},
+		})
+		other.Do(write...)
+		run(t, c, []step{{[]string{"EXEC"}, "*-1\r\n"}})
+	}
+
+	// A client's own writes before MULTI abort its transaction too.
+	run(t, c, []step{
+		{[]string{"WATCH", "a"}, "+OK\r\n"},
+		{[]string{"SET", "a", "2"}, "+OK\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"EXEC"}, "*-1\r\n"},
+		{[]string{"WATCH"}, "-ERR wrong number of arguments for 'watch' command\r\n"},
+	})
+}
@@ -0,0 +1,170 @@
+package goredis
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// keyspaceEvents is a set of notification classes, as configured by
+// notify-keyspace-events.
+type keyspaceEvents int
+
+const (
+	notifyKeyspace keyspaceEvents = 1 << iota // K: __keyspace@0__:<key>
+	notifyKeyevent                            // E: __keyevent@0__:<event>
+	notifyGeneric                             // g: DEL, EXPIRE, RENAME, ...
+	notifyString                              // $
+	notifyList                                // l
+	notifySet                                 // s
+	notifyHash                                // h
+	notifyZset                                // z
+	notifyExpired                             // x: a key reached its expiration time
+	notifyEvicted                             // e: a key was evicted for maxmemory
+	notifyNew                                 // n: a key was created, not part of A
+
+	// notifyAll is the A alias.
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZset | notifyExpired | notifyEvicted
+)
+
+// Keyspace notifications are never dropped. Instead, once more than
+// notificationQueueSize wait for notifyCycle while it has been writing to
+// the same subscriber for notificationStallTimeout, that subscriber is
+// disconnected, like a client over its output buffer limit in Redis.
+const (
+	notificationQueueSize    = 1024
+	notificationStallTimeout = time.Second
+)
+
+// keyspaceEventFlags maps the characters of notify-keyspace-events to
+// classes, in the order they are printed.
+var keyspaceEventFlags = []struct {
+	flag  byte
+	class keyspaceEvents
+}{
+	{'g', notifyGeneric},
+	{'$', notifyString},
+	{'l', notifyList},
+	{'s', notifySet},
+	{'h', notifyHash},
+	{'z', notifyZset},
+	{'x', notifyExpired},
+	{'e', notifyEvicted},
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+	{'n', notifyNew},
+}
+
+// parseKeyspaceEvents parses a notify-keyspace-events value such as KEA.
+func parseKeyspaceEvents(value string) (keyspaceEvents, bool) {
+	events := keyspaceEvents(0)
+	for i := 0; i < len(value); i += 1 {
+		if value[i] == 'A' {
+			events |= notifyAll
+			continue
+		}
+		found := false
+		for _, f := range keyspaceEventFlags {
+			if f.flag == value[i] {
+				events |= f.class
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return events, true
+}
+
+// String formats events the way CONFIG GET reports them, using A when all
+// of its classes are set.
+func (events keyspaceEvents) String() string {
+	var flags strings.Builder
+	if events&notifyAll == notifyAll {
+		flags.WriteByte('A')
+		events &^= notifyAll
+	}
+	for _, f := range keyspaceEventFlags {
+		if events&f.class != 0 {
+			flags.WriteByte(f.flag)
+		}
+	}
+	return flags.String()
+}
+
+// notification is a message for notifyCycle to publish.
+type notification struct {
+	channel string
+	message string
+}
+
+// notifyKeyspaceEvent publishes that event of class happened to key, if
+// notify-keyspace-events enables it. Handlers call it while still holding
+// dbLock for writing, like propagate, so subscribers see events in the
+// order they happened. The messages are published by notifyCycle, so no
+// lock is held while writing to subscribers.
+func (s *server) notifyKeyspaceEvent(class keyspaceEvents, event string, key string) {
+	events := s.getConfig().notifyKeyspaceEvents
+	if events&class == 0 {
+		return
+	}
+	if events&notifyKeyspace != 0 {
+		s.queueNotification(notification{"__keyspace@0__:" + key, event})
+	}
+	if events&notifyKeyevent != 0 {
+		s.queueNotification(notification{"__keyevent@0__:" + event, key})
+	}
+}
+
+// queueNotification hands n to notifyCycle. Writers hold dbLock, so rather
+// than wait for a subscriber that stopped reading, which keeps notifyCycle
+// from catching up, it disconnects it.
+func (s *server) queueNotification(n notification) {
+	s.notificationsLock.Lock()
+	s.notifications = append(s.notifications, n)
+	if len(s.notifications) > notificationQueueSize && s.notifying != nil && time.Since(s.notifyingSince) > notificationStallTimeout {
+		s.logger.Debug("notifications are not read, disconnecting subscriber", slog.Int64("clientId", s.notifying.id))
+		s.outputLimitDisconnections.Add(1)
+		s.notifying.conn.Close()
+		s.notifying = nil
+	}
+	s.notificationsLock.Unlock()
+
+	select {
+	case s.notificationsReady <- struct{}{}:
+	default:
+		// notifyCycle has a wakeup pending already.
+	}
+}
+
+// notifyCycle publishes queued keyspace notifications until the server
+// stops.
+func (s *server) notifyCycle() {
+	defer s.background.Done()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.notificationsReady:
+		}
+		s.notificationsLock.Lock()
+		notifications := s.notifications
+		s.notifications = nil
+		s.notificationsLock.Unlock()
+		for _, n := range notifications {
+			s.publish(nil, n.channel, n.message)
+		}
+	}
+}
+
+// setNotifying records that notifyCycle is writing to receiver, or to no
+// one when receiver is nil.
+func (s *server) setNotifying(receiver *client) {
+	s.notificationsLock.Lock()
+	s.notifying = receiver
+	s.notifyingSince = time.Now()
+	s.notificationsLock.Unlock()
+}
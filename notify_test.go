@@ -0,0 +1,91 @@
+package goredis_test
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestKeyeventNotification(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+
+	run(t, c, []step{{[]string{"CONFIG", "SET", "notify-keyspace-events", "E$"}, "+OK\r\n"}})
+	run(t, subscriber, []step{
+		{[]string{"SUBSCRIBE", "__keyevent@0__:set"}, "*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@0__:set\r\n:1\r\n"},
+	})
+	run(t, c, []step{
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		// Other events are not published to the channel.
+		{[]string{"APPEND", "key", "value"}, ":10\r\n"},
+		{[]string{"SET", "other", "value"}, "+OK\r\n"},
+	})
+	for _, key := range []string{"key", "other"} {
+		want := "*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:set\r\n$" + strconv.Itoa(len(key)) + "\r\n" + key + "\r\n"
+		if got := subscriber.Receive(); got != want {
+			t.Errorf("message = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockWrites(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+
+	run(t, c, []step{{[]string{"CONFIG", "SET", "notify-keyspace-events", "E$"}, "+OK\r\n"}})
+	run(t, subscriber, []step{
+		{[]string{"SUBSCRIBE", "__keyevent@0__:set"}, "*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@0__:set\r\n:1\r\n"},
+	})
+
+	// The subscriber never reads, so the messages with these long keys fill
+	// the socket buffers and then the notification queue, until the
+	// subscriber is disconnected.
+	prefix := strings.Repeat("k", 16*1024)
+	start := time.Now()
+	for i := 0; !strings.Contains(c.Do("INFO", "stats"), "client_output_buffer_limit_disconnections:1\r\n"); i++ {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the subscriber was not disconnected")
+		}
+		if got := c.Do("SET", prefix+strconv.Itoa(i), "value"); got != "+OK\r\n" {
+			t.Fatalf("SET = %q", got)
+		}
+	}
+	subscriber.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, subscriber.Conn()); err != nil {
+		t.Errorf("the subscriber is still connected: %v", err)
+	}
+}
+
+func TestNotificationsAreNotDropped(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	subscriber := redistest.Connect(t, addr)
+
+	run(t, c, []step{{[]string{"CONFIG", "SET", "notify-keyspace-events", "E$"}, "+OK\r\n"}})
+	run(t, subscriber, []step{
+		{[]string{"SUBSCRIBE", "__keyevent@0__:set"}, "*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@0__:set\r\n:1\r\n"},
+	})
+
+	// A burst of writes larger than the notification queue reaches a
+	// subscriber that keeps reading, all of it and in order.
+	const writes = 5000
+	for i := range writes {
+		c.Send("SET", strconv.Itoa(i), "value")
+	}
+	for i := range writes {
+		key := strconv.Itoa(i)
+		want := "*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:set\r\n$" + strconv.Itoa(len(key)) + "\r\n" + key + "\r\n"
+		if got := subscriber.Receive(); got != want {
+			t.Fatalf("message %d = %q, want %q", i, got, want)
+		}
+	}
+	for range writes {
+		c.Receive()
+	}
+}
@@ -0,0 +1,140 @@
+package goredis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The thresholds below which Redis keeps values in a compact encoding, which
+// OBJECT ENCODING mimics.
+const (
+	embstrSizeLimit    = 44
+	listpackEntriesMax = 128
+	listpackValueMax   = 64
+	intsetEntriesMax   = 512
+)
+
+// defaultListMaxListpackSize is the default list-max-listpack-size. As in
+// Redis, a positive size limits the entries of a list kept in a listpack,
+// and a negative one its bytes: -1 stands for 4kb, -2 for 8kb and so on up
+// to -5 for 64kb.
+const defaultListMaxListpackSize = -2
+
+// encodingName returns the encoding Redis would use for value under config.
+// The server stores every value the same way regardless of size, but
+// clients and test suites inspect the encoding.
+func encodingName(value any, config serverConfig) string {
+	switch value := value.(type) {
+	case string:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value {
+			return "int"
+		}
+		if len(value) <= embstrSizeLimit {
+			return "embstr"
+		}
+		return "raw"
+	case *list:
+		if listFitsListpack(value.elements, config.listMaxListpackSize, config.listMaxListpackValue) {
+			return "listpack"
+		}
+		return "quicklist"
+	case hash:
+		values := make([]string, 0, 2*len(value))
+		for field, fieldValue := range value {
+			values = append(values, field, fieldValue)
+		}
+		if fitsListpack(len(value), values) {
+			return "listpack"
+		}
+		return "hashtable"
+	case set:
+		members := make([]string, 0, len(value))
+		integers := true
+		for member := range value {
+			members = append(members, member)
+			if n, err := strconv.ParseInt(member, 10, 64); err != nil || strconv.FormatInt(n, 10) != member {
+				integers = false
+			}
+		}
+		if integers && len(value) <= intsetEntriesMax {
+			return "intset"
+		}
+		if fitsListpack(len(value), members) {
+			return "listpack"
+		}
+		return "hashtable"
+	case *sortedSet:
+		members := make([]string, 0, len(value.sorted))
+		for _, m := range value.sorted {
+			members = append(members, m.member)
+		}
+		if fitsListpack(len(value.sorted), members) {
+			return "listpack"
+		}
+		return "skiplist"
+	default:
+		panic(fmt.Sprintf("unknown value type %T", value))
+	}
+}
+
+// fitsListpack reports whether a collection of n entries made of values is
+// small enough for a listpack.
+func fitsListpack(n int, values []string) bool {
+	if n > listpackEntriesMax {
+		return false
+	}
+	for _, value := range values {
+		if len(value) > listpackValueMax {
+			return false
+		}
+	}
+	return true
+}
+
+// listFitsListpack reports whether a list of elements fits in a listpack
+// whose size is limited by size, in the list-max-listpack-size convention,
+// and whose elements are at most valueMax bytes. The bytes of a listpack
+// are estimated as in Redis: a 7 byte header and terminator, and the
+// elements with 2 bytes each for their length.
+func listFitsListpack(elements []string, size int, valueMax int) bool {
+	if size > 0 && len(elements) > size {
+		return false
+	}
+	bytes := 7
+	for _, element := range elements {
+		if len(element) > valueMax {
+			return false
+		}
+		bytes += len(element) + 2
+	}
+	return size > 0 || bytes <= 4096<<(-size-1)
+}
+
+// handleObjectCommand serves OBJECT ENCODING key and OBJECT REFCOUNT key.
+// Values are never shared, so the reference count is always 1.
+func (s *server) handleObjectCommand(c *client, command []string) error {
+	subcommand := strings.ToUpper(command[1])
+	if (subcommand != "ENCODING" && subcommand != "REFCOUNT") || len(command) != 3 {
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", command[1]))
+	}
+	key := command[2]
+
+	config := s.getConfig()
+	s.dbLock.RLock()
+	value, ok := s.lookupKey(key)
+	encoding := ""
+	if ok {
+		encoding = encodingName(value, config)
+	}
+	s.dbLock.RUnlock()
+
+	if !ok {
+		s.expireKey(key)
+		return c.writer.WriteError("ERR no such key")
+	}
+	if subcommand == "REFCOUNT" {
+		return c.writer.WriteInteger(1)
+	}
+	return c.writer.WriteBulkString(encoding)
+}
@@ -0,0 +1,104 @@
+package goredis_test
+
+import (
+	"strings"
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestObject(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "int", "123"}, "+OK\r\n"},
+		{[]string{"OBJECT", "ENCODING", "int"}, "$3\r\nint\r\n"},
+		// Only integers printed the canonical way are stored as integers.
+		{[]string{"SET", "int", "0123"}, "+OK\r\n"},
+		{[]string{"OBJECT", "encoding", "int"}, "$6\r\nembstr\r\n"},
+		{[]string{"SET", "string", strings.Repeat("x", 44)}, "+OK\r\n"},
+		{[]string{"OBJECT", "ENCODING", "string"}, "$6\r\nembstr\r\n"},
+		{[]string{"APPEND", "string", "x"}, ":45\r\n"},
+		{[]string{"OBJECT", "ENCODING", "string"}, "$3\r\nraw\r\n"},
+
+		{[]string{"SADD", "set", "1", "2"}, ":2\r\n"},
+		{[]string{"OBJECT", "ENCODING", "set"}, "$6\r\nintset\r\n"},
+		{[]string{"SADD", "set", "a"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "set"}, "$8\r\nlistpack\r\n"},
+		{[]string{"SADD", "set", strings.Repeat("x", 65)}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "set"}, "$9\r\nhashtable\r\n"},
+		{[]string{"HSET", "hash", "field", "value"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "hash"}, "$8\r\nlistpack\r\n"},
+		{[]string{"HSET", "hash", "field", strings.Repeat("x", 65)}, ":0\r\n"},
+		{[]string{"OBJECT", "ENCODING", "hash"}, "$9\r\nhashtable\r\n"},
+		{[]string{"ZADD", "zset", "1", "a"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "zset"}, "$8\r\nlistpack\r\n"},
+		{[]string{"ZADD", "zset", "2", strings.Repeat("x", 65)}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "zset"}, "$8\r\nskiplist\r\n"},
+
+		// Values are never shared.
+		{[]string{"OBJECT", "REFCOUNT", "int"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "missing"}, "-ERR no such key\r\n"},
+		{[]string{"OBJECT", "REFCOUNT", "missing"}, "-ERR no such key\r\n"},
+		{[]string{"OBJECT", "FOO", "int"}, "-ERR unknown subcommand or wrong number of arguments for 'FOO'. Try OBJECT HELP.\r\n"},
+		{[]string{"OBJECT", "ENCODING"}, "-ERR unknown subcommand or wrong number of arguments for 'ENCODING'. Try OBJECT HELP.\r\n"},
+	})
+}
+
+func TestListEncoding(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"CONFIG", "GET", "list-max-listpack-*"}, "*4\r\n$22\r\nlist-max-listpack-size\r\n$2\r\n-2\r\n$23\r\nlist-max-listpack-value\r\n$2\r\n64\r\n"},
+		{[]string{"RPUSH", "list", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$8\r\nlistpack\r\n"},
+
+		// A positive size limits the number of entries.
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "4"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "d"}, ":4\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$8\r\nlistpack\r\n"},
+		{[]string{"RPUSH", "list", "e"}, ":5\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$9\r\nquicklist\r\n"},
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "5"}, "+OK\r\n"},
+		{[]string{"LPUSH", "list", "z"}, ":6\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$9\r\nquicklist\r\n"},
+		{[]string{"LPOP", "list"}, "$1\r\nz\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$8\r\nlistpack\r\n"},
+
+		// So does list-max-listpack-value with the size of elements.
+		{[]string{"CONFIG", "SET", "list-max-listpack-value", "3"}, "+OK\r\n"},
+		{[]string{"RPUSH", "other", "abc"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "other"}, "$8\r\nlistpack\r\n"},
+		{[]string{"RPUSH", "other", "abcd"}, ":2\r\n"},
+		{[]string{"OBJECT", "ENCODING", "other"}, "$9\r\nquicklist\r\n"},
+
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "0"}, "-ERR CONFIG SET failed (possibly related to argument 'list-max-listpack-size') - argument must be a positive integer or between -5 and -1\r\n"},
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "-6"}, "-ERR CONFIG SET failed (possibly related to argument 'list-max-listpack-size') - argument must be a positive integer or between -5 and -1\r\n"},
+		{[]string{"CONFIG", "SET", "list-max-listpack-value", "-1"}, "-ERR CONFIG SET failed (possibly related to argument 'list-max-listpack-value') - argument must be a non-negative integer\r\n"},
+	})
+}
+
+func TestListEncodingBySize(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// With -1, a listpack holds at most 4kb: 7 bytes of header and 2 bytes
+	// per element on top of the elements themselves.
+	element := strings.Repeat("x", 40)
+	run(t, c, []step{
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "-1", "list-max-listpack-value", "100"}, "+OK\r\n"},
+	})
+	for range (4096 - 7) / 42 {
+		c.Do("RPUSH", "list", element)
+	}
+	run(t, c, []step{
+		{[]string{"OBJECT", "ENCODING", "list"}, "$8\r\nlistpack\r\n"},
+		{[]string{"RPUSH", "list", element}, ":98\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$9\r\nquicklist\r\n"},
+		// -2 doubles the limit.
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "-2"}, "+OK\r\n"},
+		{[]string{"OBJECT", "ENCODING", "list"}, "$8\r\nlistpack\r\n"},
+	})
+}
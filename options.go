@@ -0,0 +1,144 @@
+package goredis
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// Option configures optional server settings in NewServer.
+type Option func(*server)
+
+// WithIdleTimeout closes client connections that send nothing for the given
+// duration. Zero disables the timeout.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(s *server) {
+		s.config.idleTimeout = timeout
+	}
+}
+
+// WithMaxClients limits the number of simultaneously connected clients.
+// Connections beyond the limit are sent an error and closed.
+func WithMaxClients(maxClients int) Option {
+	return func(s *server) {
+		s.config.maxClients = maxClients
+	}
+}
+
+// WithMaxMemory limits the memory used by the keyspace to the given number
+// of bytes. Zero means no limit.
+func WithMaxMemory(bytes int64) Option {
+	return func(s *server) {
+		s.config.maxMemory = bytes
+	}
+}
+
+// WithMaxMemoryPolicy sets what happens when the memory limit is reached.
+// The default is NoEviction.
+func WithMaxMemoryPolicy(policy EvictionPolicy) Option {
+	return func(s *server) {
+		s.config.maxMemoryPolicy = policy
+	}
+}
+
+// WithNotifyKeyspaceEvents enables keyspace notifications for the event
+// classes in events, written like the notify-keyspace-events directive of
+// Redis, for example "KEA". Invalid classes disable notifications.
+func WithNotifyKeyspaceEvents(events string) Option {
+	return func(s *server) {
+		s.config.notifyKeyspaceEvents, _ = parseKeyspaceEvents(events)
+	}
+}
+
+// WithSlowlog adds commands that run longer than logSlowerThan to the slow
+// log, which keeps the maxLen most recent ones. A negative logSlowerThan
+// disables the slow log.
+func WithSlowlog(logSlowerThan time.Duration, maxLen int) Option {
+	return func(s *server) {
+		s.config.slowlogLogSlowerThan = logSlowerThan
+		s.config.slowlogMaxLen = maxLen
+	}
+}
+
+// WithShutdownTimeout sets how long Stop waits for clients to finish the
+// command they are running before closing their connections.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithUnixSocket also accepts connections on a Unix socket at path. A stale
+// socket file left at path is replaced, and the file is removed on Stop.
+func WithUnixSocket(path string) Option {
+	return func(s *server) {
+		s.unixSocketPath = path
+	}
+}
+
+// WithTLS encrypts the connections accepted by the listener passed to
+// NewServer with config, which must hold the server certificate. Set
+// config.ClientAuth and config.ClientCAs to require client certificates.
+// The Unix socket, if any, is not encrypted.
+func WithTLS(config *tls.Config) Option {
+	return func(s *server) {
+		s.listener = tls.NewListener(s.listener, config)
+	}
+}
+
+// WithReplicaOf makes the server a read only replica of the master at host
+// and port once started.
+func WithReplicaOf(host string, port string) Option {
+	return func(s *server) {
+		s.masterHost = host
+		s.masterPort = port
+	}
+}
+
+// WithReplBacklogSize sets how many bytes of recent write commands are kept
+// for replicas that reconnect, so they can continue where they left off
+// instead of loading a snapshot again.
+func WithReplBacklogSize(bytes int) Option {
+	return func(s *server) {
+		s.config.replBacklogSize = bytes
+	}
+}
+
+// WithPassword requires clients to AUTH with password before running other
+// commands. An empty password disables authentication.
+func WithPassword(password string) Option {
+	return func(s *server) {
+		s.password = password
+	}
+}
+
+// WithDebugCommand allows the DEBUG command, which can stall the server and
+// expose its internals. As in Redis, it is refused by default.
+func WithDebugCommand(enabled bool) Option {
+	return func(s *server) {
+		s.debugEnabled = enabled
+	}
+}
+
+// WithSnapshotFile sets the file SAVE and BGSAVE write the keyspace to. The
+// snapshot is loaded from it when the server starts, if it exists.
+func WithSnapshotFile(path string) Option {
+	return func(s *server) {
+		s.snapshotPath = path
+	}
+}
+
+// WithAppendOnlyFile logs every write command to path and replays it when
+// the server starts, instead of loading the snapshot.
+func WithAppendOnlyFile(path string) Option {
+	return func(s *server) {
+		s.appendOnlyPath = path
+	}
+}
+
+// WithAppendFsync sets how often the append only file is synced to disk. The
+// default is FsyncEverysec.
+func WithAppendFsync(policy FsyncPolicy) Option {
+	return func(s *server) {
+		s.config.appendFsync = policy
+	}
+}
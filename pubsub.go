@@ -0,0 +1,230 @@
+package goredis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// subscriberTimeout is how long a subscriber may take to accept a message
+// before it is disconnected.
+const subscriberTimeout = 10 * time.Second
+
+// subscriptionCount returns how many channels and patterns c is subscribed
+// to, which is the count reported in subscribe and unsubscribe replies.
+// Callers must hold pubsubLock.
+func (c *client) subscriptionCount() int64 {
+	return int64(len(c.channels) + len(c.patterns))
+}
+
+// isSubscribed reports whether c has any subscriptions.
+func (s *server) isSubscribed(c *client) bool {
+	s.pubsubLock.Lock()
+	defer s.pubsubLock.Unlock()
+	return c.subscriptionCount() > 0
+}
+
+// inSubscriberMode reports whether c may only run pub/sub commands. RESP3
+// connections can mix messages and replies, so the restriction only applies
+// to RESP2.
+func (s *server) inSubscriberMode(c *client) bool {
+	return c.writer.Protocol() != 3 && s.isSubscribed(c)
+}
+
+// handleSubscribeCommand serves SUBSCRIBE channel [channel ...].
+func (s *server) handleSubscribeCommand(c *client, command []string) error {
+	return s.subscribe(c, "subscribe", s.channels, c.channels, command[1:])
+}
+
+// handlePsubscribeCommand serves PSUBSCRIBE pattern [pattern ...], where
+// patterns are glob-style like in KEYS.
+func (s *server) handlePsubscribeCommand(c *client, command []string) error {
+	return s.subscribe(c, "psubscribe", s.patterns, c.patterns, command[1:])
+}
+
+// handleUnsubscribeCommand serves UNSUBSCRIBE [channel ...]. Without
+// arguments it unsubscribes from every channel.
+func (s *server) handleUnsubscribeCommand(c *client, command []string) error {
+	return s.unsubscribe(c, "unsubscribe", s.channels, c.channels, command[1:])
+}
+
+// handlePunsubscribeCommand serves PUNSUBSCRIBE [pattern ...]. Without
+// arguments it unsubscribes from every pattern.
+func (s *server) handlePunsubscribeCommand(c *client, command []string) error {
+	return s.unsubscribe(c, "punsubscribe", s.patterns, c.patterns, command[1:])
+}
+
+// subscribe adds c to registry, the server-wide channel or pattern map, and
+// to its own subscriptions, replying with a kind message for each name.
+func (s *server) subscribe(c *client, kind string, registry map[string]map[*client]struct{}, subscriptions map[string]struct{}, names []string) error {
+	s.pubsubLock.Lock()
+	defer s.pubsubLock.Unlock()
+	for _, name := range names {
+		if _, ok := subscriptions[name]; !ok {
+			subscriptions[name] = struct{}{}
+			if registry[name] == nil {
+				registry[name] = make(map[*client]struct{})
+			}
+			registry[name][c] = struct{}{}
+		}
+		if err := c.writer.WritePush([]any{kind, name, c.subscriptionCount()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsubscribe is the reverse of subscribe. No names means every name in
+// subscriptions.
+func (s *server) unsubscribe(c *client, kind string, registry map[string]map[*client]struct{}, subscriptions map[string]struct{}, names []string) error {
+	s.pubsubLock.Lock()
+	defer s.pubsubLock.Unlock()
+
+	if len(names) == 0 {
+		for name := range subscriptions {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return c.writer.WritePush([]any{kind, nil, c.subscriptionCount()})
+		}
+	}
+	for _, name := range names {
+		removeSubscription(c, registry, subscriptions, name)
+		if err := c.writer.WritePush([]any{kind, name, c.subscriptionCount()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSubscription removes c from name in registry and its own
+// subscriptions. Callers must hold pubsubLock.
+func removeSubscription(c *client, registry map[string]map[*client]struct{}, subscriptions map[string]struct{}, name string) {
+	delete(subscriptions, name)
+	subscribers := registry[name]
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		delete(registry, name)
+	}
+}
+
+// unsubscribeAll removes every subscription of c. It is the single place a
+// connection leaves the pub/sub registry when it goes away, so PUBLISH never
+// counts or writes to a closed connection.
+func (s *server) unsubscribeAll(c *client) {
+	s.pubsubLock.Lock()
+	defer s.pubsubLock.Unlock()
+	for channel := range c.channels {
+		removeSubscription(c, s.channels, c.channels, channel)
+	}
+	for pattern := range c.patterns {
+		removeSubscription(c, s.patterns, c.patterns, pattern)
+	}
+}
+
+// handlePublishCommand serves PUBLISH channel message. Subscribers of the
+// channel receive a message and subscribers of every matching pattern a
+// pmessage. The reply counts all deliveries, so a client subscribed both ways
+// is counted twice.
+func (s *server) handlePublishCommand(c *client, command []string) error {
+	return c.writer.WriteInteger(int64(s.publish(c, command[1], command[2])))
+}
+
+// publish delivers message to the subscribers of channel and of the
+// patterns matching it, and returns the number of deliveries. publisher is
+// the client that published it, or nil for keyspace notifications.
+func (s *server) publish(publisher *client, channel string, message string) int {
+	// Deliveries are collected first so that no lock is held while writing
+	// to receivers, since a slow subscriber would otherwise stall all of
+	// pub/sub.
+	type delivery struct {
+		receiver *client
+		message  []any
+	}
+	s.pubsubLock.Lock()
+	deliveries := make([]delivery, 0, len(s.channels[channel]))
+	for receiver := range s.channels[channel] {
+		deliveries = append(deliveries, delivery{receiver, []any{"message", channel, message}})
+	}
+	for pattern, subscribers := range s.patterns {
+		if !matchGlob(pattern, channel) {
+			continue
+		}
+		for receiver := range subscribers {
+			deliveries = append(deliveries, delivery{receiver, []any{"pmessage", pattern, channel, message}})
+		}
+	}
+	s.pubsubLock.Unlock()
+
+	for _, d := range deliveries {
+		s.deliver(publisher, d.receiver, d.message)
+	}
+	return len(deliveries)
+}
+
+// deliver writes a published message to receiver. Messages to other
+// connections are flushed right away because their goroutine may be waiting
+// for the next request, while a message to the publisher itself goes out
+// with its reply. A receiver that does not accept the message within
+// subscriberTimeout is disconnected, so it cannot hold up publishers.
+func (s *server) deliver(publisher *client, receiver *client, message []any) {
+	var err error
+	switch {
+	case receiver == publisher:
+		err = receiver.writer.WriteMessage(message)
+	case publisher == nil:
+		// Keyspace notifications that pile up meanwhile disconnect the
+		// receiver if it takes too long.
+		s.setNotifying(receiver)
+		err = receiver.writer.SendMessage(message, subscriberTimeout)
+		s.setNotifying(nil)
+	default:
+		err = receiver.writer.SendMessage(message, subscriberTimeout)
+	}
+	if err != nil {
+		// The receiver's own goroutine notices the closed connection and
+		// unsubscribes it.
+		s.logger.Debug("cannot deliver message, disconnecting subscriber", slog.Int64("clientId", receiver.id), slog.String("err", err.Error()))
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			s.outputLimitDisconnections.Add(1)
+		}
+		receiver.conn.Close()
+	}
+}
+
+// handlePubsubCommand serves PUBSUB CHANNELS [pattern], PUBSUB NUMSUB
+// [channel ...] and PUBSUB NUMPAT.
+func (s *server) handlePubsubCommand(c *client, command []string) error {
+	s.pubsubLock.Lock()
+	defer s.pubsubLock.Unlock()
+
+	switch subcommand := strings.ToUpper(command[1]); {
+	case subcommand == "CHANNELS" && len(command) <= 3:
+		channels := []string{}
+		for channel := range s.channels {
+			if len(command) == 2 || matchGlob(command[2], channel) {
+				channels = append(channels, channel)
+			}
+		}
+		slices.Sort(channels)
+		reply := make([]any, len(channels))
+		for i, channel := range channels {
+			reply[i] = channel
+		}
+		return c.writer.WriteArray(reply)
+	case subcommand == "NUMSUB":
+		reply := make([]any, 0, 2*(len(command)-2))
+		for _, channel := range command[2:] {
+			reply = append(reply, channel, int64(len(s.channels[channel])))
+		}
+		return c.writer.WriteArray(reply)
+	case subcommand == "NUMPAT" && len(command) == 2:
+		return c.writer.WriteInteger(int64(len(s.patterns)))
+	default:
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try PUBSUB HELP.", command[1]))
+	}
+}
@@ -0,0 +1,151 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestMessageFrame(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	publisher := redistest.Connect(t, addr)
+
+	tests := []struct {
+		protocol string
+		prefix   string
+	}{
+		{"2", "*"},
+		{"3", ">"},
+	}
+	for _, test := range tests {
+		t.Run("RESP"+test.protocol, func(t *testing.T) {
+			subscriber := redistest.Connect(t, addr)
+			subscriber.Do("HELLO", test.protocol)
+			run(t, subscriber, []step{
+				{[]string{"SUBSCRIBE", "channel"}, test.prefix + "3\r\n$9\r\nsubscribe\r\n$7\r\nchannel\r\n:1\r\n"},
+			})
+			run(t, publisher, []step{{[]string{"PUBLISH", "channel", "hello"}, ":1\r\n"}})
+			want := test.prefix + "3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n"
+			if got := subscriber.Receive(); got != want {
+				t.Errorf("message = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMessageAfterExec(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// A RESP3 connection can run commands while subscribed, including a
+	// transaction publishing to its own channel.
+	c.Do("HELLO", "3")
+	run(t, c, []step{
+		{[]string{"SUBSCRIBE", "channel"}, ">3\r\n$9\r\nsubscribe\r\n$7\r\nchannel\r\n:1\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"PUBLISH", "channel", "hello"}, "+QUEUED\r\n"},
+		{[]string{"SET", "key", "value"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*2\r\n:1\r\n+OK\r\n"},
+	})
+	want := ">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n"
+	if got := c.Receive(); got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+// receive reads a reply or message for each of want and checks it.
+func receive(t *testing.T, c *redistest.Client, want ...string) {
+	t.Helper()
+	for _, want := range want {
+		if got := c.Receive(); got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	first := redistest.Connect(t, addr)
+	second := redistest.Connect(t, addr)
+	publisher := redistest.Connect(t, addr)
+
+	// Subscribing to several channels confirms each of them.
+	run(t, first, []step{{[]string{"SUBSCRIBE", "a", "b"}, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"}})
+	receive(t, first, "*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n")
+	run(t, second, []step{
+		{[]string{"SUBSCRIBE", "a"}, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"},
+		// Subscribers can only run subscription commands.
+		{[]string{"GET", "key"}, "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"},
+	})
+
+	// PUBLISH replies with the number of subscribers that got the message.
+	run(t, publisher, []step{
+		{[]string{"PUBLISH", "a", "hi"}, ":2\r\n"},
+		{[]string{"PUBLISH", "b", "yo"}, ":1\r\n"},
+		{[]string{"PUBLISH", "nobody", "x"}, ":0\r\n"},
+		{[]string{"PUBSUB", "CHANNELS"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"PUBSUB", "CHANNELS", "b*"}, "*1\r\n$1\r\nb\r\n"},
+		{[]string{"PUBSUB", "NUMSUB", "a", "missing"}, "*4\r\n$1\r\na\r\n:2\r\n$7\r\nmissing\r\n:0\r\n"},
+	})
+	receive(t, first,
+		"*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n",
+		"*3\r\n$7\r\nmessage\r\n$1\r\nb\r\n$2\r\nyo\r\n",
+	)
+	receive(t, second, "*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n")
+
+	// Unsubscribing from everything leaves subscriber mode.
+	run(t, second, []step{
+		{[]string{"UNSUBSCRIBE"}, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:0\r\n"},
+		{[]string{"UNSUBSCRIBE"}, "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"},
+		{[]string{"GET", "key"}, "$-1\r\n"},
+	})
+	run(t, first, []step{{[]string{"UNSUBSCRIBE", "a"}, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n"}})
+	run(t, publisher, []step{
+		{[]string{"PUBLISH", "a", "hi"}, ":0\r\n"},
+		{[]string{"PUBSUB", "NUMSUB", "b"}, "*2\r\n$1\r\nb\r\n:1\r\n"},
+	})
+
+	// A subscriber that disconnects is forgotten.
+	first.Conn().Close()
+	waitForReply(t, publisher, "*0\r\n", "PUBSUB", "CHANNELS")
+	run(t, publisher, []step{{[]string{"PUBLISH", "b", "yo"}, ":0\r\n"}})
+}
+
+func TestPsubscribe(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	subscriber := redistest.Connect(t, addr)
+	publisher := redistest.Connect(t, addr)
+
+	run(t, subscriber, []step{
+		{[]string{"PSUBSCRIBE", "news.*"}, "*3\r\n$10\r\npsubscribe\r\n$6\r\nnews.*\r\n:1\r\n"},
+		{[]string{"PSUBSCRIBE", "h?llo"}, "*3\r\n$10\r\npsubscribe\r\n$5\r\nh?llo\r\n:2\r\n"},
+		// Channels and patterns count towards the same total.
+		{[]string{"SUBSCRIBE", "news.sports"}, "*3\r\n$9\r\nsubscribe\r\n$11\r\nnews.sports\r\n:3\r\n"},
+	})
+
+	// A message reaching a client both through a channel and a pattern is
+	// delivered once for each.
+	run(t, publisher, []step{
+		{[]string{"PUBLISH", "news.sports", "goal"}, ":2\r\n"},
+		{[]string{"PUBLISH", "hello", "world"}, ":1\r\n"},
+		{[]string{"PUBLISH", "other", "x"}, ":0\r\n"},
+		{[]string{"PUBSUB", "NUMPAT"}, ":2\r\n"},
+	})
+	receive(t, subscriber,
+		"*3\r\n$7\r\nmessage\r\n$11\r\nnews.sports\r\n$4\r\ngoal\r\n",
+		"*4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$11\r\nnews.sports\r\n$4\r\ngoal\r\n",
+		"*4\r\n$8\r\npmessage\r\n$5\r\nh?llo\r\n$5\r\nhello\r\n$5\r\nworld\r\n",
+	)
+
+	run(t, subscriber, []step{{[]string{"PUNSUBSCRIBE", "news.*"}, "*3\r\n$12\r\npunsubscribe\r\n$6\r\nnews.*\r\n:2\r\n"}})
+	run(t, publisher, []step{
+		{[]string{"PUBLISH", "news.weather", "rain"}, ":0\r\n"},
+		{[]string{"PUBSUB", "NUMPAT"}, ":1\r\n"},
+	})
+	// PUNSUBSCRIBE without patterns drops every pattern but keeps channels.
+	run(t, subscriber, []step{
+		{[]string{"PUNSUBSCRIBE"}, "*3\r\n$12\r\npunsubscribe\r\n$5\r\nh?llo\r\n:1\r\n"},
+		{[]string{"PING"}, "*2\r\n$4\r\npong\r\n$0\r\n\r\n"},
+	})
+	run(t, publisher, []step{{[]string{"PUBSUB", "NUMPAT"}, ":0\r\n"}})
+}
@@ -0,0 +1,40 @@
+package goredis
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+)
+
+// randomIndexes picks positions in a collection of size elements for
+// SRANDMEMBER, HRANDFIELD and ZRANDMEMBER. A positive count asks for up to
+// count distinct positions and a negative one for exactly -count positions
+// that may repeat. Every position is equally likely to be picked, whatever
+// order the collection is in.
+func randomIndexes(size int, count int) []int {
+	if size == 0 {
+		return []int{}
+	}
+	if count < 0 {
+		indexes := make([]int, -count)
+		for i := range indexes {
+			indexes[i] = rand.IntN(size)
+		}
+		return indexes
+	}
+	return rand.Perm(size)[:min(count, size)]
+}
+
+// parseRandomCount parses the count argument of SRANDMEMBER, HRANDFIELD and
+// ZRANDMEMBER. The returned string is an error message to reply with when
+// the value is invalid.
+func parseRandomCount(value string) (int, string) {
+	count, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return 0, "ERR value is not an integer or out of range"
+	case count == math.MinInt:
+		return 0, "ERR value is out of range"
+	}
+	return count, ""
+}
@@ -0,0 +1,166 @@
+package goredis_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// bulkStrings returns the elements of a raw array reply of bulk strings.
+func bulkStrings(t *testing.T, reply string) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(reply, "\r\n"), "\r\n")
+	if !strings.HasPrefix(lines[0], "*") {
+		t.Fatalf("reply %q is not an array", reply)
+	}
+	elements := []string{}
+	for i := 2; i < len(lines); i += 2 {
+		elements = append(elements, lines[i])
+	}
+	return elements
+}
+
+// checkSample checks that got holds want elements of from, distinct if
+// distinct is set.
+func checkSample(t *testing.T, got []string, want int, from []string, distinct bool) {
+	t.Helper()
+	if len(got) != want {
+		t.Errorf("got %d elements %q, want %d", len(got), got, want)
+	}
+	seen := map[string]bool{}
+	for _, element := range got {
+		if !slices.Contains(from, element) {
+			t.Errorf("%q is not one of %q", element, from)
+		}
+		if distinct && seen[element] {
+			t.Errorf("%q repeats in %q", element, got)
+		}
+		seen[element] = true
+	}
+}
+
+func TestSrandmember(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	members := []string{"a", "b", "c", "d"}
+
+	run(t, c, []step{
+		{[]string{"SRANDMEMBER", "set"}, "$-1\r\n"},
+		{[]string{"SRANDMEMBER", "set", "3"}, "*0\r\n"},
+		{[]string{"SRANDMEMBER", "set", "-3"}, "*0\r\n"},
+		{[]string{"SADD", "set", "a", "b", "c", "d"}, ":4\r\n"},
+		{[]string{"SRANDMEMBER", "set", "0"}, "*0\r\n"},
+		{[]string{"SRANDMEMBER", "set", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"SRANDMEMBER", "set", "1", "2"}, "-ERR wrong number of arguments for 'srandmember' command\r\n"},
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+		{[]string{"SRANDMEMBER", "string"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+
+	checkSample(t, bulkStrings(t, c.Do("SRANDMEMBER", "set", "2")), 2, members, true)
+	checkSample(t, bulkStrings(t, c.Do("SRANDMEMBER", "set", "10")), 4, members, true)
+	checkSample(t, bulkStrings(t, c.Do("SRANDMEMBER", "set", "-10")), 10, members, false)
+	reply := c.Do("SRANDMEMBER", "set")
+	checkSample(t, []string{strings.Split(reply, "\r\n")[1]}, 1, members, false)
+
+	// Nothing is removed.
+	run(t, c, []step{{[]string{"SCARD", "set"}, ":4\r\n"}})
+}
+
+func TestSrandmemberIsFair(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	c.Do("SADD", "set", "a", "b", "c", "d")
+
+	// Picking 2 of 4 distinct members 1200 times picks each member 600
+	// times on average, with a standard deviation of about 17.
+	picked := map[string]int{}
+	for range 1200 {
+		for _, member := range bulkStrings(t, c.Do("SRANDMEMBER", "set", "2")) {
+			picked[member]++
+		}
+	}
+	for _, member := range []string{"a", "b", "c", "d"} {
+		if n := picked[member]; n < 500 || n > 700 {
+			t.Errorf("%q was picked %d times out of 1200, want about 600", member, n)
+		}
+	}
+
+	// The same holds with repeats: 4000 picks, 1000 of each on average.
+	picked = map[string]int{}
+	for _, member := range bulkStrings(t, c.Do("SRANDMEMBER", "set", "-4000")) {
+		picked[member]++
+	}
+	for _, member := range []string{"a", "b", "c", "d"} {
+		if n := picked[member]; n < 850 || n > 1150 {
+			t.Errorf("%q was picked %d times out of 4000, want about 1000", member, n)
+		}
+	}
+}
+
+func TestHrandfield(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	fields := []string{"f1", "f2", "f3"}
+	values := map[string]string{"f1": "v1", "f2": "v2", "f3": "v3"}
+
+	run(t, c, []step{
+		{[]string{"HRANDFIELD", "hash"}, "$-1\r\n"},
+		{[]string{"HRANDFIELD", "hash", "2", "WITHVALUES"}, "*0\r\n"},
+		{[]string{"HSET", "hash", "f1", "v1", "f2", "v2", "f3", "v3"}, ":3\r\n"},
+		{[]string{"HRANDFIELD", "hash", "0"}, "*0\r\n"},
+		{[]string{"HRANDFIELD", "hash", "2", "WITHSCORES"}, "-ERR syntax error\r\n"},
+		{[]string{"HRANDFIELD", "hash", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+		{[]string{"HRANDFIELD", "string", "1"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+
+	checkSample(t, bulkStrings(t, c.Do("HRANDFIELD", "hash", "5")), 3, fields, true)
+	checkSample(t, bulkStrings(t, c.Do("HRANDFIELD", "hash", "-5")), 5, fields, false)
+
+	// WITHVALUES follows each field with its own value.
+	for _, count := range []string{"2", "-6"} {
+		pairs := bulkStrings(t, c.Do("HRANDFIELD", "hash", count, "withvalues"))
+		got := []string{}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if values[pairs[i]] != pairs[i+1] {
+				t.Errorf("HRANDFIELD %s WITHVALUES paired %q with %q", count, pairs[i], pairs[i+1])
+			}
+			got = append(got, pairs[i])
+		}
+		if count == "2" {
+			checkSample(t, got, 2, fields, true)
+		} else {
+			checkSample(t, got, 6, fields, false)
+		}
+	}
+}
+
+func TestZrandmember(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	members := []string{"a", "b", "c"}
+	scores := map[string]string{"a": "1", "b": "2.5", "c": "inf"}
+
+	run(t, c, []step{
+		{[]string{"ZRANDMEMBER", "zset"}, "$-1\r\n"},
+		{[]string{"ZRANDMEMBER", "zset", "-2", "WITHSCORES"}, "*0\r\n"},
+		{[]string{"ZADD", "zset", "1", "a", "2.5", "b", "inf", "c"}, ":3\r\n"},
+		{[]string{"ZRANDMEMBER", "zset", "0"}, "*0\r\n"},
+		{[]string{"ZRANDMEMBER", "zset", "1", "WITHVALUES"}, "-ERR syntax error\r\n"},
+		{[]string{"ZRANDMEMBER", "zset", "1", "WITHSCORES", "x"}, "-ERR wrong number of arguments for 'zrandmember' command\r\n"},
+	})
+
+	checkSample(t, bulkStrings(t, c.Do("ZRANDMEMBER", "zset", "3")), 3, members, true)
+	checkSample(t, bulkStrings(t, c.Do("ZRANDMEMBER", "zset", "-7")), 7, members, false)
+	pairs := bulkStrings(t, c.Do("ZRANDMEMBER", "zset", "2", "WITHSCORES"))
+	got := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if scores[pairs[i]] != pairs[i+1] {
+			t.Errorf("ZRANDMEMBER WITHSCORES paired %q with %q", pairs[i], pairs[i+1])
+		}
+		got = append(got, pairs[i])
+	}
+	checkSample(t, got, 2, members, true)
+}
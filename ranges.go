@@ -0,0 +1,24 @@
+package goredis
+
+// normalizeRange applies Redis's index rules for inclusive [start, stop]
+// ranges over a sequence of the given length, as used by LRANGE, LTRIM,
+// GETRANGE and ZRANGE. Negative indexes count from the end, out of range
+// indexes are clamped and empty reports a range that selects nothing.
+func normalizeRange(start, stop, length int) (lo, hi int, empty bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0, true
+	}
+	return start, stop, false
+}
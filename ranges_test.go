@@ -0,0 +1,74 @@
+package goredis
+
+import (
+	"testing"
+)
+
+func TestNormalizeRange(t *testing.T) {
+	tests := []struct {
+		name              string
+		start, stop, size int
+		lo, hi            int
+		empty             bool
+	}{
+		{"whole", 0, -1, 5, 0, 4, false},
+		{"single", 2, 2, 5, 2, 2, false},
+		{"both positive", 1, 3, 5, 1, 3, false},
+		{"both negative", -3, -2, 5, 2, 3, false},
+		{"mixed", 1, -2, 5, 1, 3, false},
+		{"stop past end", 2, 100, 5, 2, 4, false},
+		{"start before beginning", -100, 1, 5, 0, 1, false},
+		{"both out of range", -100, 100, 5, 0, 4, false},
+		{"start past end", 5, 10, 5, 0, 0, true},
+		{"stop before beginning", 0, -6, 5, 0, 0, true},
+		{"both before beginning", -10, -6, 5, 0, 0, true},
+		{"crossed", 3, 1, 5, 0, 0, true},
+		{"crossed negative", -1, -2, 5, 0, 0, true},
+		{"crossed mixed", -1, 2, 5, 0, 0, true},
+		{"empty sequence", 0, -1, 0, 0, 0, true},
+		{"empty sequence zero", 0, 0, 0, 0, 0, true},
+	}
+	for _, test := range tests {
+		lo, hi, empty := normalizeRange(test.start, test.stop, test.size)
+		if lo != test.lo || hi != test.hi || empty != test.empty {
+			t.Errorf("%s: normalizeRange(%d, %d, %d) = %d, %d, %t, want %d, %d, %t",
+				test.name, test.start, test.stop, test.size, lo, hi, empty, test.lo, test.hi, test.empty)
+		}
+	}
+}
+
+// TestNormalizeRangeExhaustive compares every start and stop around the
+// bounds of short sequences with the definition of a Redis range: index i is
+// selected when it lies between start and stop, after negative indexes are
+// counted from the end.
+func TestNormalizeRangeExhaustive(t *testing.T) {
+	for size := 0; size <= 6; size += 1 {
+		for start := -2*size - 2; start <= 2*size+2; start += 1 {
+			for stop := -2*size - 2; stop <= 2*size+2; stop += 1 {
+				from, to := start, stop
+				if from < 0 {
+					from += size
+				}
+				if to < 0 {
+					to += size
+				}
+				wantLo, wantHi, wantEmpty := 0, 0, true
+				for i := 0; i < size; i += 1 {
+					if i < from || i > to {
+						continue
+					}
+					if wantEmpty {
+						wantLo, wantEmpty = i, false
+					}
+					wantHi = i
+				}
+
+				lo, hi, empty := normalizeRange(start, stop, size)
+				if lo != wantLo || hi != wantHi || empty != wantEmpty {
+					t.Errorf("normalizeRange(%d, %d, %d) = %d, %d, %t, want %d, %d, %t",
+						start, stop, size, lo, hi, empty, wantLo, wantHi, wantEmpty)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,709 @@
+package goredis
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replicationRetryDelay is how long a replica waits before connecting
+	// to its master again after the link failed.
+	replicationRetryDelay = time.Second
+	// replicaTimeout is how long a master waits for a replica to accept the
+	// snapshot or a batch of write commands before dropping it.
+	replicaTimeout = 60 * time.Second
+	// replicaBufferLimit is how many bytes of write commands may wait for a
+	// replica before it is dropped, like the hard limit of the replica class
+	// of client-output-buffer-limit in Redis.
+	replicaBufferLimit = 256 * 1024 * 1024
+)
+
+// replicaStream holds the part of the replication stream waiting to be sent
+// to a replica. feedReplicas adds to it while holding dbLock, and
+// streamToReplica sends it from its own goroutine, so writers never wait
+// for the network.
+type replicaStream struct {
+	// writer is the only writer to the replica's connection, so nothing but
+	// the stream reaches it.
+	writer  *respWriter
+	lock    sync.Mutex
+	pending []byte
+	// ready is signalled when data is added, and done is closed when the
+	// replica is dropped.
+	ready     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// ackOffset is the replication offset the replica last reported with
+	// REPLCONF ACK. It is guarded by the server's replicasLock.
+	ackOffset int64
+}
+
+func newReplicaStream(writer *respWriter) *replicaStream {
+	return &replicaStream{
+		writer: writer,
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// add queues data, and reports false if that takes the stream over
+// replicaBufferLimit.
+func (r *replicaStream) add(data []byte) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.pending)+len(data) > replicaBufferLimit {
+		return false
+	}
+	r.pending = append(r.pending, data...)
+	select {
+	case r.ready <- struct{}{}:
+	default:
+		// The sender has a wakeup pending already.
+	}
+	return true
+}
+
+// take removes and returns the pending data.
+func (r *replicaStream) take() []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	pending := r.pending
+	r.pending = nil
+	return pending
+}
+
+// close stops streamToReplica. It may be called more than once.
+func (r *replicaStream) close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// newReplicationId returns a random 40 character replication id, like the
+// ones Redis uses.
+func newReplicationId() string {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// handleReplicaofCommand serves REPLICAOF host port, which makes the server a
+// replica of the given master, and REPLICAOF NO ONE, which turns it back into
+// a master that keeps the data it has. SLAVEOF is the same command.
+func (s *server) handleReplicaofCommand(c *client, command []string) error {
+	host, port := command[1], command[2]
+	if strings.EqualFold(host, "no") && strings.EqualFold(port, "one") {
+		s.replicaOf("", "")
+		return c.writer.WriteSimpleString("OK")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return c.writer.WriteError("ERR Invalid master port")
+	}
+	if !s.replicaOf(host, port) {
+		return c.writer.WriteSimpleString("OK Already connected to specified master")
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+// replicaOf makes the server replicate the master at host and port, or stop
+// replicating when host is empty. It reports false when nothing changed.
+func (s *server) replicaOf(host string, port string) bool {
+	s.replicationLock.Lock()
+	defer s.replicationLock.Unlock()
+	if host == s.masterHost && port == s.masterPort {
+		return false
+	}
+	if s.stopReplication != nil {
+		s.stopReplication()
+		s.stopReplication = nil
+	}
+	s.masterHost = host
+	s.masterPort = port
+	s.masterLinkUp = false
+	if host != "" {
+		s.connectToMaster()
+	}
+	return true
+}
+
+// connectToMaster starts replicating masterHost and masterPort in the
+// background. Callers must hold replicationLock.
+func (s *server) connectToMaster() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.stopReplication = cancel
+
+	// Registering with background under clientsLock orders the Add before
+	// the Wait in Stop, which sets shuttingDown under the same lock.
+	s.clientsLock.Lock()
+	defer s.clientsLock.Unlock()
+	if s.shuttingDown {
+		return
+	}
+	s.background.Add(1)
+	go s.replicate(ctx, net.JoinHostPort(s.masterHost, s.masterPort))
+}
+
+// isReplica reports whether the server replicates a master.
+func (s *server) isReplica() bool {
+	s.replicationLock.Lock()
+	defer s.replicationLock.Unlock()
+	return s.masterHost != ""
+}
+
+// setMasterLinkUp records whether the link started with ctx is in sync with
+// the master, unless the link was replaced since.
+func (s *server) setMasterLinkUp(ctx context.Context, up bool) {
+	s.replicationLock.Lock()
+	defer s.replicationLock.Unlock()
+	if ctx.Err() == nil {
+		s.masterLinkUp = up
+	}
+}
+
+// isWriteCommand reports whether the command modifies the keyspace, which a
+// replica only lets its master do.
+func isWriteCommand(upperName string) bool {
+	info, ok := commandTable[strings.ToLower(upperName)]
+	return ok && slices.Contains(info.flags, "write")
+}
+
+// masterLink is where a replica is in the replication stream of its master:
+// the replication id of the master and the offset of the data applied so
+// far. An empty replicationId means nothing was applied yet.
+type masterLink struct {
+	replicationId string
+	offset        int64
+}
+
+// replicate keeps the server in sync with the master at addr until ctx is
+// cancelled, connecting again whenever the link fails. Reconnecting asks to
+// continue from where the link failed, which spares loading a snapshot
+// again if the master still has the missing data.
+func (s *server) replicate(ctx context.Context, addr string) {
+	defer s.background.Done()
+
+	var link masterLink
+	for {
+		err := s.syncWithMaster(ctx, addr, &link)
+		s.setMasterLinkUp(ctx, false)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Error("lost link with master, retrying", slog.String("master", addr), slog.String("err", err.Error()))
+		select {
+		case <-time.After(replicationRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncWithMaster connects to the master at addr and continues the stream at
+// link, or replaces the keyspace with the snapshot the master sends if it
+// cannot. It then runs the write commands the master streams, keeping link
+// up to date, until the connection fails or ctx is cancelled.
+func (s *server) syncWithMaster(ctx context.Context, addr string, link *masterLink) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Closing the connection interrupts the reads below.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+	writer := newRespWriter(conn)
+	request := func(command ...string) (string, error) {
+		elements := make([]any, len(command))
+		for i, arg := range command {
+			elements[i] = arg
+		}
+		writer.WriteArray(elements)
+		if err := writer.Flush(); err != nil {
+			return "", err
+		}
+		return readLine(reader)
+	}
+
+	reply, err := request("PING")
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply, "-") {
+		return fmt.Errorf("master replied to PING with %s", reply)
+	}
+	port := ""
+	if _, p, err := net.SplitHostPort(s.listener.Addr().String()); err == nil {
+		port = p
+	}
+	// Older masters do not know REPLCONF, so its reply does not matter.
+	if _, err := request("REPLCONF", "listening-port", port); err != nil {
+		return err
+	}
+	if link.replicationId == "" {
+		reply, err = request("PSYNC", "?", "-1")
+	} else {
+		reply, err = request("PSYNC", link.replicationId, strconv.FormatInt(link.offset, 10))
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(reply)
+	switch {
+	case len(fields) >= 1 && fields[0] == "+CONTINUE":
+		// The master streams the commands missing since link.offset, and
+		// may have a new replication id.
+		if len(fields) == 2 {
+			link.replicationId = fields[1]
+		}
+		s.setMasterLinkUp(ctx, true)
+		s.logger.Info("continuing replication with master", slog.String("master", addr), slog.Int64("offset", link.offset))
+		return s.applyReplicationStream(ctx, reader, writer, link)
+	case strings.HasPrefix(reply, "-"):
+		// A master without PSYNC sends the snapshot right after SYNC,
+		// without a reply of its own, and cannot continue a stream.
+		writer.WriteArray([]any{"SYNC"})
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		fields = []string{"", "", "0"}
+	case len(fields) != 3 || fields[0] != "+FULLRESYNC":
+		return fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+	}
+	offset, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid offset in reply to PSYNC: %s", reply)
+	}
+
+	// The snapshot is sent as a bulk string without the trailing CRLF.
+	header, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(strings.TrimPrefix(header, "$"), 10, 64)
+	if !strings.HasPrefix(header, "$") || err != nil || size < 0 {
+		return fmt.Errorf("invalid snapshot header %q", header)
+	}
+	var payload bytes.Buffer
+	if _, err := io.CopyN(&payload, reader, size); err != nil {
+		return err
+	}
+	database, expirations, err := decodeSnapshot(bufio.NewReader(&payload))
+	if err != nil {
+		return fmt.Errorf("cannot load snapshot from master: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	s.replaceKeyspace(database, expirations)
+	// Only once the snapshot is loaded does the link point past it.
+	*link = masterLink{replicationId: fields[1], offset: offset}
+	s.setMasterLinkUp(ctx, true)
+	s.logger.Info("synchronized with master", slog.String("master", addr), slog.Int("keys", len(database)))
+	return s.applyReplicationStream(ctx, reader, writer, link)
+}
+
+// applyReplicationStream runs the write commands the master streams through
+// reader, and moves link.offset past each of them, until the connection
+// fails or ctx is cancelled. REPLCONF GETACK is answered through writer.
+func (s *server) applyReplicationStream(ctx context.Context, reader *bufio.Reader, writer *respWriter, link *masterLink) error {
+	// The master's commands run like those of the append only file, by a
+	// client without a connection whose replies are discarded.
+	master := &client{
+		writer:        newRespWriter(io.Discard),
+		authenticated: true,
+	}
+	for {
+		command, err := readRequest(reader)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(command) == 3 && strings.EqualFold(command[0], "replconf") && strings.EqualFold(command[1], "getack") {
+			// The offset reported leaves out the GETACK itself, as in Redis.
+			writer.WriteArray([]any{"REPLCONF", "ACK", strconv.FormatInt(link.offset, 10)})
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		} else if len(command) > 0 {
+			s.dispatch(master, command)
+		}
+		link.offset += int64(len(encodeCommand(command)))
+	}
+}
+
+// handleSyncCommand serves PSYNC replicationid offset and SYNC, which a
+// replica sends to start replicating. If the replica applied this server's
+// stream up to an offset the backlog still holds, the reply is +CONTINUE
+// followed by the commands it is missing. Otherwise the reply is the whole
+// keyspace as a snapshot. Either way c then receives every write command
+// the server runs. All of it is sent by streamToReplica, so a slow replica
+// holds up neither writers nor c's own goroutine. The stream takes over c's
+// writer, and replies to the commands the replica sends from then on, such
+// as REPLCONF ACK, are dropped, since the replica would read them as part
+// of the stream.
+func (s *server) handleSyncCommand(c *client, command []string) error {
+	if c.isReplica {
+		return nil
+	}
+	psync := strings.EqualFold(command[0], "psync")
+
+	// Registering c under the same read lock as the snapshot is encoded or
+	// the backlog is read keeps writers out in between, so the stream holds
+	// exactly the writes the replica is missing.
+	stream := newReplicaStream(c.writer)
+	s.dbLock.RLock()
+	s.replicasLock.Lock()
+	if s.backlog == nil {
+		s.backlog = newReplicationBacklog(s.getConfig().replBacklogSize, s.replicationOffset)
+	}
+	var missing []byte
+	partial := false
+	if psync && command[1] == s.replicationId {
+		if offset, err := strconv.ParseInt(command[2], 10, 64); err == nil {
+			missing, partial = s.backlog.since(offset)
+		}
+	}
+	s.replicasLock.Unlock()
+
+	var snapshot bytes.Buffer
+	keys := len(s.database)
+	if partial {
+		// The wakeup also sends +CONTINUE when nothing is missing.
+		stream.add(missing)
+	} else {
+		writer := bufio.NewWriter(&snapshot)
+		encodeSnapshot(writer, s.database, s.expirations)
+		writer.Flush()
+	}
+	s.replicasLock.Lock()
+	s.replicas[c] = stream
+	offset := s.replicationOffset
+	s.replicasLock.Unlock()
+	s.dbLock.RUnlock()
+	c.isReplica = true
+
+	switch {
+	case partial:
+		s.syncPartialOk.Add(1)
+		stream.writer.WriteSimpleString("CONTINUE " + s.replicationId)
+	case psync:
+		if command[1] != "?" {
+			s.syncPartialErr.Add(1)
+		}
+		s.syncFull.Add(1)
+		stream.writer.WriteSimpleString(fmt.Sprintf("FULLRESYNC %s %d", s.replicationId, offset))
+	default:
+		s.syncFull.Add(1)
+	}
+	// Only c's own goroutine uses c.writer, since replicas are neither
+	// subscribers nor monitors.
+	c.writer = newRespWriter(io.Discard)
+	// c's goroutine is tracked by connections, which Stop waits for before
+	// background, so this Add happens before the Wait.
+	s.background.Add(1)
+	if partial {
+		go s.streamToReplica(c, stream, nil)
+		s.logger.Info("replica continuing", slog.Int64("clientId", c.id), slog.Int("bytes", len(missing)))
+		return nil
+	}
+	go s.streamToReplica(c, stream, snapshot.Bytes())
+	s.logger.Info("replica synchronizing", slog.Int64("clientId", c.id), slog.Int("keys", keys))
+	return nil
+}
+
+// streamToReplica sends the snapshot, unless it is nil, to the replica c
+// and then the write commands of stream as they arrive, until the replica
+// is dropped or the server stops. A replica that does not accept them
+// within replicaTimeout is disconnected.
+func (s *server) streamToReplica(c *client, stream *replicaStream, snapshot []byte) {
+	defer s.background.Done()
+
+	var err error
+	if snapshot != nil {
+		err = stream.writer.SendSnapshot(snapshot, replicaTimeout)
+	}
+	for err == nil {
+		select {
+		case <-stream.ready:
+		case <-stream.done:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+		err = stream.writer.SendStream(stream.take(), replicaTimeout)
+	}
+
+	s.logger.Error("cannot write to replica, disconnecting it", slog.Int64("clientId", c.id), slog.String("err", err.Error()))
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		s.outputLimitDisconnections.Add(1)
+	}
+	// The replica's own goroutine notices the closed connection and
+	// cleans up.
+	s.removeReplica(c)
+	c.conn.Close()
+}
+
+// parseWait parses WAIT numreplicas timeout, where timeout is in
+// milliseconds and 0 waits forever.
+func (s *server) parseWait(command []string) (numReplicas int, timeout time.Duration, message string) {
+	if s.isReplica() {
+		return 0, 0, "ERR WAIT cannot be used with replica instances. Please also note that since Redis 4.0 if a replica is configured to be writable (which is not the default) writes to replicas are just local and are not propagated."
+	}
+	numReplicas, err := strconv.Atoi(command[1])
+	if err != nil {
+		return 0, 0, "ERR value is not an integer or out of range"
+	}
+	milliseconds, err := strconv.ParseInt(command[2], 10, 64)
+	if err != nil || milliseconds > int64(time.Duration(1<<63-1)/time.Millisecond) {
+		return 0, 0, "ERR timeout is not an integer or out of range"
+	}
+	if milliseconds < 0 {
+		return 0, 0, "ERR timeout is negative"
+	}
+	return numReplicas, time.Duration(milliseconds) * time.Millisecond, ""
+}
+
+// ackedReplicas returns how many replicas acknowledged the replication
+// stream up to offset. Callers must hold replicasLock.
+func (s *server) ackedReplicas(offset int64) int {
+	acked := 0
+	for _, stream := range s.replicas {
+		if stream.ackOffset >= offset {
+			acked += 1
+		}
+	}
+	return acked
+}
+
+// handleWaitCommand serves WAIT inside a transaction. Nothing else runs
+// until the transaction is over, so no replica could acknowledge anything
+// new, and it replies right away with the number of replicas that
+// acknowledged every write so far.
+func (s *server) handleWaitCommand(c *client, command []string) error {
+	if _, _, message := s.parseWait(command); message != "" {
+		return c.writer.WriteError(message)
+	}
+	s.replicasLock.Lock()
+	acked := s.ackedReplicas(s.replicationOffset)
+	s.replicasLock.Unlock()
+	return c.writer.WriteInteger(int64(acked))
+}
+
+// waitForReplicas serves WAIT numreplicas timeout, which blocks until
+// numreplicas replicas acknowledged every write the server ran so far, or
+// the timeout elapses, and replies with the number of replicas that did.
+// The replicas are asked for their offsets with REPLCONF GETACK. Like
+// waitForPop, it waits without holding execLock.
+func (s *server) waitForReplicas(c *client, command []string) error {
+	numReplicas, timeout, message := s.parseWait(command)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.replicasLock.Lock()
+	target := s.replicationOffset
+	acked, changed := s.ackedReplicas(target), s.replicasAcked
+	s.replicasLock.Unlock()
+	if acked >= numReplicas {
+		return c.writer.WriteInteger(int64(acked))
+	}
+	// The stream is ordered by dbLock.
+	s.dbLock.Lock()
+	s.feedReplicas([]string{"REPLCONF", "GETACK", "*"})
+	s.dbLock.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	gone, stop := watchDisconnect(c)
+	defer stop()
+	for {
+		select {
+		case <-changed:
+		case <-expired:
+			return c.writer.WriteInteger(int64(acked))
+		case <-gone:
+			c.closeAfterReply = true
+			return nil
+		case <-s.ctx.Done():
+			return c.writer.WriteInteger(int64(acked))
+		}
+
+		s.replicasLock.Lock()
+		acked, changed = s.ackedReplicas(target), s.replicasAcked
+		s.replicasLock.Unlock()
+		if acked >= numReplicas {
+			return c.writer.WriteInteger(int64(acked))
+		}
+	}
+}
+
+// handleReplconfCommand serves REPLCONF option value [option value ...],
+// which replicas send to describe themselves. Only listening-port is kept.
+// REPLCONF ACK offset records how much of the replication stream the
+// replica applied, for WAIT, and gets no reply, since replicas do not read
+// one.
+func (s *server) handleReplconfCommand(c *client, command []string) error {
+	if len(command)%2 == 0 {
+		return c.writer.WriteError("ERR syntax error")
+	}
+	for i := 1; i < len(command); i += 2 {
+		switch option := strings.ToLower(command[i]); option {
+		case "listening-port":
+			port, err := strconv.Atoi(command[i+1])
+			if err != nil || port < 0 || port > 65535 {
+				return c.writer.WriteError("ERR value is not an integer or out of range")
+			}
+			s.replicasLock.Lock()
+			c.listeningPort = strconv.Itoa(port)
+			s.replicasLock.Unlock()
+		case "ack":
+			offset, err := strconv.ParseInt(command[i+1], 10, 64)
+			if err != nil {
+				return nil
+			}
+			s.replicasLock.Lock()
+			if stream, ok := s.replicas[c]; ok && offset > stream.ackOffset {
+				stream.ackOffset = offset
+				close(s.replicasAcked)
+				s.replicasAcked = make(chan struct{})
+			}
+			s.replicasLock.Unlock()
+			return nil
+		case "capa", "ip-address":
+		default:
+			return c.writer.WriteError(fmt.Sprintf("ERR Unrecognized REPLCONF option: %s", command[i]))
+		}
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+// feedReplicas queues command for every replica, keeps it in the backlog
+// once there is one, and moves the replication offset past it. Replicas
+// that fall more than replicaBufferLimit behind are disconnected, and have
+// to synchronize again. Callers must hold dbLock for writing, which orders
+// the commands.
+func (s *server) feedReplicas(command []string) {
+	data := encodeCommand(command)
+	s.replicasLock.Lock()
+	defer s.replicasLock.Unlock()
+	s.replicationOffset += int64(len(data))
+	if s.backlog != nil {
+		s.backlog.write(data)
+	}
+	for replica, stream := range s.replicas {
+		if stream.add(data) {
+			continue
+		}
+		// The replica's own goroutine notices the closed connection and
+		// cleans up.
+		s.logger.Error("replica fell too far behind, disconnecting it", slog.Int64("clientId", replica.id))
+		s.outputLimitDisconnections.Add(1)
+		delete(s.replicas, replica)
+		stream.close()
+		replica.conn.Close()
+	}
+}
+
+// resizeBacklog gives the backlog size bytes, which drops its contents, as
+// set by CONFIG SET repl-backlog-size.
+func (s *server) resizeBacklog(size int) {
+	s.replicasLock.Lock()
+	defer s.replicasLock.Unlock()
+	if s.backlog != nil && len(s.backlog.data) != max(size, minReplBacklogSize) {
+		s.backlog = newReplicationBacklog(size, s.replicationOffset)
+	}
+}
+
+// removeReplica stops sending write commands to c. It is safe to call more
+// than once.
+func (s *server) removeReplica(c *client) {
+	if !c.isReplica {
+		return
+	}
+	s.replicasLock.Lock()
+	if stream, ok := s.replicas[c]; ok {
+		delete(s.replicas, c)
+		stream.close()
+	}
+	s.replicasLock.Unlock()
+}
+
+func (s *server) infoReplication() []infoField {
+	s.replicationLock.Lock()
+	fields := []infoField{
+		{"role", "master"},
+	}
+	if s.masterHost != "" {
+		linkStatus := "down"
+		if s.masterLinkUp {
+			linkStatus = "up"
+		}
+		fields = []infoField{
+			{"role", "slave"},
+			{"master_host", s.masterHost},
+			{"master_port", s.masterPort},
+			{"master_link_status", linkStatus},
+		}
+	}
+	s.replicationLock.Unlock()
+
+	s.replicasLock.Lock()
+	replicas := make([]*client, 0, len(s.replicas))
+	for replica := range s.replicas {
+		replicas = append(replicas, replica)
+	}
+	slices.SortFunc(replicas, func(a, b *client) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	fields = append(fields, infoField{"connected_slaves", len(replicas)})
+	for i, replica := range replicas {
+		ip, _, _ := net.SplitHostPort(replica.conn.RemoteAddr().String())
+		fields = append(fields, infoField{
+			fmt.Sprintf("slave%d", i),
+			fmt.Sprintf("ip=%s,port=%s,state=online,offset=%d", ip, replica.listeningPort, s.replicas[replica].ackOffset),
+		})
+	}
+	fields = append(fields,
+		infoField{"master_replid", s.replicationId},
+		infoField{"master_repl_offset", s.replicationOffset},
+	)
+	if s.backlog == nil {
+		fields = append(fields,
+			infoField{"repl_backlog_active", 0},
+			infoField{"repl_backlog_size", s.getConfig().replBacklogSize},
+			infoField{"repl_backlog_first_byte_offset", 0},
+			infoField{"repl_backlog_histlen", 0},
+		)
+	} else {
+		fields = append(fields,
+			infoField{"repl_backlog_active", 1},
+			infoField{"repl_backlog_size", len(s.backlog.data)},
+			infoField{"repl_backlog_first_byte_offset", s.backlog.start()},
+			infoField{"repl_backlog_histlen", s.backlog.length},
+		)
+	}
+	s.replicasLock.Unlock()
+	return fields
+}
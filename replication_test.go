@@ -0,0 +1,321 @@
+package goredis_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// syncTimeout bounds how long tests wait for a replica to catch up.
+const syncTimeout = 5 * time.Second
+
+// waitForReply sends command until it gets want, and fails the test if it
+// does not within syncTimeout.
+func waitForReply(t *testing.T, c *redistest.Client, want string, command ...string) {
+	t.Helper()
+	deadline := time.Now().Add(syncTimeout)
+	for {
+		got := c.Do(command...)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%q = %q, want %q", command, got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicaOf(t *testing.T) {
+	masterAddr, _ := redistest.StartServer(t)
+	master := redistest.Connect(t, masterAddr)
+	// Data from before the link is in the snapshot, and writes after it are
+	// streamed.
+	run(t, master, []step{{[]string{"SET", "before", "1"}, "+OK\r\n"}})
+	host, port, _ := net.SplitHostPort(masterAddr)
+	replicaAddr, _ := redistest.StartServer(t, goredis.WithReplicaOf(host, port))
+	replica := redistest.Connect(t, replicaAddr)
+	waitForReply(t, replica, "$1\r\n1\r\n", "GET", "before")
+	run(t, master, []step{
+		{[]string{"SET", "after", "2"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "a", "b"}, ":2\r\n"},
+		{[]string{"DEL", "before"}, ":1\r\n"},
+	})
+	waitForReply(t, replica, ":0\r\n", "EXISTS", "before")
+	run(t, replica, []step{
+		{[]string{"GET", "after"}, "$1\r\n2\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+	})
+
+	// Clients of the replica can read but not write.
+	run(t, replica, []step{
+		{[]string{"SET", "key", "value"}, "-READONLY You can't write against a read only replica.\r\n"},
+		{[]string{"DEL", "after"}, "-READONLY You can't write against a read only replica.\r\n"},
+		{[]string{"GET", "after"}, "$1\r\n2\r\n"},
+	})
+	if info := replica.Do("INFO", "replication"); !strings.Contains(info, "role:slave\r\n") || !strings.Contains(info, "master_link_status:up\r\n") {
+		t.Errorf("INFO replication of the replica:\n%s", info)
+	}
+
+	// Once promoted, the replica keeps its data and accepts writes.
+	run(t, replica, []step{
+		{[]string{"REPLICAOF", "NO", "ONE"}, "+OK\r\n"},
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"GET", "after"}, "$1\r\n2\r\n"},
+	})
+}
+
+func TestSlowReplicaDoesNotBlockWrites(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// The snapshot and the writes after it are far larger than the socket
+	// buffers, and the replica reads none of them.
+	value := strings.Repeat("v", 256*1024)
+	for i := range 100 {
+		c.Do("SET", "before"+strconv.Itoa(i), value)
+	}
+	replica := redistest.Connect(t, addr)
+	replica.Send("PSYNC", "?", "-1")
+	time.Sleep(blockDelay)
+
+	start := time.Now()
+	for i := range 100 {
+		if got := c.Do("SET", "after"+strconv.Itoa(i), value); got != "+OK\r\n" {
+			t.Fatalf("SET = %q", got)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("writes took %v", elapsed)
+	}
+	if info := c.Do("INFO", "replication"); !strings.Contains(info, "connected_slaves:1\r\n") {
+		t.Errorf("replica is not connected:\n%s", info)
+	}
+}
+
+// psync sends PSYNC replicationId offset on a new connection to addr, as a
+// replica would, and returns the connection and its reply line.
+func psync(t *testing.T, addr string, replicationId string, offset string) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+	conn := redistest.Dial(t, addr)
+	conn.SetReadDeadline(time.Now().Add(syncTimeout))
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "*3\r\n$5\r\nPSYNC\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(replicationId), replicationId, len(offset), offset)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read PSYNC reply: %v", err)
+	}
+	return conn, reader, strings.TrimSuffix(line, "\r\n")
+}
+
+// skipSnapshot reads the snapshot that follows +FULLRESYNC, a bulk string
+// without the trailing CRLF.
+func skipSnapshot(t *testing.T, reader *bufio.Reader) {
+	t.Helper()
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "$") {
+		t.Fatalf("snapshot header = %q, %v", line, err)
+	}
+	size, _ := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if _, err := io.CopyN(io.Discard, reader, int64(size)); err != nil {
+		t.Fatalf("cannot read snapshot: %v", err)
+	}
+}
+
+// expectStream reads len(want) bytes of the replication stream from reader
+// and compares them with want.
+func expectStream(t *testing.T, reader *bufio.Reader, want string) {
+	t.Helper()
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatalf("cannot read commands: %v (read %q)", err, got)
+	}
+	if string(got) != want {
+		t.Errorf("replica received %q, want %q", got, want)
+	}
+}
+
+func TestWritesReachReplicaConnection(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	c.Do("SET", "before", "1")
+
+	// Act as a replica on a plain connection.
+	conn, reader, reply := psync(t, addr, "?", "-1")
+	if !strings.HasPrefix(reply, "+FULLRESYNC ") {
+		t.Fatalf("PSYNC reply = %q", reply)
+	}
+	skipSnapshot(t, reader)
+
+	// The replica's own commands get no reply, which would be mixed into
+	// the stream.
+	io.WriteString(conn, "*1\r\n$4\r\nPING\r\n")
+	time.Sleep(blockDelay)
+
+	// Writes arrive in order as arrays, and reads are not sent.
+	run(t, c, []step{
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$5\r\nvalue\r\n"},
+		{[]string{"INCR", "counter"}, ":1\r\n"},
+	})
+	expectStream(t, reader, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"+
+		"*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n")
+}
+
+func TestTransactionReachesReplicaWhole(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	_, reader, reply := psync(t, addr, "?", "-1")
+	if !strings.HasPrefix(reply, "+FULLRESYNC ") {
+		t.Fatalf("PSYNC reply = %q", reply)
+	}
+	skipSnapshot(t, reader)
+
+	run(t, c, []step{
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*1\r\n$-1\r\n"},
+		{[]string{"MULTI"}, "+OK\r\n"},
+		{[]string{"SET", "key", "value"}, "+QUEUED\r\n"},
+		{[]string{"INCR", "counter"}, "+QUEUED\r\n"},
+		{[]string{"EXEC"}, "*2\r\n+OK\r\n:1\r\n"},
+		{[]string{"DEL", "key"}, ":1\r\n"},
+	})
+	expectStream(t, reader, "*1\r\n$5\r\nMULTI\r\n"+
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"+
+		"*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"+
+		"*1\r\n$4\r\nEXEC\r\n"+
+		"*2\r\n$3\r\nDEL\r\n$3\r\nkey\r\n")
+}
+
+func TestPartialResync(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+	c.Do("SET", "before", "1")
+
+	conn, reader, reply := psync(t, addr, "?", "-1")
+	fields := strings.Fields(reply)
+	if len(fields) != 3 || fields[0] != "+FULLRESYNC" {
+		t.Fatalf("PSYNC reply = %q", reply)
+	}
+	replicationId := fields[1]
+	offset, _ := strconv.Atoi(fields[2])
+	skipSnapshot(t, reader)
+	first := "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"
+	c.Do("SET", "a", "1")
+	expectStream(t, reader, first)
+	offset += len(first)
+
+	// The link fails, and writes go on meanwhile.
+	conn.Close()
+	c.Do("SET", "b", "2")
+	c.Do("INCR", "counter")
+
+	// Continuing from the offset reached sends only the missing commands.
+	_, reader, reply = psync(t, addr, replicationId, strconv.Itoa(offset))
+	if reply != "+CONTINUE "+replicationId {
+		t.Fatalf("PSYNC reply = %q, want +CONTINUE", reply)
+	}
+	expectStream(t, reader, "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n"+
+		"*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n")
+
+	// Another replication id or an offset the backlog does not hold needs
+	// a snapshot.
+	for _, request := range [][2]string{
+		{"0123456789012345678901234567890123456789", strconv.Itoa(offset)},
+		{replicationId, strconv.Itoa(offset + 1000)},
+	} {
+		_, reader, reply := psync(t, addr, request[0], request[1])
+		if !strings.HasPrefix(reply, "+FULLRESYNC "+replicationId+" ") {
+			t.Errorf("PSYNC %s %s = %q, want +FULLRESYNC", request[0], request[1], reply)
+			continue
+		}
+		skipSnapshot(t, reader)
+	}
+
+	info := c.Do("INFO", "stats")
+	for _, field := range []string{"sync_full:3\r\n", "sync_partial_ok:1\r\n", "sync_partial_err:2\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("INFO stats lacks %q:\n%s", field, info)
+		}
+	}
+	if info := c.Do("INFO", "replication"); !strings.Contains(info, "repl_backlog_active:1\r\n") {
+		t.Errorf("INFO replication:\n%s", info)
+	}
+}
+
+func TestReplicaContinuesAfterLinkLoss(t *testing.T) {
+	masterAddr, _ := redistest.StartServer(t)
+	master := redistest.Connect(t, masterAddr)
+	host, port, _ := net.SplitHostPort(masterAddr)
+	replicaAddr, _ := redistest.StartServer(t, goredis.WithReplicaOf(host, port))
+	replica := redistest.Connect(t, replicaAddr)
+
+	master.Do("SET", "a", "1")
+	waitForReply(t, replica, "$1\r\n1\r\n", "GET", "a")
+
+	// Drop the link from the master's side: the replica's is the only other
+	// connection.
+	own := strings.TrimSuffix(strings.TrimPrefix(master.Do("CLIENT", "ID"), ":"), "\r\n")
+	for _, line := range strings.Split(master.Do("CLIENT", "LIST"), "\n") {
+		if id, ok := strings.CutPrefix(strings.Fields(line + " x")[0], "id="); ok && id != own {
+			run(t, master, []step{{[]string{"CLIENT", "KILL", "ID", id}, ":1\r\n"}})
+		}
+	}
+	master.Do("SET", "b", "2")
+
+	waitForReply(t, replica, "$1\r\n2\r\n", "GET", "b")
+	info := master.Do("INFO", "stats")
+	if !strings.Contains(info, "sync_full:1\r\n") || !strings.Contains(info, "sync_partial_ok:1\r\n") {
+		t.Errorf("INFO stats of the master:\n%s", info)
+	}
+}
+
+func TestWait(t *testing.T) {
+	masterAddr, _ := redistest.StartServer(t)
+	master := redistest.Connect(t, masterAddr)
+
+	// Without replicas WAIT only succeeds when asked for none.
+	run(t, master, []step{
+		{[]string{"WAIT", "0", "0"}, ":0\r\n"},
+		{[]string{"WAIT", "1", "50"}, ":0\r\n"},
+		{[]string{"WAIT", "1", "-1"}, "-ERR timeout is negative\r\n"},
+		{[]string{"WAIT", "one", "0"}, "-ERR value is not an integer or out of range\r\n"},
+	})
+
+	host, port, _ := net.SplitHostPort(masterAddr)
+	replicaAddr, _ := redistest.StartServer(t, goredis.WithReplicaOf(host, port))
+	replica := redistest.Connect(t, replicaAddr)
+	waitForReply(t, master, ":1\r\n", "WAIT", "1", "100")
+
+	// WAIT returns once the replica acknowledged the writes before it,
+	// which it has applied by then.
+	for i := range 10 {
+		run(t, master, []step{
+			{[]string{"INCR", "counter"}, fmt.Sprintf(":%d\r\n", i+1)},
+			{[]string{"WAIT", "1", "0"}, ":1\r\n"},
+		})
+		value := strconv.Itoa(i + 1)
+		run(t, replica, []step{{[]string{"GET", "counter"}, fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)}})
+	}
+	// Asking for more replicas than there are waits for the timeout.
+	start := time.Now()
+	run(t, master, []step{{[]string{"WAIT", "2", "100"}, ":1\r\n"}})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("WAIT returned after %v", elapsed)
+	}
+	if info := master.Do("INFO", "replication"); !strings.Contains(info, "master_repl_offset:") {
+		t.Errorf("INFO replication of the master:\n%s", info)
+	}
+
+	run(t, replica, []step{{[]string{"WAIT", "0", "0"}, "-ERR WAIT cannot be used with replica instances. Please also note that since Redis 4.0 if a replica is configured to be writable (which is not the default) writes to replicas are just local and are not propagated.\r\n"}})
+}
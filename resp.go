@@ -0,0 +1,235 @@
+package goredis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits on what a single request may claim, so that a length header cannot
+// make the server allocate memory the client never sends.
+const (
+	maxMultibulkLength = 1024 * 1024
+	maxBulkLength      = 512 * 1024 * 1024
+	maxInlineLength    = 64 * 1024
+)
+
+// bulkPreallocLimit is the largest bulk string that is allocated up front.
+// Longer ones grow as their bytes arrive.
+const bulkPreallocLimit = 64 * 1024
+
+// readRequest reads the next command from reader. Clients normally send RESP
+// arrays, but a line that does not start with '*' is parsed as an inline
+// command, as typed into a telnet session.
+func readRequest(reader io.Reader) ([]string, error) {
+	firstChar, err := readByte(reader)
+	if err != nil {
+		return nil, err
+	}
+	if firstChar == '*' {
+		return readArray(reader, false)
+	}
+
+	var line []byte
+	for b := firstChar; b != '\n'; {
+		if len(line) >= maxInlineLength {
+			return nil, errors.New("too big inline request")
+		}
+		line = append(line, b)
+		if b, err = readByte(reader); err != nil {
+			return nil, err
+		}
+	}
+	return splitInlineArgs(strings.TrimSuffix(string(line), "\r"))
+}
+
+// readArray reads a request, a RESP array of bulk strings, from reader. When
+// readFirstChar is false the leading '*' has already been consumed by the
+// caller.
+func readArray(reader io.Reader, readFirstChar bool) ([]string, error) {
+	if readFirstChar {
+		firstChar, err := readByte(reader)
+		if err != nil {
+			return nil, err
+		}
+		if firstChar != '*' {
+			return nil, fmt.Errorf("expected '*', got '%c'", firstChar)
+		}
+	}
+
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(line, 10, 64)
+	if err != nil || n > maxMultibulkLength {
+		return nil, errors.New("invalid multibulk length")
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	// Requests are arrays of bulk strings. Growing the result as elements
+	// arrive keeps a large count from allocating before the data is sent.
+	result := make([]string, 0, min(n, 1024))
+	for range n {
+		typeChar, err := readByte(reader)
+		if err != nil {
+			return nil, err
+		}
+		if typeChar != '$' {
+			return nil, fmt.Errorf("expected '$', got '%c'", typeChar)
+		}
+		str, err := readBulkString(reader)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, str)
+	}
+	return result, nil
+}
+
+// readBulkString reads a bulk string whose leading '$' has already been
+// consumed.
+func readBulkString(reader io.Reader) (string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseInt(line, 10, 64)
+	if err != nil || n < 0 || n > maxBulkLength {
+		return "", errors.New("invalid bulk length")
+	}
+
+	var buf []byte
+	if n <= bulkPreallocLimit {
+		buf = make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", err
+		}
+	} else {
+		var b bytes.Buffer
+		if _, err := io.CopyN(&b, reader, n+2); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		buf = b.Bytes()
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return "", errors.New("bulk string is not terminated by CRLF")
+	}
+	return string(buf[:n]), nil
+}
+
+// readLine reads up to and including the next CRLF and returns the line
+// without it. Lines longer than maxInlineLength are rejected.
+func readLine(reader io.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := readByte(reader)
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' && len(line) > 0 && line[len(line)-1] == '\r' {
+			return string(line[:len(line)-1]), nil
+		}
+		if len(line) > maxInlineLength {
+			return "", errors.New("too big inline request")
+		}
+		line = append(line, b)
+	}
+}
+
+func readByte(reader io.Reader) (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(reader, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// splitInlineArgs splits an inline command on whitespace the way redis-server
+// does. Double quoted arguments support backslash escapes including \xHH,
+// single quoted arguments only support \'.
+func splitInlineArgs(line string) ([]string, error) {
+	errUnbalanced := errors.New("unbalanced quotes in request")
+
+	var args []string
+	for i := 0; i < len(line); {
+		if isInlineSpace(line[i]) {
+			i += 1
+			continue
+		}
+
+		var arg strings.Builder
+		switch line[i] {
+		case '"':
+			i += 1
+			for ; i < len(line) && line[i] != '"'; i += 1 {
+				if line[i] != '\\' || i+1 == len(line) {
+					arg.WriteByte(line[i])
+					continue
+				}
+				i += 1
+				switch line[i] {
+				case 'n':
+					arg.WriteByte('\n')
+				case 'r':
+					arg.WriteByte('\r')
+				case 't':
+					arg.WriteByte('\t')
+				case 'b':
+					arg.WriteByte('\b')
+				case 'a':
+					arg.WriteByte('\a')
+				case 'x':
+					if i+2 < len(line) {
+						if b, err := strconv.ParseUint(line[i+1:i+3], 16, 8); err == nil {
+							arg.WriteByte(byte(b))
+							i += 2
+							continue
+						}
+					}
+					arg.WriteByte('x')
+				default:
+					arg.WriteByte(line[i])
+				}
+			}
+			if i == len(line) {
+				return nil, errUnbalanced
+			}
+			i += 1
+		case '\'':
+			i += 1
+			for ; i < len(line) && line[i] != '\''; i += 1 {
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i += 1
+				}
+				arg.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, errUnbalanced
+			}
+			i += 1
+		default:
+			for ; i < len(line) && !isInlineSpace(line[i]); i += 1 {
+				arg.WriteByte(line[i])
+			}
+		}
+		// A closing quote must be followed by a space or the end of the line.
+		if i < len(line) && !isInlineSpace(line[i]) {
+			return nil, errUnbalanced
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func isInlineSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
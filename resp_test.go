@@ -0,0 +1,47 @@
+package goredis_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestOversizedRequest(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{"array length", "*1000000000\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		{"bulk length", "*1\r\n$600000000\r\n", "-ERR Protocol error: invalid bulk length\r\n"},
+		{"negative bulk length", "*1\r\n$-5\r\n", "-ERR Protocol error: invalid bulk length\r\n"},
+		{"inline request", strings.Repeat("a", 100*1024), "-ERR Protocol error: too big inline request\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := redistest.Connect(t, addr)
+			if _, err := io.WriteString(c.Conn(), test.request); err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Receive(); got != test.want {
+				t.Errorf("reply = %q, want %q", got, test.want)
+			}
+
+			// The server closes the connection after the error. Closing it
+			// with unread data, like the rest of the inline request, resets
+			// it instead of sending EOF.
+			c.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, err := c.Conn().Read(make([]byte, 1))
+			if err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("read after the error = %d, %v, want the connection closed", n, err)
+			}
+		})
+	}
+}
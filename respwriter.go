@@ -0,0 +1,309 @@
+package goredis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// respWriter buffers replies to a connection and serializes them in the
+// protocol version the connection negotiated. It is safe for concurrent use,
+// since other connections write published messages to subscribers, and each
+// reply is written atomically.
+type respWriter struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	// conn is the underlying writer when it supports write deadlines, as
+	// network connections do, and nil otherwise.
+	conn interface{ SetWriteDeadline(time.Time) error }
+	// protocol is the RESP version negotiated with HELLO.
+	protocol int
+	// holding is set while a reply is written in several parts, during
+	// which published messages wait in held instead of splitting it.
+	holding bool
+	held    [][]any
+	// written counts the bytes sent to the underlying writer.
+	written byteCounter
+}
+
+func newRespWriter(w io.Writer) *respWriter {
+	conn, _ := w.(interface{ SetWriteDeadline(time.Time) error })
+	writer := &respWriter{
+		conn:     conn,
+		protocol: 2,
+	}
+	writer.writer = bufio.NewWriter(countingWriter{w, &writer.written})
+	return writer
+}
+
+// countingWriter counts the bytes written to writer.
+type countingWriter struct {
+	writer  io.Writer
+	counter *byteCounter
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.counter.add(n)
+	return n, err
+}
+
+func (w *respWriter) Protocol() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.protocol
+}
+
+func (w *respWriter) SetProtocol(protocol int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.protocol = protocol
+}
+
+// Flush sends the buffered replies.
+func (w *respWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Flush()
+}
+
+// sendTimeout runs write, which uses the unlocked methods, and flushes. It
+// is for writes on behalf of other connections, which must not wait for a
+// peer that stopped reading, and fails if the data is not sent within
+// timeout. The deadline also covers the writes the buffer makes on its own
+// when it fills up.
+func (w *respWriter) sendTimeout(timeout time.Duration, write func() error) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn != nil {
+		w.conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer w.conn.SetWriteDeadline(time.Time{})
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+func (w *respWriter) WriteSimpleString(s string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writer.WriteByte('+')
+	w.writer.WriteString(s)
+	_, err := w.writer.WriteString("\r\n")
+	return err
+}
+
+// WriteError writes an error reply. message starts with the error code, as
+// in "ERR syntax error" or "WRONGTYPE ...".
+func (w *respWriter) WriteError(message string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writer.WriteByte('-')
+	w.writer.WriteString(message)
+	_, err := w.writer.WriteString("\r\n")
+	return err
+}
+
+func (w *respWriter) WriteInteger(n int64) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writeInteger(n)
+}
+
+func (w *respWriter) WriteBulkString(s string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writeBulkString(s)
+}
+
+// WriteNull writes a null: the RESP3 null type, or a null bulk string for
+// RESP2.
+func (w *respWriter) WriteNull() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writeNull()
+}
+
+// WriteNullArray writes a null array: the RESP3 null type, or *-1 for
+// RESP2.
+func (w *respWriter) WriteNullArray() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.protocol == 3 {
+		_, err := w.writer.WriteString("_\r\n")
+		return err
+	}
+	_, err := w.writer.WriteString("*-1\r\n")
+	return err
+}
+
+// WriteArray writes elements as an array. Strings are written as bulk
+// strings, int64s as integers, nested []any as arrays and nil as a null.
+func (w *respWriter) WriteArray(elements []any) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writeArray(elements)
+}
+
+// WritePush writes out-of-band data such as a pub/sub message: a RESP3 push,
+// which clients keep apart from command replies, or an array for RESP2.
+func (w *respWriter) WritePush(elements []any) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writePush(elements)
+}
+
+func (w *respWriter) writePush(elements []any) error {
+	if w.protocol != 3 {
+		return w.writeArray(elements)
+	}
+	w.writeHeader('>', len(elements))
+	return w.writeElements(elements)
+}
+
+// WriteMessage writes a published message with WritePush. While messages
+// are held, it is queued and written by ReleaseMessages instead.
+func (w *respWriter) WriteMessage(elements []any) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writeMessage(elements)
+}
+
+// SendMessage is WriteMessage followed by a flush, both of which must
+// complete within timeout.
+func (w *respWriter) SendMessage(elements []any, timeout time.Duration) error {
+	return w.sendTimeout(timeout, func() error {
+		return w.writeMessage(elements)
+	})
+}
+
+func (w *respWriter) writeMessage(elements []any) error {
+	if w.holding {
+		w.held = append(w.held, elements)
+		return nil
+	}
+	return w.writePush(elements)
+}
+
+// HoldMessages makes WriteMessage queue messages until ReleaseMessages, for
+// replies such as EXEC that are written in several parts, which a message
+// must not land between.
+func (w *respWriter) HoldMessages() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.holding = true
+}
+
+// ReleaseMessages writes the messages queued since HoldMessages.
+func (w *respWriter) ReleaseMessages() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.holding = false
+	var err error
+	for _, message := range w.held {
+		if e := w.writePush(message); e != nil {
+			err = e
+		}
+	}
+	w.held = nil
+	return err
+}
+
+// WriteArrayHeader starts an array of n elements that the caller writes one
+// by one, for replies such as EXEC that are assembled from other replies.
+func (w *respWriter) WriteArrayHeader(n int) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writeHeader('*', n)
+	return nil
+}
+
+// WriteMap writes alternating keys and values as a RESP3 map, or as a flat
+// array of keys and values for RESP2.
+func (w *respWriter) WriteMap(pairs []any) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.protocol != 3 {
+		return w.writeArray(pairs)
+	}
+	w.writeHeader('%', len(pairs)/2)
+	return w.writeElements(pairs)
+}
+
+// SendSnapshot sends a snapshot to a replica within timeout. Like the RDB
+// file Redis sends, it is a bulk string without the trailing CRLF.
+func (w *respWriter) SendSnapshot(snapshot []byte, timeout time.Duration) error {
+	return w.sendTimeout(timeout, func() error {
+		w.writeHeader('$', len(snapshot))
+		_, err := w.writer.Write(snapshot)
+		return err
+	})
+}
+
+// SendStream sends part of the replication stream, which holds commands
+// already encoded as arrays, to a replica within timeout.
+func (w *respWriter) SendStream(data []byte, timeout time.Duration) error {
+	return w.sendTimeout(timeout, func() error {
+		_, err := w.writer.Write(data)
+		return err
+	})
+}
+
+func (w *respWriter) writeInteger(n int64) error {
+	w.writer.WriteByte(':')
+	w.writer.WriteString(strconv.FormatInt(n, 10))
+	_, err := w.writer.WriteString("\r\n")
+	return err
+}
+
+func (w *respWriter) writeBulkString(s string) error {
+	w.writer.WriteByte('$')
+	w.writer.WriteString(strconv.Itoa(len(s)))
+	w.writer.WriteString("\r\n")
+	w.writer.WriteString(s)
+	_, err := w.writer.WriteString("\r\n")
+	return err
+}
+
+func (w *respWriter) writeNull() error {
+	if w.protocol == 3 {
+		_, err := w.writer.WriteString("_\r\n")
+		return err
+	}
+	_, err := w.writer.WriteString("$-1\r\n")
+	return err
+}
+
+func (w *respWriter) writeArray(elements []any) error {
+	w.writeHeader('*', len(elements))
+	return w.writeElements(elements)
+}
+
+func (w *respWriter) writeHeader(prefix byte, n int) {
+	w.writer.WriteByte(prefix)
+	w.writer.WriteString(strconv.Itoa(n))
+	w.writer.WriteString("\r\n")
+}
+
+func (w *respWriter) writeElements(elements []any) error {
+	var err error
+	for _, element := range elements {
+		switch element := element.(type) {
+		case nil:
+			err = w.writeNull()
+		case string:
+			err = w.writeBulkString(element)
+		case int64:
+			err = w.writeInteger(element)
+		case []any:
+			err = w.writeArray(element)
+		default:
+			panic(fmt.Sprintf("cannot encode %T", element))
+		}
+	}
+	return err
+}
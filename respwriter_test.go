@@ -0,0 +1,217 @@
+package goredis
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRespWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *respWriter) error
+		resp2 string
+		resp3 string
+	}{
+		{
+			name:  "simple string",
+			write: func(w *respWriter) error { return w.WriteSimpleString("OK") },
+			resp2: "+OK\r\n",
+			resp3: "+OK\r\n",
+		},
+		{
+			name:  "error",
+			write: func(w *respWriter) error { return w.WriteError("ERR syntax error") },
+			resp2: "-ERR syntax error\r\n",
+			resp3: "-ERR syntax error\r\n",
+		},
+		{
+			name:  "integer",
+			write: func(w *respWriter) error { return w.WriteInteger(-42) },
+			resp2: ":-42\r\n",
+			resp3: ":-42\r\n",
+		},
+		{
+			name:  "bulk string",
+			write: func(w *respWriter) error { return w.WriteBulkString("a\r\nb") },
+			resp2: "$4\r\na\r\nb\r\n",
+			resp3: "$4\r\na\r\nb\r\n",
+		},
+		{
+			name:  "empty bulk string",
+			write: func(w *respWriter) error { return w.WriteBulkString("") },
+			resp2: "$0\r\n\r\n",
+			resp3: "$0\r\n\r\n",
+		},
+		{
+			name:  "null",
+			write: func(w *respWriter) error { return w.WriteNull() },
+			resp2: "$-1\r\n",
+			resp3: "_\r\n",
+		},
+		{
+			name:  "null array",
+			write: func(w *respWriter) error { return w.WriteNullArray() },
+			resp2: "*-1\r\n",
+			resp3: "_\r\n",
+		},
+		{
+			name:  "empty array",
+			write: func(w *respWriter) error { return w.WriteArray([]any{}) },
+			resp2: "*0\r\n",
+			resp3: "*0\r\n",
+		},
+		{
+			name:  "array",
+			write: func(w *respWriter) error { return w.WriteArray([]any{"a", int64(1), nil, []any{"b"}}) },
+			resp2: "*4\r\n$1\r\na\r\n:1\r\n$-1\r\n*1\r\n$1\r\nb\r\n",
+			resp3: "*4\r\n$1\r\na\r\n:1\r\n_\r\n*1\r\n$1\r\nb\r\n",
+		},
+		{
+			name:  "push",
+			write: func(w *respWriter) error { return w.WritePush([]any{"message", "channel", "hello"}) },
+			resp2: "*3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+			resp3: ">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+		},
+		{
+			name:  "message",
+			write: func(w *respWriter) error { return w.WriteMessage([]any{"message", "channel", "hello"}) },
+			resp2: "*3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+			resp3: ">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+		},
+		{
+			name: "held messages",
+			write: func(w *respWriter) error {
+				w.HoldMessages()
+				w.WriteMessage([]any{"message", "channel", "hello"})
+				w.WriteArrayHeader(1)
+				w.WriteSimpleString("OK")
+				return w.ReleaseMessages()
+			},
+			resp2: "*1\r\n+OK\r\n*3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+			resp3: "*1\r\n+OK\r\n>3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n",
+		},
+		{
+			name:  "array header",
+			write: func(w *respWriter) error { return w.WriteArrayHeader(2) },
+			resp2: "*2\r\n",
+			resp3: "*2\r\n",
+		},
+		{
+			name:  "map",
+			write: func(w *respWriter) error { return w.WriteMap([]any{"server", "redis", "proto", int64(3)}) },
+			resp2: "*4\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n",
+			resp3: "%2\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n",
+		},
+		{
+			name:  "snapshot",
+			write: func(w *respWriter) error { return w.SendSnapshot([]byte("REDIS"), time.Second) },
+			resp2: "$5\r\nREDIS",
+			resp3: "$5\r\nREDIS",
+		},
+		{
+			name:  "stream",
+			write: func(w *respWriter) error { return w.SendStream([]byte("*1\r\n$4\r\nPING\r\n"), time.Second) },
+			resp2: "*1\r\n$4\r\nPING\r\n",
+			resp3: "*1\r\n$4\r\nPING\r\n",
+		},
+	}
+	for _, test := range tests {
+		for _, protocol := range []int{2, 3} {
+			var buf bytes.Buffer
+			w := newRespWriter(&buf)
+			w.SetProtocol(protocol)
+			if err := test.write(w); err != nil {
+				t.Errorf("%s in RESP%d: %v", test.name, protocol, err)
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				t.Errorf("%s in RESP%d: flush: %v", test.name, protocol, err)
+				continue
+			}
+			want := test.resp2
+			if protocol == 3 {
+				want = test.resp3
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("%s in RESP%d wrote %q, want %q", test.name, protocol, got, want)
+			}
+		}
+	}
+}
+
+func TestRespWriterBuffers(t *testing.T) {
+	var buf bytes.Buffer
+	w := newRespWriter(&buf)
+	w.WriteSimpleString("OK")
+	w.WriteInteger(1)
+	if buf.Len() != 0 {
+		t.Fatalf("wrote %q before Flush", buf.String())
+	}
+	w.Flush()
+	if got, want := buf.String(), "+OK\r\n:1\r\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+	if got := w.written.count.Load(); got != int64(buf.Len()) {
+		t.Errorf("counted %d bytes written, want %d", got, buf.Len())
+	}
+}
+
+// BenchmarkSetReplies writes the replies to a pipeline of SETs to a TCP
+// connection, flushing once per pipeline as the server does, or after every
+// reply as an unbuffered writer would.
+func BenchmarkSetReplies(b *testing.B) {
+	const pipeline = 100
+	for _, bench := range []struct {
+		name     string
+		buffered bool
+	}{
+		{"buffered", true},
+		{"unbuffered", false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			conn := discardingConn(b)
+			w := newRespWriter(conn)
+			b.ResetTimer()
+			for range b.N {
+				for range pipeline {
+					w.WriteSimpleString("OK")
+					if !bench.buffered {
+						w.Flush()
+					}
+				}
+				if err := w.Flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*pipeline)/b.Elapsed().Seconds(), "sets/s")
+		})
+	}
+}
+
+// discardingConn returns a loopback TCP connection whose peer reads and
+// discards everything written to it.
+func discardingConn(b *testing.B) net.Conn {
+	b.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { listener.Close() })
+	go func() {
+		peer, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, peer)
+		peer.Close()
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	return conn
+}
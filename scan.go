@@ -0,0 +1,74 @@
+package goredis
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"math"
+	"time"
+)
+
+// scanDefaultCount is the number of keys SCAN visits when COUNT is not given.
+const scanDefaultCount = 10
+
+// scanEntry is a key along with its position in scan order.
+type scanEntry struct {
+	hash uint64
+	key  string
+}
+
+// scanHeap is a max-heap on hash, used to keep the count smallest hashes seen
+// during a pass over the keyspace.
+type scanHeap []scanEntry
+
+func (h scanHeap) Len() int           { return len(h) }
+func (h scanHeap) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h scanHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scanHeap) Push(x any)        { *h = append(*h, x.(scanEntry)) }
+func (h *scanHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// scanKeys returns the next batch of about count keys in scan order starting
+// at cursor, and the cursor to continue from, which is 0 once every key has
+// been visited.
+//
+// Scan order is the order of each key's hash under a seed fixed for the life
+// of the server, and the cursor is the lowest hash not yet visited. Because
+// the cursor only moves forward through a fixed order, every key that exists
+// for the whole scan is returned exactly once no matter how the map is
+// modified in between. Two keys would have to collide on a 64-bit hash right
+// at a batch boundary for one of them to be skipped. Each call is a single O(N) pass over the keyspace, but
+// unlike KEYS it only holds on to count keys and builds a small reply.
+// Callers must hold dbLock.
+func (s *server) scanKeys(cursor uint64, count int) ([]string, uint64) {
+	batch := make(scanHeap, 0, count)
+	now := time.Now()
+	for key := range s.database {
+		if s.isExpired(key, now) {
+			continue
+		}
+		hash := maphash.String(s.scanSeed, key)
+		if hash < cursor {
+			continue
+		}
+		if batch.Len() < count {
+			heap.Push(&batch, scanEntry{hash, key})
+		} else if hash < batch[0].hash {
+			batch[0] = scanEntry{hash, key}
+			heap.Fix(&batch, 0)
+		}
+	}
+
+	keys := make([]string, batch.Len())
+	for i, entry := range batch {
+		keys[i] = entry.key
+	}
+	if batch.Len() < count || batch[0].hash == math.MaxUint64 {
+		// Everything from cursor onwards fit in this batch.
+		return keys, 0
+	}
+	return keys, batch[0].hash + 1
+}
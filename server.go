@@ -1,31 +1,654 @@
 package goredis
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
 	"log/slog"
 	"net"
+	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+)
+
+// defaultIdleTimeout is how long a client may stay silent before its
+// connection is closed, unless changed with WithIdleTimeout.
+const defaultIdleTimeout = 5 * time.Minute
+
+// defaultShutdownTimeout is how long Stop waits for clients to finish their
+// commands unless changed with WithShutdownTimeout.
+const defaultShutdownTimeout = 10 * time.Second
+
+// defaultMaxClients is the connection limit unless changed with
+// WithMaxClients.
+const defaultMaxClients = 10000
+
+// acceptRetryMinDelay and acceptRetryMaxDelay bound the backoff after a
+// temporary Accept error such as running out of file descriptors.
+const (
+	acceptRetryMinDelay = 5 * time.Millisecond
+	acceptRetryMaxDelay = time.Second
 )
 
 type server struct {
 	listener net.Listener
 	logger   *slog.Logger
+	// unixSocketPath is a Unix socket that Start accepts connections on in
+	// addition to listener. Empty disables it.
+	unixSocketPath string
+
+	// configLock guards config, the settings CONFIG SET can change.
+	configLock sync.RWMutex
+	config     serverConfig
+	password   string
+	// debugEnabled allows the DEBUG command.
+	debugEnabled bool
+	// snapshotPath is the file SAVE and BGSAVE write and Start loads. Empty
+	// disables snapshots.
+	snapshotPath string
+	// appendOnlyPath is the append only file, which replaces the snapshot
+	// as the source of the keyspace at startup. Empty disables it.
+	appendOnlyPath string
+
+	started      atomic.Bool
+	startTime    time.Time
+	clients      map[int64]*client
+	lastClientId int64
+	clientsLock  sync.Mutex
+	shuttingDown bool
+	// unixListener listens on unixSocketPath once Start opened it. It is
+	// guarded by clientsLock.
+	unixListener net.Listener
+	done         chan struct{}
+
+	// Counters reported by INFO.
+	totalConnections    atomic.Int64
+	rejectedConnections atomic.Int64
+	totalCommands       atomic.Int64
+	evictedKeys         atomic.Int64
+	// netInputBytes and netOutputBytes count the bytes read from and
+	// written to client connections.
+	netInputBytes  atomic.Int64
+	netOutputBytes atomic.Int64
+	// outputLimitDisconnections counts the clients disconnected for not
+	// reading what was sent to them.
+	outputLimitDisconnections atomic.Int64
+	// syncFull counts the replicas sent a snapshot, and syncPartialOk and
+	// syncPartialErr the requests to continue a stream that succeeded and
+	// failed.
+	syncFull       atomic.Int64
+	syncPartialOk  atomic.Int64
+	syncPartialErr atomic.Int64
+	// commandStats maps each command name to its statistics, reported by
+	// INFO commandstats. It is guarded by statsLock.
+	statsLock    sync.Mutex
+	commandStats map[string]*commandStat
+	// slowlog holds the slow log entries, oldest first, and slowlogNextId
+	// is the id of the next one. Both are guarded by slowlogLock.
+	slowlogLock   sync.Mutex
+	slowlog       []slowlogEntry
+	slowlogNextId int64
+
+	// ctx is cancelled when Stop begins, telling connection handlers tracked
+	// by connections and background goroutines tracked by background to
+	// return.
+	ctx             context.Context
+	cancel          context.CancelFunc
+	connections     sync.WaitGroup
+	background      sync.WaitGroup
+	shutdownTimeout time.Duration
+
+	// execLock is held for reading while a command runs and for writing
+	// while EXEC runs a transaction, so no other command interleaves with it.
+	execLock sync.RWMutex
+	dbLock   sync.RWMutex
+	database map[string]*entry
+	// usedMemory estimates the memory used by the keyspace. It is guarded
+	// by dbLock.
+	usedMemory  int64
+	expirations map[string]time.Time
+	// watchedKeys holds the keys clients WATCH. It is guarded by dbLock.
+	watchedKeys map[string]*watchedKey
+	// blockedKeys maps each key to the clients blocked on it. It is guarded
+	// by dbLock.
+	blockedKeys map[string][]*blockedClient
+	// scanSeed fixes the key order used by SCAN cursors.
+	scanSeed maphash.Seed
+	// activeExpireDisabled pauses expireCycle, as set by DEBUG
+	// SET-ACTIVE-EXPIRE 0.
+	activeExpireDisabled atomic.Bool
+	// saving is set while a BGSAVE is writing the snapshot.
+	saving atomic.Bool
+	// lastSave is the Unix time of the last successful SAVE or BGSAVE, or
+	// of the start of the server if there was none yet.
+	lastSave atomic.Int64
+	// notifications queues keyspace notifications for notifyCycle, which
+	// notificationsReady wakes up. notifying is the subscriber notifyCycle
+	// is writing to and notifyingSince when it started, or nil. They are
+	// guarded by notificationsLock.
+	notificationsLock  sync.Mutex
+	notifications      []notification
+	notificationsReady chan struct{}
+	notifying          *client
+	notifyingSince     time.Time
+	// appendOnly is the open append only file, or nil when disabled.
+	appendOnly *appendOnlyFile
+	// inExec is set while EXEC runs its commands, and multiPropagated once
+	// the first write among them was propagated after a MULTI, so EXEC can
+	// close the transaction in the append only file and the replication
+	// stream. Both are guarded by dbLock.
+	inExec          bool
+	multiPropagated bool
+
+	// masterHost and masterPort locate the master this server replicates,
+	// and are empty when it is a master itself. masterLinkUp records that
+	// the replica is in sync, and stopReplication ends the link. They are
+	// guarded by replicationLock.
+	replicationLock sync.Mutex
+	masterHost      string
+	masterPort      string
+	masterLinkUp    bool
+	stopReplication context.CancelFunc
+	// replicationId identifies the data set this server sends to its
+	// replicas.
+	replicationId string
+	// replicas maps each connected replica to the stream of write commands
+	// waiting to be sent to it. replicationOffset counts the bytes of every
+	// command streamed to replicas so far, backlog keeps the last of them
+	// once a replica connected, and replicasAcked is closed and replaced
+	// whenever a replica acknowledges a new offset. They are guarded by
+	// replicasLock.
+	replicasLock      sync.Mutex
+	replicas          map[*client]*replicaStream
+	replicationOffset int64
+	backlog           *replicationBacklog
+	replicasAcked     chan struct{}
 
-	started atomic.Bool
+	// monitors holds the clients that sent MONITOR. It is guarded by
+	// monitorsLock.
+	monitorsLock sync.Mutex
+	monitors     map[*client]struct{}
+
+	// channels and patterns map each channel or pattern to its subscribers.
+	pubsubLock sync.Mutex
+	channels   map[string]map[*client]struct{}
+	patterns   map[string]map[*client]struct{}
 }
 
-func NewServer(listener net.Listener, logger *slog.Logger) *server {
-	return &server{
+func NewServer(listener net.Listener, logger *slog.Logger, options ...Option) *server {
+	s := &server{
 		listener: listener,
 		logger:   logger,
 
-		started: atomic.Bool{},
+		configLock: sync.RWMutex{},
+		config: serverConfig{
+			idleTimeout:     defaultIdleTimeout,
+			maxClients:      defaultMaxClients,
+			maxMemory:       0,
+			maxMemoryPolicy: NoEviction,
+			appendFsync:     FsyncEverysec,
+			replBacklogSize: defaultReplBacklogSize,
+
+			listMaxListpackSize:  defaultListMaxListpackSize,
+			listMaxListpackValue: listpackValueMax,
+
+			slowlogLogSlowerThan: defaultSlowlogLogSlowerThan,
+			slowlogMaxLen:        defaultSlowlogMaxLen,
+		},
+
+		started:      atomic.Bool{},
+		clients:      make(map[int64]*client),
+		lastClientId: 0,
+		clientsLock:  sync.Mutex{},
+		shuttingDown: false,
+		done:         make(chan struct{}),
+
+		statsLock:    sync.Mutex{},
+		commandStats: make(map[string]*commandStat),
+
+		slowlogLock: sync.Mutex{},
+
+		connections:     sync.WaitGroup{},
+		background:      sync.WaitGroup{},
+		shutdownTimeout: defaultShutdownTimeout,
+
+		execLock:    sync.RWMutex{},
+		dbLock:      sync.RWMutex{},
+		database:    make(map[string]*entry),
+		expirations: make(map[string]time.Time),
+		watchedKeys: make(map[string]*watchedKey),
+		blockedKeys: make(map[string][]*blockedClient),
+		scanSeed:    maphash.MakeSeed(),
+
+		notificationsReady: make(chan struct{}, 1),
+
+		replicationLock: sync.Mutex{},
+		replicationId:   newReplicationId(),
+		replicasLock:    sync.Mutex{},
+		replicas:        make(map[*client]*replicaStream),
+		replicasAcked:   make(chan struct{}),
+
+		monitorsLock: sync.Mutex{},
+		monitors:     make(map[*client]struct{}),
+
+		pubsubLock: sync.Mutex{},
+		channels:   make(map[string]map[*client]struct{}),
+		patterns:   make(map[string]map[*client]struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, option := range options {
+		option(s)
 	}
+	return s
 }
 
+// Start accepts connections until the server is stopped. It returns nil
+// after a clean Stop and an error if the server was already started, was
+// stopped before it started, or the listener failed.
 func (s *server) Start() error {
-	panic("todo")
+	s.clientsLock.Lock()
+	isShuttingDown := s.shuttingDown
+	s.clientsLock.Unlock()
+	if isShuttingDown {
+		return errors.New("server is stopped")
+	}
+	if !s.started.CompareAndSwap(false, true) {
+		return errors.New("server already started")
+	}
+	// Loading the data can raise notifications already.
+	s.background.Add(1)
+	go s.notifyCycle()
+	if s.appendOnlyPath != "" {
+		if err := s.loadAppendOnlyFile(); err != nil {
+			return fmt.Errorf("cannot load append only file: %w", err)
+		}
+		appendOnly, err := openAppendOnlyFile(s.appendOnlyPath, s.getConfig().appendFsync)
+		if err != nil {
+			return fmt.Errorf("cannot open append only file: %w", err)
+		}
+		s.appendOnly = appendOnly
+		s.background.Add(1)
+		go s.appendFsyncCycle()
+	} else if err := s.loadSnapshot(); err != nil {
+		return fmt.Errorf("cannot load snapshot: %w", err)
+	}
+	s.startTime = time.Now()
+	s.lastSave.Store(s.startTime.Unix())
+	if s.unixSocketPath != "" {
+		unixListener, err := listenUnix(s.unixSocketPath)
+		if err != nil {
+			return fmt.Errorf("cannot listen on unix socket: %w", err)
+		}
+		s.clientsLock.Lock()
+		if s.shuttingDown {
+			// Stop already closed the listeners it knew about.
+			unixListener.Close()
+		} else {
+			s.logger.Info("accepting connections on unix socket", slog.String("path", s.unixSocketPath))
+			s.unixListener = unixListener
+			s.background.Add(1)
+			go func() {
+				defer s.background.Done()
+				if err := s.acceptConnections(unixListener); err != nil {
+					s.logger.Error("unix socket stopped", slog.String("err", err.Error()))
+				}
+			}()
+		}
+		s.clientsLock.Unlock()
+	}
+	s.logger.Info("server started", slog.String("address", s.listener.Addr().String()))
+
+	s.background.Add(1)
+	go s.expireCycle()
+
+	s.replicationLock.Lock()
+	if s.masterHost != "" {
+		s.connectToMaster()
+	}
+	s.replicationLock.Unlock()
+
+	return s.acceptConnections(s.listener)
+}
+
+// listenUnix listens on the Unix socket at path, replacing the socket file a
+// previous run may have left behind. The file is removed again when the
+// listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	return net.Listen("unix", path)
 }
 
+// acceptConnections serves the connections accepted by listener until the
+// server is stopped, and returns nil then or an error if listener failed.
+func (s *server) acceptConnections(listener net.Listener) error {
+	retryDelay := time.Duration(0)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.clientsLock.Lock()
+			isShuttingDown := s.shuttingDown
+			s.clientsLock.Unlock()
+
+			if isShuttingDown {
+				return nil
+			}
+			// Temporary is deprecated because few errors are reliably
+			// temporary, but Accept errors such as EMFILE still are, and
+			// net/http retries them the same way.
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				retryDelay = min(max(2*retryDelay, acceptRetryMinDelay), acceptRetryMaxDelay)
+				s.logger.Error("cannot accept connection, retrying", slog.Duration("delay", retryDelay), slog.String("err", err.Error()))
+				select {
+				case <-time.After(retryDelay):
+				case <-s.ctx.Done():
+				}
+				continue
+			}
+			return fmt.Errorf("cannot accept connection: %w", err)
+		}
+		retryDelay = 0
+
+		s.totalConnections.Add(1)
+		s.clientsLock.Lock()
+		if s.shuttingDown {
+			s.clientsLock.Unlock()
+			conn.Close()
+			return nil
+		}
+		if len(s.clients) >= s.getConfig().maxClients {
+			s.clientsLock.Unlock()
+			s.rejectedConnections.Add(1)
+			s.logger.Warn("max number of clients reached", slog.String("host", conn.RemoteAddr().String()))
+			conn.Write([]byte("-ERR max number of clients reached\r\n"))
+			conn.Close()
+			continue
+		}
+		s.lastClientId += 1
+		c := newClient(s.lastClientId, conn, &s.netInputBytes, &s.netOutputBytes)
+		s.clients[c.id] = c
+		s.connections.Add(1)
+		s.clientsLock.Unlock()
+
+		go s.handleConn(c)
+	}
+}
+
+// Stop stops accepting connections and lets every client finish the command
+// it is running. Connections still busy after the shutdown timeout are
+// closed. It is safe to call more than once; calls after the first are
+// no-ops.
 func (s *server) Stop() error {
-	panic("todo")
+	s.clientsLock.Lock()
+	if s.shuttingDown {
+		s.clientsLock.Unlock()
+		return nil
+	}
+	s.shuttingDown = true
+	s.cancel()
+	for _, c := range s.clients {
+		// Wake up handlers waiting for a request. A handler that is running a
+		// command finishes it and sends the reply before it notices.
+		c.conn.SetReadDeadline(time.Now())
+	}
+	unixListener := s.unixListener
+	s.clientsLock.Unlock()
+
+	err := s.listener.Close()
+	if err != nil {
+		s.logger.Error("cannot stop listener", slog.String("err", err.Error()))
+	}
+	if unixListener != nil {
+		// Closing the listener also removes the socket file.
+		if err := unixListener.Close(); err != nil {
+			s.logger.Error("cannot stop unix socket listener", slog.String("err", err.Error()))
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.connections.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(s.shutdownTimeout):
+		s.clientsLock.Lock()
+		for clientId, c := range s.clients {
+			s.logger.Warn("closing client that did not finish in time", slog.Int64("clientId", clientId))
+			c.conn.Close()
+		}
+		s.clientsLock.Unlock()
+		<-drained
+	}
+
+	s.background.Wait()
+	close(s.done)
+	s.logger.Info("server stopped")
+	return err
+}
+
+// Started reports whether Start has been called.
+func (s *server) Started() bool {
+	return s.started.Load()
+}
+
+// Done returns a channel that is closed once Stop has completed.
+func (s *server) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *server) handleConn(c *client) {
+	defer s.connections.Done()
+	s.logger.Info(
+		"client connected",
+		slog.Int64("clientId", c.id),
+		slog.String("host", c.conn.RemoteAddr().String()),
+	)
+
+	for {
+		if idleTimeout := s.getConfig().idleTimeout; idleTimeout > 0 {
+			if s.isSubscribed(c) || c.isReplica || c.isMonitor {
+				// Subscribers wait for messages, replicas for write
+				// commands and monitors for any command, so they are never
+				// idle.
+				c.conn.SetReadDeadline(time.Time{})
+			} else {
+				c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+			}
+		}
+		// Checking after the deadline is set means either this sees the
+		// shutdown, or Stop's deadline replaces the one set above.
+		if s.ctx.Err() != nil {
+			break
+		}
+		command, err := readRequest(c.reader)
+		if err != nil {
+			switch {
+			case s.ctx.Err() != nil:
+				// Stop interrupted the read.
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				s.logger.Info("client idle timeout", slog.Int64("clientId", c.id))
+			case !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed):
+				s.logger.Error("error reading from client", slog.Int64("clientId", c.id), slog.String("err", err.Error()))
+				c.writer.WriteError(fmt.Sprintf("ERR Protocol error: %s", err))
+			}
+			break
+		}
+		if len(command) == 0 {
+			continue
+		}
+
+		s.logger.Debug("command received", slog.Int64("clientId", c.id), slog.String("command", command[0]))
+
+		s.totalCommands.Add(1)
+		err = s.dispatch(c, command)
+		s.syncAppendOnly()
+		if err == nil && c.closeAfterReply {
+			break
+		}
+		if err == nil && c.reader.Buffered() == 0 {
+			// Replies to pipelined commands are sent together once every
+			// command already received has been processed, and before
+			// blocking on the next read.
+			err = c.writer.Flush()
+		}
+		if err != nil {
+			s.logger.Error("error writing to client", slog.Int64("clientId", c.id), slog.String("err", err.Error()))
+			break
+		}
+	}
+
+	// Deliver anything still buffered, such as a protocol error reply.
+	c.writer.Flush()
+	s.unsubscribeAll(c)
+	s.unwatchAll(c)
+	s.removeReplica(c)
+	s.removeMonitor(c)
+
+	s.clientsLock.Lock()
+	delete(s.clients, c.id)
+	s.clientsLock.Unlock()
+
+	s.logger.Info("client disconnecting", slog.Int64("clientId", c.id))
+	if err := c.conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Error("cannot close client", slog.Int64("clientId", c.id), slog.String("err", err.Error()))
+	}
+}
+
+// dispatch runs command for c and writes its reply.
+func (s *server) dispatch(c *client, command []string) error {
+	upperName := strings.ToUpper(command[0])
+	if s.password != "" && !c.authenticated && upperName != "AUTH" && upperName != "HELLO" && upperName != "PING" && upperName != "QUIT" {
+		return c.writer.WriteError("NOAUTH Authentication required.")
+	}
+	// Commands that could never run, because they are unknown or have the
+	// wrong number of arguments, are rejected before reaching their handler,
+	// so handlers can index their arguments without checking. Inside a
+	// transaction they also make EXEC abort.
+	name := strings.ToLower(command[0])
+	info, ok := commandTable[name]
+	if !ok || !checkArity(info, len(command)) {
+		if c.inMulti {
+			c.multiFailed = true
+		}
+		if !ok {
+			s.logger.Error("unknown command", slog.Int64("clientId", c.id), slog.String("command", command[0]))
+			return c.writer.WriteError(unknownCommandError(command))
+		}
+		return c.writer.WriteError(arityError(name))
+	}
+	// Commands replayed from the append only file or the master are not
+	// shown to monitors, and monitors only run QUIT.
+	switch {
+	case c.isMonitor && upperName != "QUIT":
+		return nil
+	case !c.isMonitor && c.conn != nil:
+		s.feedMonitors(c, command)
+	}
+	if s.inSubscriberMode(c) {
+		switch upperName {
+		case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "PING", "QUIT":
+		default:
+			return c.writer.WriteError(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name))
+		}
+	}
+
+	switch upperName {
+	case "MULTI", "EXEC", "DISCARD", "WATCH":
+		defer s.recordCall(c, command, time.Now())
+	}
+	switch upperName {
+	case "MULTI":
+		return s.handleMultiCommand(c, command)
+	case "EXEC":
+		return s.handleExecCommand(c, command)
+	case "DISCARD":
+		return s.handleDiscardCommand(c, command)
+	case "WATCH":
+		return s.handleWatchCommand(c, command)
+	case "BLPOP", "BRPOP":
+		if !c.inMulti {
+			defer s.recordCall(c, command, time.Now())
+			// Blocking pops wait without holding execLock, which would
+			// stall every transaction in the meantime.
+			return s.waitForPop(c, command)
+		}
+	case "WAIT":
+		if !c.inMulti {
+			defer s.recordCall(c, command, time.Now())
+			// Likewise WAIT only holds up its own connection.
+			return s.waitForReplicas(c, command)
+		}
+	case "DEBUG":
+		if !c.inMulti && isDebugSleep(command) {
+			// Likewise DEBUG SLEEP only stalls its own connection.
+			defer s.recordCall(c, command, time.Now())
+			return s.handleDebugCommand(c, command)
+		}
+	}
+	if c.inMulti && upperName != "QUIT" {
+		return s.queueCommand(c, command)
+	}
+
+	s.execLock.RLock()
+	defer s.execLock.RUnlock()
+	return s.call(c, upperName, command)
+}
+
+// call runs a command that is not handled by dispatch itself through its
+// handler in commandTable. upperName is the command name in upper case.
+func (s *server) call(c *client, upperName string, command []string) error {
+	// The append only file is replayed by a client without a connection,
+	// and loading it is never refused.
+	// Replicas only apply the writes of their master, which has no
+	// connection either.
+	if c.conn != nil && isWriteCommand(upperName) && s.isReplica() {
+		return c.writer.WriteError("READONLY You can't write against a read only replica.")
+	}
+	if c.conn != nil && denyOOM(upperName) && !s.freeMemory() {
+		return c.writer.WriteError("OOM command not allowed when used memory > 'maxmemory'.")
+	}
+	if c.conn != nil {
+		defer s.recordCall(c, command, time.Now())
+	}
+
+	info, ok := commandTable[strings.ToLower(command[0])]
+	if !ok {
+		// dispatch has already rejected commands missing from commandTable.
+		return c.writer.WriteError(unknownCommandError(command))
+	}
+	return info.handler(s, c, command)
+}
+
+// unknownArgsLimit caps how much of the arguments unknownCommandError
+// quotes, like maxUnknownArgs in Redis.
+const unknownArgsLimit = 128
+
+// unknownCommandError formats the error for a command the server does not
+// implement in the same words as Redis, quoting the first arguments so the
+// client can tell what was sent.
+func unknownCommandError(command []string) string {
+	var args strings.Builder
+	for _, arg := range command[1:] {
+		if args.Len() >= unknownArgsLimit {
+			break
+		}
+		fmt.Fprintf(&args, "'%s' ", truncate(arg, unknownArgsLimit-args.Len()))
+	}
+	return fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", truncate(command[0], unknownArgsLimit), args.String())
+}
+
+// truncate returns the first n bytes of s.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
 }
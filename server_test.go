@@ -0,0 +1,233 @@
+package goredis_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// temporaryError is an Accept error such as running out of file
+// descriptors, which goes away on its own.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// failingListener returns err from its first failures calls to Accept, or
+// from every call if failures is negative, and then accepts connections from
+// the listener it wraps.
+type failingListener struct {
+	net.Listener
+	err      error
+	failures int
+	accepts  atomic.Int64
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if n := l.accepts.Add(1); l.failures < 0 || n <= int64(l.failures) {
+		return nil, l.err
+	}
+	return l.Listener.Accept()
+}
+
+// startWithListener starts a server on listener. It returns the server's
+// Stop and a channel that receives what Start returned.
+func startWithListener(t *testing.T, listener net.Listener) (stop func() error, started chan error) {
+	t.Helper()
+	server := goredis.NewServer(listener, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	started = make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+	t.Cleanup(func() { server.Stop() })
+	return server.Stop, started
+}
+
+// waitForStart waits for Start to return.
+func waitForStart(t *testing.T, started chan error) error {
+	t.Helper()
+	select {
+	case err := <-started:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return")
+		return nil
+	}
+}
+
+func TestAcceptRetriesTemporaryErrors(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &failingListener{Listener: inner, err: temporaryError{}, failures: 3}
+	stop, started := startWithListener(t, listener)
+
+	c := redistest.Connect(t, inner.Addr().String())
+	run(t, c, []step{{[]string{"PING"}, "+PONG\r\n"}})
+	if n := listener.accepts.Load(); n < 4 {
+		t.Errorf("Accept was called %d times, want at least 4", n)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if err := waitForStart(t, started); err != nil {
+		t.Errorf("Start() = %v after Stop, want nil", err)
+	}
+}
+
+func TestAcceptFailsOnFatalError(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fatal := errors.New("listener broke")
+	_, started := startWithListener(t, &failingListener{Listener: inner, err: fatal, failures: 1})
+
+	if err := waitForStart(t, started); !errors.Is(err, fatal) {
+		t.Errorf("Start() = %v, want %v", err, fatal)
+	}
+}
+
+func TestStopWhileAcceptRetries(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &failingListener{Listener: inner, err: temporaryError{}, failures: -1}
+	stop, started := startWithListener(t, listener)
+
+	// After 8 failures the server waits 640ms before it retries. Stop
+	// must not wait for that.
+	deadline := time.Now().Add(5 * time.Second)
+	for listener.accepts.Load() < 8 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	begin := time.Now()
+	if err := stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if err := waitForStart(t, started); err != nil {
+		t.Errorf("Start() = %v after Stop, want nil", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 300*time.Millisecond {
+		t.Errorf("stopping took %v while Accept was retrying", elapsed)
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := goredis.NewServer(listener, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	started := make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+	c := redistest.Connect(t, listener.Addr().String())
+	run(t, c, []step{{[]string{"PING"}, "+PONG\r\n"}})
+	if !server.Started() {
+		t.Error("Started() = false after Start")
+	}
+
+	select {
+	case <-server.Done():
+		t.Fatal("Done is closed before Stop")
+	default:
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	select {
+	case <-server.Done():
+	default:
+		t.Fatal("Done is not closed after Stop")
+	}
+	if err := waitForStart(t, started); err != nil {
+		t.Errorf("Start() = %v after Stop, want nil", err)
+	}
+
+	if err := server.Stop(); err != nil {
+		t.Errorf("second Stop() = %v, want nil", err)
+	}
+	if err := server.Start(); err == nil {
+		t.Error("Start() after Stop = nil, want an error")
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := goredis.NewServer(listener, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if server.Started() {
+		t.Error("Started() = true before Start")
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	<-server.Done()
+	if err := server.Start(); err == nil {
+		t.Error("Start() after Stop = nil, want an error")
+	}
+}
+
+func TestStopDrainsConnections(t *testing.T) {
+	addr, stop := redistest.StartServer(t, goredis.WithDebugCommand(true))
+	idle := redistest.Dial(t, addr)
+	partial := redistest.Dial(t, addr)
+	sleeper := redistest.Connect(t, addr)
+
+	io.WriteString(partial, "*2\r\n$3\r\nGET\r\n")
+	sleeper.Send("DEBUG", "SLEEP", "10")
+	// Give the server time to start sleeping.
+	time.Sleep(50 * time.Millisecond)
+
+	begin := time.Now()
+	stop()
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Stop took %v", elapsed)
+	}
+	// The command that was running finished and was answered, and every
+	// connection was then closed.
+	if got := sleeper.Receive(); got != "+OK\r\n" {
+		t.Errorf("DEBUG SLEEP = %q, want +OK", got)
+	}
+	for _, conn := range []net.Conn{idle, partial, sleeper.Conn()} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if n, err := conn.Read(make([]byte, 64)); err != io.EOF {
+			t.Errorf("read %d bytes, %v after Stop, want EOF", n, err)
+		}
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	addr, stop := redistest.StartServer(t, goredis.WithShutdownTimeout(200*time.Millisecond))
+	c := redistest.Connect(t, addr)
+	run(t, c, []step{{[]string{"SET", "key", strings.Repeat("x", 1<<20)}, "+OK\r\n"}})
+
+	// A client that does not read its replies leaves the server stuck
+	// writing to it until the shutdown timeout closes the connection.
+	for range 64 {
+		c.Send("GET", "key")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	begin := time.Now()
+	stop()
+	if elapsed := time.Since(begin); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Stop took %v, want about the 200ms shutdown timeout", elapsed)
+	}
+}
@@ -0,0 +1,87 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSetAlgebra(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SADD", "a", "w", "x", "y", "z"}, ":4\r\n"},
+		{[]string{"SADD", "b", "x", "y", "q"}, ":3\r\n"},
+		{[]string{"SADD", "c", "y", "x", "r", "s", "t"}, ":5\r\n"},
+		{[]string{"SINTER", "a", "b", "c"}, "*2\r\n$1\r\nx\r\n$1\r\ny\r\n"},
+		{[]string{"SINTER", "a"}, "*4\r\n$1\r\nw\r\n$1\r\nx\r\n$1\r\ny\r\n$1\r\nz\r\n"},
+		// A missing key is an empty set.
+		{[]string{"SINTER", "a", "missing"}, "*0\r\n"},
+		{[]string{"SUNION", "a", "b", "missing"}, "*5\r\n$1\r\nq\r\n$1\r\nw\r\n$1\r\nx\r\n$1\r\ny\r\n$1\r\nz\r\n"},
+		{[]string{"SDIFF", "a", "b", "c"}, "*2\r\n$1\r\nw\r\n$1\r\nz\r\n"},
+		{[]string{"SDIFF", "missing", "a"}, "*0\r\n"},
+
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"SINTER", "a", "string"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"SUNION", "string", "a"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"SDIFF", "a", "string"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"SINTER"}, "-ERR wrong number of arguments for 'sinter' command\r\n"},
+	})
+}
+
+func TestSetAlgebraStore(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SADD", "a", "1", "2", "3", "4"}, ":4\r\n"},
+		{[]string{"SADD", "b", "3", "4", "5"}, ":3\r\n"},
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+
+		{[]string{"SINTERSTORE", "dst", "a", "b"}, ":2\r\n"},
+		{[]string{"SMEMBERS", "dst"}, "*2\r\n$1\r\n3\r\n$1\r\n4\r\n"},
+		{[]string{"SUNIONSTORE", "dst", "a", "b", "missing"}, ":5\r\n"},
+		{[]string{"SCARD", "dst"}, ":5\r\n"},
+		{[]string{"SDIFFSTORE", "dst", "a", "b"}, ":2\r\n"},
+		{[]string{"SMEMBERS", "dst"}, "*2\r\n$1\r\n1\r\n$1\r\n2\r\n"},
+
+		// The destination may be one of the sources, and of any type.
+		{[]string{"SINTERSTORE", "a", "a", "b"}, ":2\r\n"},
+		{[]string{"SMEMBERS", "a"}, "*2\r\n$1\r\n3\r\n$1\r\n4\r\n"},
+		{[]string{"SINTERSTORE", "string", "a", "b"}, ":2\r\n"},
+		{[]string{"TYPE", "string"}, "+set\r\n"},
+
+		// An empty result deletes the destination.
+		{[]string{"SINTERSTORE", "dst", "a", "missing"}, ":0\r\n"},
+		{[]string{"EXISTS", "dst"}, ":0\r\n"},
+
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+		{[]string{"SINTERSTORE", "dst", "a", "string"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"SINTERSTORE", "dst"}, "-ERR wrong number of arguments for 'sinterstore' command\r\n"},
+	})
+}
+
+func TestSintercard(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SADD", "a", "1", "2", "3", "4", "5"}, ":5\r\n"},
+		{[]string{"SADD", "b", "2", "3", "4", "5", "6"}, ":5\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "b"}, ":4\r\n"},
+		{[]string{"SINTERCARD", "1", "a"}, ":5\r\n"},
+		// LIMIT stops counting early, and 0 means no limit.
+		{[]string{"SINTERCARD", "2", "a", "b", "LIMIT", "2"}, ":2\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "b", "limit", "0"}, ":4\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "missing"}, ":0\r\n"},
+
+		{[]string{"SINTERCARD", "0", "a"}, "-ERR numkeys should be greater than 0\r\n"},
+		{[]string{"SINTERCARD", "x", "a"}, "-ERR numkeys should be greater than 0\r\n"},
+		{[]string{"SINTERCARD", "3", "a", "b"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "b", "LIMIT", "-1"}, "-ERR LIMIT can't be negative\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "b", "FOO"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "string", "x"}, "+OK\r\n"},
+		{[]string{"SINTERCARD", "2", "a", "string"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+}
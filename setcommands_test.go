@@ -0,0 +1,87 @@
+package goredis_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSet(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		// Only new members are counted.
+		{[]string{"SADD", "set", "x", "y", "x", "z"}, ":3\r\n"},
+		{[]string{"SADD", "set", "x", "w"}, ":1\r\n"},
+		{[]string{"TYPE", "set"}, "+set\r\n"},
+		{[]string{"SMEMBERS", "set"}, "*4\r\n$1\r\nw\r\n$1\r\nx\r\n$1\r\ny\r\n$1\r\nz\r\n"},
+		{[]string{"SMEMBERS", "missing"}, "*0\r\n"},
+		{[]string{"SISMEMBER", "set", "x"}, ":1\r\n"},
+		{[]string{"SISMEMBER", "set", "q"}, ":0\r\n"},
+		{[]string{"SISMEMBER", "missing", "x"}, ":0\r\n"},
+		{[]string{"SCARD", "set"}, ":4\r\n"},
+		{[]string{"SCARD", "missing"}, ":0\r\n"},
+		{[]string{"SREM", "set", "x", "q"}, ":1\r\n"},
+		{[]string{"SCARD", "set"}, ":3\r\n"},
+		// Removing the last member deletes the key.
+		{[]string{"SREM", "set", "w", "y", "z"}, ":3\r\n"},
+		{[]string{"EXISTS", "set"}, ":0\r\n"},
+		{[]string{"SADD", "set"}, "-ERR wrong number of arguments for 'sadd' command\r\n"},
+
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"SADD", "string", "v"}, wrongType},
+		{[]string{"SMEMBERS", "string"}, wrongType},
+		{[]string{"SCARD", "string"}, wrongType},
+	})
+}
+
+func TestSpop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	addr, stop := redistest.StartServer(t, goredis.WithAppendOnlyFile(path))
+	c := redistest.Connect(t, addr)
+	members := []string{"a", "b", "c"}
+
+	run(t, c, []step{
+		{[]string{"SPOP", "missing"}, "$-1\r\n"},
+		{[]string{"SPOP", "missing", "2"}, "*0\r\n"},
+		{[]string{"SADD", "set", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"SPOP", "set", "0"}, "*0\r\n"},
+		{[]string{"SPOP", "set", "-1"}, "-ERR value is out of range, must be positive\r\n"},
+		{[]string{"SCARD", "set"}, ":3\r\n"},
+	})
+
+	popped := bulkStrings(t, c.Do("SPOP", "set", "2"))
+	checkSample(t, popped, 2, members, true)
+	remaining := bulkStrings(t, c.Do("SMEMBERS", "set"))
+	checkSample(t, remaining, 1, members, true)
+	if slices.Contains(popped, remaining[0]) {
+		t.Errorf("SPOP popped %q but %q remains", popped, remaining)
+	}
+
+	// Popping the last member deletes the key.
+	if got, want := c.Do("SPOP", "set"), "$1\r\n"+remaining[0]+"\r\n"; got != want {
+		t.Errorf("SPOP = %q, want %q", got, want)
+	}
+	run(t, c, []step{
+		{[]string{"EXISTS", "set"}, ":0\r\n"},
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"SPOP", "string"}, wrongType},
+	})
+	stop()
+
+	// The members popped are logged, so replaying the file pops the same
+	// ones.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "SPOP") || strings.Count(string(data), "SREM") != 2 {
+		t.Errorf("append only file = %q, want SPOP logged as SREM", data)
+	}
+}
@@ -0,0 +1,465 @@
+package goredis
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// set is the value of a set key. Sets are never empty; the key is deleted
+// along with the last member.
+type set map[string]struct{}
+
+// sortedMembers returns the members of st in order, so that replies listing
+// them do not depend on map iteration order.
+func (st set) sortedMembers() []any {
+	members := make([]string, 0, len(st))
+	for member := range st {
+		members = append(members, member)
+	}
+	slices.Sort(members)
+	reply := make([]any, len(members))
+	for i, member := range members {
+		reply[i] = member
+	}
+	return reply
+}
+
+// handleSaddCommand serves SADD key member [member ...] and replies with the
+// number of members that were not already in the set.
+func (s *server) handleSaddCommand(c *client, command []string) error {
+	key := command[1]
+
+	added := 0
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	st, ok, wrongType := lookupTyped[set](s, key)
+	if !wrongType {
+		if !ok {
+			st = make(set)
+			s.storeValue(key, st)
+		}
+		for _, member := range command[2:] {
+			if _, exists := st[member]; !exists {
+				st[member] = struct{}{}
+				s.usedMemory += elementSize(member)
+				added += 1
+			}
+		}
+		if added > 0 {
+			s.touchKey(key)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifySet, "sadd", key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(added))
+}
+
+// handleSremCommand serves SREM key member [member ...] and replies with the
+// number of members that were removed.
+func (s *server) handleSremCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	st, ok, wrongType := lookupTyped[set](s, key)
+	removed := 0
+	if ok {
+		removed = s.removeMembers(key, st, command[2:], "srem")
+		if removed > 0 {
+			s.propagate(command...)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(removed))
+}
+
+// removeMembers removes members from st and returns how many were in it.
+// key is deleted when st becomes empty. The removal is notified as event.
+// Callers must hold dbLock for writing.
+func (s *server) removeMembers(key string, st set, members []string, event string) int {
+	removed := 0
+	for _, member := range members {
+		if _, exists := st[member]; exists {
+			delete(st, member)
+			s.usedMemory -= elementSize(member)
+			removed += 1
+		}
+	}
+	if removed > 0 {
+		s.notifyKeyspaceEvent(notifySet, event, key)
+	}
+	if len(st) == 0 {
+		s.deleteKey(key)
+		s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	} else if removed > 0 {
+		s.touchKey(key)
+	}
+	return removed
+}
+
+// handleSpopCommand serves SPOP key [count], which removes random members.
+// Without count the reply is a single member, and with it an array of up to
+// count members. The removal is propagated as SREM, so replaying it removes
+// the same members.
+func (s *server) handleSpopCommand(c *client, command []string) error {
+	key := command[1]
+
+	count := 1
+	if len(command) == 3 {
+		n, err := strconv.Atoi(command[2])
+		if err != nil || n < 0 {
+			return c.writer.WriteError("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	st, ok, wrongType := lookupTyped[set](s, key)
+	popped := []string{}
+	if ok {
+		// Map iteration starts at a random position.
+		for member := range st {
+			if len(popped) == count {
+				break
+			}
+			popped = append(popped, member)
+		}
+		s.removeMembers(key, st, popped, "spop")
+		if len(popped) > 0 {
+			s.propagate(append([]string{"SREM", key}, popped...)...)
+		}
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case len(command) == 2 && !ok:
+		return c.writer.WriteNull()
+	case len(command) == 2:
+		return c.writer.WriteBulkString(popped[0])
+	}
+	reply := make([]any, len(popped))
+	for i, member := range popped {
+		reply[i] = member
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleSrandmemberCommand serves SRANDMEMBER key [count], which replies
+// with random members without removing them. Without count the reply is a
+// single member; with it, an array of up to count distinct members, or of
+// -count members that may repeat when count is negative.
+func (s *server) handleSrandmemberCommand(c *client, command []string) error {
+	key := command[1]
+
+	count := 1
+	if len(command) == 3 {
+		n, message := parseRandomCount(command[2])
+		if message != "" {
+			return c.writer.WriteError(message)
+		}
+		count = n
+	}
+
+	s.dbLock.RLock()
+	st, ok, wrongType := lookupTyped[set](s, key)
+	members := make([]string, 0, len(st))
+	for member := range st {
+		members = append(members, member)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	indexes := randomIndexes(len(members), count)
+	if len(command) == 2 {
+		if len(indexes) == 0 {
+			return c.writer.WriteNull()
+		}
+		return c.writer.WriteBulkString(members[indexes[0]])
+	}
+	reply := make([]any, len(indexes))
+	for i, index := range indexes {
+		reply[i] = members[index]
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleSmembersCommand serves SMEMBERS key, which replies with the members
+// of the set in order.
+func (s *server) handleSmembersCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	st, ok, wrongType := lookupTyped[set](s, key)
+	members := st.sortedMembers()
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(members)
+}
+
+func (s *server) handleSismemberCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	st, ok, wrongType := lookupTyped[set](s, key)
+	_, exists := st[command[2]]
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if !exists {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+func (s *server) handleScardCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	st, ok, wrongType := lookupTyped[set](s, key)
+	size := len(st)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(int64(size))
+}
+
+// lookupSets returns the sets stored at keys, with a nil set for each
+// missing key, and reports whether one of the keys holds another type.
+// Callers must hold dbLock.
+func (s *server) lookupSets(keys []string) ([]set, bool) {
+	sets := make([]set, len(keys))
+	for i, key := range keys {
+		st, _, wrongType := lookupTyped[set](s, key)
+		if wrongType {
+			return nil, true
+		}
+		sets[i] = st
+	}
+	return sets, false
+}
+
+// intersectSets returns the members common to all sets. It walks the
+// smallest set and checks each member against the others, so the cost
+// depends on the smallest set rather than the largest, and a member is
+// dropped as soon as one set lacks it.
+func intersectSets(sets []set) set {
+	smallest := slices.MinFunc(sets, func(a, b set) int {
+		return len(a) - len(b)
+	})
+	result := make(set)
+	for member := range smallest {
+		found := true
+		for _, st := range sets {
+			if _, ok := st[member]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			result[member] = struct{}{}
+		}
+	}
+	return result
+}
+
+// intersectionSize counts the members common to all sets like
+// intersectSets, without collecting them, and stops once it reaches limit
+// unless limit is 0.
+func intersectionSize(sets []set, limit int) int {
+	smallest := slices.MinFunc(sets, func(a, b set) int {
+		return len(a) - len(b)
+	})
+	size := 0
+	for member := range smallest {
+		if limit > 0 && size == limit {
+			break
+		}
+		found := true
+		for _, st := range sets {
+			if _, ok := st[member]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			size += 1
+		}
+	}
+	return size
+}
+
+func unionSets(sets []set) set {
+	result := make(set)
+	for _, st := range sets {
+		for member := range st {
+			result[member] = struct{}{}
+		}
+	}
+	return result
+}
+
+// diffSets returns the members of the first set that are in none of the
+// others.
+func diffSets(sets []set) set {
+	result := make(set)
+	for member := range sets[0] {
+		found := false
+		for _, st := range sets[1:] {
+			if _, ok := st[member]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result[member] = struct{}{}
+		}
+	}
+	return result
+}
+
+// handleSintercardCommand serves SINTERCARD numkeys key [key ...] [LIMIT
+// limit], which replies with the size of the intersection of the sets. With
+// a limit other than 0 it stops counting at limit.
+func (s *server) handleSintercardCommand(c *client, command []string) error {
+	numKeys, err := strconv.Atoi(command[1])
+	if err != nil {
+		return c.writer.WriteError("ERR numkeys should be greater than 0")
+	}
+	if numKeys <= 0 {
+		return c.writer.WriteError("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(command)-2 {
+		return c.writer.WriteError("ERR Number of keys can't be greater than number of args")
+	}
+	keys := command[2 : 2+numKeys]
+
+	limit := 0
+	for i := 2 + numKeys; i < len(command); i += 1 {
+		switch option := strings.ToUpper(command[i]); {
+		case option == "LIMIT" && i+1 < len(command):
+			i += 1
+			n, err := strconv.Atoi(command[i])
+			if err != nil {
+				return c.writer.WriteError("ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				return c.writer.WriteError("ERR LIMIT can't be negative")
+			}
+			limit = n
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.RLock()
+	sets, wrongType := s.lookupSets(keys)
+	size := 0
+	if !wrongType {
+		size = intersectionSize(sets, limit)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(size))
+}
+
+// combineSets applies the set operation of command, one of SINTER, SUNION
+// and SDIFF or their STORE forms, to sets.
+func combineSets(command string, sets []set) set {
+	switch strings.TrimSuffix(strings.ToLower(command), "store") {
+	case "sinter":
+		return intersectSets(sets)
+	case "sunion":
+		return unionSets(sets)
+	default:
+		return diffSets(sets)
+	}
+}
+
+// handleSetAlgebraCommand serves SINTER, SUNION and SDIFF key [key ...],
+// treating missing keys as empty sets.
+func (s *server) handleSetAlgebraCommand(c *client, command []string) error {
+	s.dbLock.RLock()
+	sets, wrongType := s.lookupSets(command[1:])
+	var result set
+	if !wrongType {
+		result = combineSets(command[0], sets)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteArray(result.sortedMembers())
+}
+
+// handleSetAlgebraStoreCommand serves SINTERSTORE, SUNIONSTORE and
+// SDIFFSTORE destination key [key ...], which store what SINTER, SUNION or
+// SDIFF would reply with at destination, whatever it held before, and reply
+// with its size. An empty result deletes destination.
+func (s *server) handleSetAlgebraStoreCommand(c *client, command []string) error {
+	dst := command[1]
+
+	s.dbLock.Lock()
+	for _, key := range command[1:] {
+		s.deleteIfExpired(key)
+	}
+	sets, wrongType := s.lookupSets(command[2:])
+	size := 0
+	if !wrongType {
+		result := combineSets(command[0], sets)
+		size = len(result)
+		existed := s.deleteKey(dst)
+		if size > 0 {
+			s.storeValue(dst, result)
+			s.notifyKeyspaceEvent(notifySet, strings.ToLower(command[0]), dst)
+		} else if existed {
+			s.notifyKeyspaceEvent(notifyGeneric, "del", dst)
+		}
+		if size > 0 || existed {
+			s.propagate(command...)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(size))
+}
@@ -0,0 +1,88 @@
+package goredis
+
+import (
+	"strconv"
+	"testing"
+)
+
+// naiveIntersect intersects the sets in the order given, building the full
+// intermediate result at every step. It is what intersectSets is measured
+// against.
+func naiveIntersect(sets []set) set {
+	result := make(set)
+	for member := range sets[0] {
+		result[member] = struct{}{}
+	}
+	for _, st := range sets[1:] {
+		next := make(set)
+		for member := range result {
+			if _, ok := st[member]; ok {
+				next[member] = struct{}{}
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// newSet returns a set of size members named after the numbers from start.
+func newSet(start int, size int) set {
+	st := make(set, size)
+	for i := start; i < start+size; i += 1 {
+		st[strconv.Itoa(i)] = struct{}{}
+	}
+	return st
+}
+
+func TestIntersectSets(t *testing.T) {
+	sets := []set{newSet(0, 1000), newSet(500, 1000), newSet(900, 20)}
+	want := naiveIntersect(sets)
+	got := intersectSets(sets)
+	if len(got) != len(want) {
+		t.Fatalf("intersectSets has %d members, want %d", len(got), len(want))
+	}
+	for member := range want {
+		if _, ok := got[member]; !ok {
+			t.Errorf("intersectSets is missing %q", member)
+		}
+	}
+	if size := intersectionSize(sets, 0); size != len(want) {
+		t.Errorf("intersectionSize = %d, want %d", size, len(want))
+	}
+	if size := intersectionSize(sets, 5); size != 5 {
+		t.Errorf("intersectionSize with limit 5 = %d, want 5", size)
+	}
+	if size := intersectionSize([]set{newSet(0, 10), nil}, 0); size != 0 {
+		t.Errorf("intersectionSize with a missing set = %d, want 0", size)
+	}
+}
+
+// smallAndLarge returns the case intersectSets is written for: a large set
+// listed before a small one.
+func smallAndLarge() []set {
+	return []set{newSet(0, 100_000), newSet(99_950, 100)}
+}
+
+func BenchmarkIntersectNaive(b *testing.B) {
+	sets := smallAndLarge()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		naiveIntersect(sets)
+	}
+}
+
+func BenchmarkIntersectSets(b *testing.B) {
+	sets := smallAndLarge()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		intersectSets(sets)
+	}
+}
+
+func BenchmarkIntersectionSize(b *testing.B) {
+	sets := smallAndLarge()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		intersectionSize(sets, 0)
+	}
+}
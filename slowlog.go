@@ -0,0 +1,123 @@
+package goredis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSlowlogLogSlowerThan = 10 * time.Millisecond
+	defaultSlowlogMaxLen        = 128
+
+	// slowlogMaxArgs and slowlogMaxArgLength bound how much of a command a
+	// slow log entry keeps, as in Redis.
+	slowlogMaxArgs      = 32
+	slowlogMaxArgLength = 128
+)
+
+// slowlogEntry records a command that ran longer than
+// slowlog-log-slower-than.
+type slowlogEntry struct {
+	id         int64
+	time       time.Time
+	duration   time.Duration
+	args       []string
+	clientAddr string
+	clientName string
+}
+
+// logSlowCommand adds command to the slow log when it took longer than the
+// configured threshold, dropping the oldest entries beyond
+// slowlog-max-len.
+func (s *server) logSlowCommand(c *client, command []string, start time.Time, duration time.Duration) {
+	config := s.getConfig()
+	if config.slowlogLogSlowerThan < 0 || duration < config.slowlogLogSlowerThan {
+		return
+	}
+
+	args := command
+	if len(args) > slowlogMaxArgs {
+		args = make([]string, slowlogMaxArgs)
+		copy(args, command[:slowlogMaxArgs-1])
+		args[slowlogMaxArgs-1] = fmt.Sprintf("... (%d more arguments)", len(command)-slowlogMaxArgs+1)
+	} else {
+		args = append([]string(nil), command...)
+	}
+	for i, arg := range args {
+		if len(arg) > slowlogMaxArgLength {
+			args[i] = fmt.Sprintf("%s... (%d more bytes)", arg[:slowlogMaxArgLength], len(arg)-slowlogMaxArgLength)
+		}
+	}
+
+	s.clientsLock.Lock()
+	clientName := c.name
+	s.clientsLock.Unlock()
+
+	s.slowlogLock.Lock()
+	s.slowlog = append(s.slowlog, slowlogEntry{
+		id:         s.slowlogNextId,
+		time:       start,
+		duration:   duration,
+		args:       args,
+		clientAddr: c.conn.RemoteAddr().String(),
+		clientName: clientName,
+	})
+	s.slowlogNextId += 1
+	if excess := len(s.slowlog) - config.slowlogMaxLen; excess > 0 {
+		s.slowlog = s.slowlog[excess:]
+	}
+	s.slowlogLock.Unlock()
+}
+
+// handleSlowlogCommand serves SLOWLOG GET [count], which replies with the
+// count most recent entries, newest first, SLOWLOG LEN and SLOWLOG RESET.
+func (s *server) handleSlowlogCommand(c *client, command []string) error {
+	switch subcommand := strings.ToUpper(command[1]); {
+	case subcommand == "GET" && len(command) <= 3:
+		count := 10
+		if len(command) == 3 {
+			n, err := strconv.Atoi(command[2])
+			if err != nil || n < -1 {
+				return c.writer.WriteError("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+
+		s.slowlogLock.Lock()
+		if count == -1 || count > len(s.slowlog) {
+			count = len(s.slowlog)
+		}
+		reply := make([]any, count)
+		for i := range reply {
+			entry := s.slowlog[len(s.slowlog)-1-i]
+			args := make([]any, len(entry.args))
+			for j, arg := range entry.args {
+				args[j] = arg
+			}
+			reply[i] = []any{
+				entry.id,
+				entry.time.Unix(),
+				entry.duration.Microseconds(),
+				args,
+				entry.clientAddr,
+				entry.clientName,
+			}
+		}
+		s.slowlogLock.Unlock()
+		return c.writer.WriteArray(reply)
+	case subcommand == "LEN" && len(command) == 2:
+		s.slowlogLock.Lock()
+		length := len(s.slowlog)
+		s.slowlogLock.Unlock()
+		return c.writer.WriteInteger(int64(length))
+	case subcommand == "RESET" && len(command) == 2:
+		s.slowlogLock.Lock()
+		s.slowlog = nil
+		s.slowlogLock.Unlock()
+		return c.writer.WriteSimpleString("OK")
+	default:
+		return c.writer.WriteError(fmt.Sprintf("ERR unknown subcommand or wrong number of arguments for '%s'. Try SLOWLOG HELP.", command[1]))
+	}
+}
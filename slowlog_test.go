@@ -0,0 +1,111 @@
+package goredis_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSlowlog(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithDebugCommand(true), goredis.WithSlowlog(10*time.Millisecond, 128))
+	c := redistest.Connect(t, addr)
+
+	begin := time.Now().Unix()
+	run(t, c, []step{
+		{[]string{"SLOWLOG", "LEN"}, ":0\r\n"},
+		{[]string{"CLIENT", "SETNAME", "me"}, "+OK\r\n"},
+		{[]string{"DEBUG", "SLEEP", "0.02"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$-1\r\n"},
+		{[]string{"SLOWLOG", "LEN"}, ":1\r\n"},
+	})
+
+	// An entry holds an id, when the command started, how many
+	// microseconds it took, its arguments, and the client's address and
+	// name.
+	entry := regexp.MustCompile(`^\*1\r\n\*6\r\n:0\r\n:(\d+)\r\n:(\d+)\r\n` +
+		`\*3\r\n\$5\r\nDEBUG\r\n\$5\r\nSLEEP\r\n\$4\r\n0\.02\r\n` +
+		`\$\d+\r\n` + regexp.QuoteMeta(c.Conn().LocalAddr().String()) + `\r\n\$2\r\nme\r\n$`)
+	reply := c.Do("SLOWLOG", "GET")
+	match := entry.FindStringSubmatch(reply)
+	if match == nil {
+		t.Fatalf("SLOWLOG GET = %q", reply)
+	}
+	if started, _ := strconv.ParseInt(match[1], 10, 64); started < begin || started > time.Now().Unix() {
+		t.Errorf("slow log entry started at %d, want about %d", started, begin)
+	}
+	if usec, _ := strconv.ParseInt(match[2], 10, 64); usec < 20000 {
+		t.Errorf("slow log entry took %dus, want at least 20000", usec)
+	}
+
+	run(t, c, []step{
+		{[]string{"SLOWLOG", "RESET"}, "+OK\r\n"},
+		{[]string{"SLOWLOG", "LEN"}, ":0\r\n"},
+		{[]string{"SLOWLOG", "GET"}, "*0\r\n"},
+		{[]string{"SLOWLOG", "GET", "-2"}, "-ERR count should be greater than or equal to -1\r\n"},
+		{[]string{"SLOWLOG", "FOO"}, "-ERR unknown subcommand or wrong number of arguments for 'FOO'. Try SLOWLOG HELP.\r\n"},
+		// A negative threshold turns the slow log off.
+		{[]string{"CONFIG", "SET", "slowlog-log-slower-than", "-1"}, "+OK\r\n"},
+		{[]string{"DEBUG", "SLEEP", "0.02"}, "+OK\r\n"},
+		{[]string{"SLOWLOG", "LEN"}, ":0\r\n"},
+	})
+}
+
+func TestSlowlogMaxLen(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithSlowlog(0, 3))
+	c := redistest.Connect(t, addr)
+
+	// With a threshold of 0 every command is logged once it finishes,
+	// SLOWLOG included.
+	for i := range 5 {
+		c.Do("SET", "key", strconv.Itoa(i))
+	}
+	run(t, c, []step{{[]string{"SLOWLOG", "LEN"}, ":3\r\n"}})
+
+	// The oldest entries were dropped, and the newest come first.
+	entries := c.Do("SLOWLOG", "GET", "-1")
+	var ids []string
+	for _, id := range regexp.MustCompile(`\*6\r\n:(\d+)\r\n`).FindAllStringSubmatch(entries, -1) {
+		ids = append(ids, id[1])
+	}
+	if got := strings.Join(ids, ","); got != "5,4,3" {
+		t.Errorf("SLOWLOG GET -1 has ids %s, want 5,4,3:\n%q", got, entries)
+	}
+	if !strings.Contains(entries, "\r\n*2\r\n$7\r\nSLOWLOG\r\n$3\r\nLEN\r\n") || !strings.Contains(entries, "\r\n*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$1\r\n4\r\n") {
+		t.Errorf("SLOWLOG GET -1 = %q, want the last SET and SLOWLOG LEN", entries)
+	}
+	if reply := c.Do("SLOWLOG", "GET", "1"); !strings.HasPrefix(reply, "*1\r\n*6\r\n:6\r\n") {
+		t.Errorf("SLOWLOG GET 1 = %q, want entry 6", reply)
+	}
+
+	run(t, c, []step{
+		{[]string{"CONFIG", "SET", "slowlog-max-len", "1"}, "+OK\r\n"},
+		{[]string{"SLOWLOG", "LEN"}, ":1\r\n"},
+	})
+}
+
+func TestSlowlogTruncatesCommands(t *testing.T) {
+	addr, _ := redistest.StartServer(t, goredis.WithSlowlog(0, 128))
+	c := redistest.Connect(t, addr)
+
+	// Entries keep at most 32 arguments of at most 128 bytes.
+	command := []string{"RPUSH", "list", strings.Repeat("x", 200)}
+	for i := range 40 {
+		command = append(command, strconv.Itoa(i))
+	}
+	c.Do(command...)
+	reply := c.Do("SLOWLOG", "GET", "1")
+	for _, want := range []string{
+		fmt.Sprintf("*32\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$147\r\n%s... (72 more bytes)\r\n", strings.Repeat("x", 128)),
+		"\r\n$2\r\n27\r\n$23\r\n... (12 more arguments)\r\n",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("SLOWLOG GET = %q, want it to contain %q", reply, want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package goredis
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A snapshot file starts with snapshotMagic and a version byte, followed by
+// one entry per key and a final snapshotEOF byte. An entry is a value type
+// byte, the expiration time in Unix milliseconds as a big endian int64 (0
+// when the key has none), then the key and the value. Strings are prefixed
+// by their length as a uvarint. Lists are their number of elements as a
+// uvarint followed by the elements as strings, sets the same with their
+// members, hashes their number of fields followed by each field and its
+// value, and sorted sets their number of members followed by each member and
+// its score as a big endian float64.
+const (
+	snapshotMagic   = "GOREDIS"
+	snapshotVersion = 1
+
+	snapshotTypeString = 0
+	snapshotTypeList   = 1
+	snapshotTypeHash   = 2
+	snapshotTypeSet    = 3
+	snapshotTypeZset   = 4
+	snapshotEOF        = 0xff
+)
+
+// writeSnapshot saves database and expirations to path. The snapshot is
+// written to a temporary file that replaces path once complete, so a failed
+// save leaves the previous snapshot intact.
+func writeSnapshot(path string, database map[string]*entry, expirations map[string]time.Time) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encodeSnapshot(writer, database, expirations)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// encodeSnapshot writes database and expirations to writer in the snapshot
+// format.
+func encodeSnapshot(writer *bufio.Writer, database map[string]*entry, expirations map[string]time.Time) {
+	writer.WriteString(snapshotMagic)
+	writer.WriteByte(snapshotVersion)
+	for key, e := range database {
+		var deadline int64
+		if expiration, ok := expirations[key]; ok {
+			deadline = expiration.UnixMilli()
+		}
+		writeSnapshotValue(writer, key, deadline, e.value)
+	}
+	writer.WriteByte(snapshotEOF)
+}
+
+func writeSnapshotValue(writer *bufio.Writer, key string, deadline int64, value any) {
+	switch value := value.(type) {
+	case string:
+		writer.WriteByte(snapshotTypeString)
+		binary.Write(writer, binary.BigEndian, deadline)
+		writeSnapshotString(writer, key)
+		writeSnapshotString(writer, value)
+	case *list:
+		writer.WriteByte(snapshotTypeList)
+		binary.Write(writer, binary.BigEndian, deadline)
+		writeSnapshotString(writer, key)
+		writer.Write(binary.AppendUvarint(nil, uint64(len(value.elements))))
+		for _, element := range value.elements {
+			writeSnapshotString(writer, element)
+		}
+	case hash:
+		writer.WriteByte(snapshotTypeHash)
+		binary.Write(writer, binary.BigEndian, deadline)
+		writeSnapshotString(writer, key)
+		writer.Write(binary.AppendUvarint(nil, uint64(len(value))))
+		for field, fieldValue := range value {
+			writeSnapshotString(writer, field)
+			writeSnapshotString(writer, fieldValue)
+		}
+	case set:
+		writer.WriteByte(snapshotTypeSet)
+		binary.Write(writer, binary.BigEndian, deadline)
+		writeSnapshotString(writer, key)
+		writer.Write(binary.AppendUvarint(nil, uint64(len(value))))
+		for member := range value {
+			writeSnapshotString(writer, member)
+		}
+	case *sortedSet:
+		writer.WriteByte(snapshotTypeZset)
+		binary.Write(writer, binary.BigEndian, deadline)
+		writeSnapshotString(writer, key)
+		writer.Write(binary.AppendUvarint(nil, uint64(len(value.sorted))))
+		for _, m := range value.sorted {
+			writeSnapshotString(writer, m.member)
+			binary.Write(writer, binary.BigEndian, m.score)
+		}
+	default:
+		panic(fmt.Sprintf("unknown value type %T", value))
+	}
+}
+
+func writeSnapshotString(writer *bufio.Writer, s string) {
+	writer.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	writer.WriteString(s)
+}
+
+// readSnapshot loads a file written by writeSnapshot.
+func readSnapshot(path string) (map[string]any, map[string]time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return decodeSnapshot(bufio.NewReader(file))
+}
+
+// decodeSnapshot reads a snapshot in the format writeSnapshot writes. Keys
+// that expired since the snapshot was taken are skipped.
+func decodeSnapshot(reader *bufio.Reader) (map[string]any, map[string]time.Time, error) {
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, nil, errors.New("not a snapshot file")
+	}
+	if version := header[len(snapshotMagic)]; version != snapshotVersion {
+		return nil, nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	database := make(map[string]any)
+	expirations := make(map[string]time.Time)
+	now := time.Now()
+	for {
+		valueType, err := reader.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("truncated snapshot: %w", err)
+		}
+		if valueType == snapshotEOF {
+			return database, expirations, nil
+		}
+		if valueType > snapshotTypeZset {
+			return nil, nil, fmt.Errorf("unknown value type %d", valueType)
+		}
+
+		var deadline int64
+		if err := binary.Read(reader, binary.BigEndian, &deadline); err != nil {
+			return nil, nil, fmt.Errorf("truncated snapshot: %w", err)
+		}
+		key, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		var value any
+		switch valueType {
+		case snapshotTypeString:
+			value, err = readSnapshotString(reader)
+		case snapshotTypeList:
+			value, err = readSnapshotList(reader)
+		case snapshotTypeHash:
+			value, err = readSnapshotHash(reader)
+		case snapshotTypeSet:
+			value, err = readSnapshotSet(reader)
+		case snapshotTypeZset:
+			value, err = readSnapshotZset(reader)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if deadline != 0 {
+			expiration := time.UnixMilli(deadline)
+			if !now.Before(expiration) {
+				continue
+			}
+			expirations[key] = expiration
+		}
+		database[key] = value
+	}
+}
+
+func readSnapshotString(reader *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", fmt.Errorf("truncated snapshot: %w", err)
+	}
+	if n > maxBulkLength {
+		return "", fmt.Errorf("invalid string length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", fmt.Errorf("truncated snapshot: %w", err)
+	}
+	return string(buf), nil
+}
+
+func readSnapshotList(reader *bufio.Reader) (*list, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	l := &list{}
+	for ; n > 0; n -= 1 {
+		element, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+		l.elements = append(l.elements, element)
+	}
+	return l, nil
+}
+
+func readSnapshotHash(reader *bufio.Reader) (hash, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	h := make(hash)
+	for ; n > 0; n -= 1 {
+		field, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+		h[field] = value
+	}
+	return h, nil
+}
+
+func readSnapshotSet(reader *bufio.Reader) (set, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	st := make(set)
+	for ; n > 0; n -= 1 {
+		member, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+		st[member] = struct{}{}
+	}
+	return st, nil
+}
+
+func readSnapshotZset(reader *bufio.Reader) (*sortedSet, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated snapshot: %w", err)
+	}
+	z := newSortedSet()
+	for ; n > 0; n -= 1 {
+		member, err := readSnapshotString(reader)
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if err := binary.Read(reader, binary.BigEndian, &score); err != nil {
+			return nil, fmt.Errorf("truncated snapshot: %w", err)
+		}
+		z.add(member, score)
+	}
+	return z, nil
+}
+
+// loadSnapshot replaces the keyspace with the snapshot file, if one is
+// configured and exists.
+func (s *server) loadSnapshot() error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+	database, expirations, err := readSnapshot(s.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.replaceKeyspace(database, expirations)
+	s.logger.Info("snapshot loaded", slog.String("path", s.snapshotPath), slog.Int("keys", len(database)))
+	return nil
+}
+
+// replaceKeyspace discards every key and stores database and expirations
+// instead.
+func (s *server) replaceKeyspace(database map[string]any, expirations map[string]time.Time) {
+	s.dbLock.Lock()
+	s.flushKeys()
+	for key, value := range database {
+		s.storeValue(key, value)
+	}
+	s.expirations = expirations
+	s.dbLock.Unlock()
+}
+
+func (s *server) handleSaveCommand(c *client, command []string) error {
+	if s.snapshotPath == "" {
+		return c.writer.WriteError("ERR snapshots are disabled")
+	}
+	if s.saving.Load() {
+		return c.writer.WriteError("ERR Background save already in progress")
+	}
+
+	// Holding the read lock keeps writers out for the whole save, so the
+	// snapshot is consistent.
+	s.dbLock.RLock()
+	err := writeSnapshot(s.snapshotPath, s.database, s.expirations)
+	s.dbLock.RUnlock()
+
+	if err != nil {
+		s.logger.Error("cannot save snapshot", slog.String("path", s.snapshotPath), slog.String("err", err.Error()))
+		return c.writer.WriteError(fmt.Sprintf("ERR %s", err))
+	}
+	s.lastSave.Store(time.Now().Unix())
+	s.logger.Info("snapshot saved", slog.String("path", s.snapshotPath))
+	return c.writer.WriteSimpleString("OK")
+}
+
+// handleLastsaveCommand serves LASTSAVE, which replies with the Unix time of
+// the last successful save, so clients can poll for a BGSAVE to finish.
+func (s *server) handleLastsaveCommand(c *client, command []string) error {
+	return c.writer.WriteInteger(s.lastSave.Load())
+}
+
+// handleBgsaveCommand saves a copy of the keyspace taken when the command
+// runs, so clients are not blocked while the file is written.
+func (s *server) handleBgsaveCommand(c *client, command []string) error {
+	if s.snapshotPath == "" {
+		return c.writer.WriteError("ERR snapshots are disabled")
+	}
+	if !s.saving.CompareAndSwap(false, true) {
+		return c.writer.WriteError("ERR Background save already in progress")
+	}
+
+	// Collections are modified in place, so they are copied too.
+	s.dbLock.RLock()
+	database := make(map[string]*entry, len(s.database))
+	for key, e := range s.database {
+		database[key] = &entry{value: copyValue(e.value)}
+	}
+	expirations := maps.Clone(s.expirations)
+	s.dbLock.RUnlock()
+
+	// Registering with background under clientsLock orders the Add before
+	// the Wait in Stop, which sets shuttingDown under the same lock.
+	s.clientsLock.Lock()
+	if s.shuttingDown {
+		s.clientsLock.Unlock()
+		s.saving.Store(false)
+		return c.writer.WriteError("ERR server is shutting down")
+	}
+	s.background.Add(1)
+	s.clientsLock.Unlock()
+
+	go func() {
+		defer s.background.Done()
+		defer s.saving.Store(false)
+
+		if err := writeSnapshot(s.snapshotPath, database, expirations); err != nil {
+			s.logger.Error("cannot save snapshot", slog.String("path", s.snapshotPath), slog.String("err", err.Error()))
+			return
+		}
+		s.lastSave.Store(time.Now().Unix())
+		s.logger.Info("background snapshot saved", slog.String("path", s.snapshotPath))
+	}()
+	return c.writer.WriteSimpleString("Background saving started")
+}
@@ -0,0 +1,113 @@
+package goredis_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	addr, stop := redistest.StartServer(t, goredis.WithSnapshotFile(path))
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "string", "value"}, "+OK\r\n"},
+		{[]string{"SET", "expiring", "value", "EX", "100"}, "+OK\r\n"},
+		{[]string{"SET", "short", "value", "PX", "50"}, "+OK\r\n"},
+		{[]string{"RPUSH", "list", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"HSET", "hash", "field", "value"}, ":1\r\n"},
+		{[]string{"SADD", "set", "x", "y"}, ":2\r\n"},
+		{[]string{"ZADD", "zset", "1.5", "a", "-inf", "b"}, ":2\r\n"},
+		{[]string{"SAVE"}, "+OK\r\n"},
+		// BGSAVE saves the keyspace as it was when it ran.
+		{[]string{"SET", "after-save", "value"}, "+OK\r\n"},
+		{[]string{"BGSAVE"}, "+Background saving started\r\n"},
+		{[]string{"SET", "after-bgsave", "value"}, "+OK\r\n"},
+	})
+	// Stop waits for the background save.
+	stop()
+	// Keys that expire while the server is down are not loaded.
+	time.Sleep(100 * time.Millisecond)
+
+	addr, _ = redistest.StartServer(t, goredis.WithSnapshotFile(path))
+	c = redistest.Connect(t, addr)
+	run(t, c, []step{
+		{[]string{"GET", "string"}, "$5\r\nvalue\r\n"},
+		{[]string{"TTL", "expiring"}, ":100\r\n"},
+		{[]string{"EXISTS", "short"}, ":0\r\n"},
+		{[]string{"LRANGE", "list", "0", "-1"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"HGET", "hash", "field"}, "$5\r\nvalue\r\n"},
+		{[]string{"SCARD", "set"}, ":2\r\n"},
+		{[]string{"ZRANGE", "zset", "0", "-1", "WITHSCORES"}, "*4\r\n$1\r\nb\r\n$4\r\n-inf\r\n$1\r\na\r\n$3\r\n1.5\r\n"},
+		{[]string{"GET", "after-save"}, "$5\r\nvalue\r\n"},
+		{[]string{"EXISTS", "after-bgsave"}, ":0\r\n"},
+		{[]string{"DBSIZE"}, ":7\r\n"},
+	})
+}
+
+func TestSnapshotDisabled(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SAVE"}, "-ERR snapshots are disabled\r\n"},
+		{[]string{"BGSAVE"}, "-ERR snapshots are disabled\r\n"},
+	})
+}
+
+func TestLastsave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	begin := time.Now().Unix()
+	addr, _ := redistest.StartServer(t, goredis.WithSnapshotFile(path))
+	c := redistest.Connect(t, addr)
+
+	// Until the first save, LASTSAVE is when the server started.
+	started := lastsave(t, c)
+	if started < begin || started > time.Now().Unix() {
+		t.Errorf("LASTSAVE = %d, want the start time", started)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	// A failed save leaves it alone. The snapshot cannot replace a
+	// directory.
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if reply := c.Do("SAVE"); !strings.HasPrefix(reply, "-ERR ") {
+		t.Errorf("SAVE = %q, want an error", reply)
+	}
+	if n := lastsave(t, c); n != started {
+		t.Errorf("LASTSAVE = %d after a failed SAVE, want %d", n, started)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	run(t, c, []step{{[]string{"SAVE"}, "+OK\r\n"}})
+	saved := lastsave(t, c)
+	if saved <= started {
+		t.Errorf("LASTSAVE = %d after SAVE, want later than %d", saved, started)
+	}
+	if !strings.Contains(c.Do("INFO", "persistence"), fmt.Sprintf("rdb_last_save_time:%d\r\n", saved)) {
+		t.Errorf("INFO persistence lacks rdb_last_save_time:%d", saved)
+	}
+}
+
+// lastsave returns the time LASTSAVE replies with.
+func lastsave(t *testing.T, c *redistest.Client) int64 {
+	t.Helper()
+	reply := c.Do("LASTSAVE")
+	n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(reply, ":"), "\r\n"), 10, 64)
+	if err != nil {
+		t.Fatalf("LASTSAVE = %q", reply)
+	}
+	return n
+}
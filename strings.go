@@ -0,0 +1,622 @@
+package goredis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setOptions are the conditions and expiration that SET and its variants
+// apply when storing a value.
+type setOptions struct {
+	// onlyIfMissing and onlyIfExists correspond to NX and XX.
+	onlyIfMissing bool
+	onlyIfExists  bool
+	// ttl is the expiration to set; zero means the key does not expire.
+	ttl time.Duration
+	// keepTTL corresponds to KEEPTTL, which leaves the timeout alone.
+	keepTTL bool
+}
+
+// setKey stores value at key unless the NX/XX condition in opts fails, and
+// reports whether it was stored. Any previous timeout is replaced by the one
+// in opts, unless opts keeps it. Callers must hold dbLock for writing.
+func (s *server) setKey(key string, value string, opts setOptions) bool {
+	s.deleteIfExpired(key)
+	_, exists := s.database[key]
+	if (opts.onlyIfMissing && exists) || (opts.onlyIfExists && !exists) {
+		return false
+	}
+
+	s.storeValue(key, value)
+	s.notifyKeyspaceEvent(notifyString, "set", key)
+	switch {
+	case opts.keepTTL:
+		// The timeout of the previous value, if any, carries over.
+	case opts.ttl > 0:
+		s.expirations[key] = time.Now().Add(opts.ttl)
+		s.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	default:
+		delete(s.expirations, key)
+	}
+	return true
+}
+
+// parseExpireTime parses a positive number of seconds or milliseconds for the
+// given command and unit. The returned string is an error message to reply
+// with when the value is invalid.
+func parseExpireTime(name string, value string, unit time.Duration) (time.Duration, string) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, "ERR value is not an integer or out of range"
+	}
+	if n <= 0 || n > math.MaxInt64/int64(unit) {
+		return 0, fmt.Sprintf("ERR invalid expire time in '%s' command", name)
+	}
+	return time.Duration(n) * unit, ""
+}
+
+func (s *server) handleGetCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	value, ok, wrongType := lookupTyped[string](s, key)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(value)
+}
+
+// handleSetCommand serves SET key value [NX | XX] [GET] [EX seconds | PX
+// milliseconds | KEEPTTL]. With GET the reply is the previous value, or null,
+// whether or not the value was stored, and a key holding another type is left
+// alone.
+func (s *server) handleSetCommand(c *client, command []string) error {
+	key := command[1]
+	value := command[2]
+
+	opts := setOptions{}
+	hasExpire := false
+	get := false
+	for i := 3; i < len(command); i += 1 {
+		switch option := strings.ToUpper(command[i]); {
+		case option == "NX" && !opts.onlyIfExists:
+			opts.onlyIfMissing = true
+		case option == "XX" && !opts.onlyIfMissing:
+			opts.onlyIfExists = true
+		case option == "GET" && !get:
+			get = true
+		case (option == "EX" || option == "PX") && !hasExpire && !opts.keepTTL && i+1 < len(command):
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			i += 1
+			ttl, message := parseExpireTime("set", command[i], unit)
+			if message != "" {
+				return c.writer.WriteError(message)
+			}
+			opts.ttl = ttl
+			hasExpire = true
+		case option == "KEEPTTL" && !hasExpire:
+			opts.keepTTL = true
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.Lock()
+	var old string
+	existed, wrongType, ok := false, false, false
+	if get {
+		old, existed, wrongType = lookupTyped[string](s, key)
+	}
+	if !wrongType {
+		ok = s.setKey(key, value, opts)
+		if ok {
+			s.propagateSet(key, value)
+		}
+	}
+	s.dbLock.Unlock()
+
+	switch {
+	case wrongType:
+		return c.writer.WriteError(wrongTypeError)
+	case get && existed:
+		return c.writer.WriteBulkString(old)
+	case get || !ok:
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteSimpleString("OK")
+}
+
+// handleSetexCommand serves both SETEX key seconds value and PSETEX key
+// milliseconds value, which are SET with EX or PX.
+func (s *server) handleSetexCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+	value := command[3]
+
+	unit := time.Second
+	if name == "psetex" {
+		unit = time.Millisecond
+	}
+	ttl, message := parseExpireTime(name, command[2], unit)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	s.setKey(key, value, setOptions{ttl: ttl})
+	s.propagateSet(key, value)
+	s.dbLock.Unlock()
+
+	return c.writer.WriteSimpleString("OK")
+}
+
+// handleSetnxCommand serves SETNX key value, which is SET with NX but replies
+// with an integer.
+func (s *server) handleSetnxCommand(c *client, command []string) error {
+	key := command[1]
+	value := command[2]
+
+	s.dbLock.Lock()
+	ok := s.setKey(key, value, setOptions{onlyIfMissing: true})
+	if ok {
+		s.propagateSet(key, value)
+	}
+	s.dbLock.Unlock()
+
+	if !ok {
+		return c.writer.WriteInteger(0)
+	}
+	return c.writer.WriteInteger(1)
+}
+
+// propagateSet propagates the value and expiration time key now has, with
+// the expiration in absolute form so that replaying it later does not extend
+// the key's life. Callers must hold dbLock for writing.
+func (s *server) propagateSet(key string, value string) {
+	s.propagate("SET", key, value)
+	if deadline, ok := s.expirations[key]; ok {
+		s.propagate("PEXPIREAT", key, strconv.FormatInt(deadline.UnixMilli(), 10))
+	}
+}
+
+// incrementKey adds delta to the integer stored at key, treating a missing
+// key as 0, and returns the new value. The key keeps its timeout. The
+// returned string is an error message to reply with when key does not hold
+// an integer or the result would overflow. Callers must hold dbLock for
+// writing.
+func (s *server) incrementKey(key string, delta int64) (int64, string) {
+	current := int64(0)
+	value, ok, wrongType := lookupTyped[string](s, key)
+	if wrongType {
+		return 0, wrongTypeError
+	}
+	if ok {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, "ERR value is not an integer or out of range"
+		}
+		current = n
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, "ERR increment or decrement would overflow"
+	}
+
+	current += delta
+	s.storeValue(key, strconv.FormatInt(current, 10))
+	return current, ""
+}
+
+func (s *server) handleIncrCommand(c *client, command []string) error {
+	return s.writeIncrement(c, command, 1)
+}
+
+func (s *server) handleDecrCommand(c *client, command []string) error {
+	return s.writeIncrement(c, command, -1)
+}
+
+// writeIncrement applies delta to the key of command and writes the new
+// value, or the error from incrementKey.
+func (s *server) writeIncrement(c *client, command []string, delta int64) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	n, message := s.incrementKey(key, delta)
+	if message == "" {
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyString, "incrby", key)
+	}
+	s.dbLock.Unlock()
+
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+	return c.writer.WriteInteger(int64(n))
+}
+
+func (s *server) handleAppendCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	old, _, wrongType := lookupTyped[string](s, key)
+	value := old + command[2]
+	if !wrongType {
+		s.storeValue(key, value)
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyString, "append", key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(len(value)))
+}
+
+func (s *server) handleStrlenCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	value, ok, wrongType := lookupTyped[string](s, key)
+	s.dbLock.RUnlock()
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+
+	return c.writer.WriteInteger(int64(len(value)))
+}
+
+// handleSetrangeCommand serves SETRANGE key offset value, which overwrites
+// the string at key from offset on, padding it with zero bytes if it is
+// shorter, and replies with the new length. The key keeps its timeout.
+func (s *server) handleSetrangeCommand(c *client, command []string) error {
+	key := command[1]
+	value := command[3]
+
+	offset, err := strconv.ParseInt(command[2], 10, 64)
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	if offset < 0 {
+		return c.writer.WriteError("ERR offset is out of range")
+	}
+	if offset+int64(len(value)) > maxBulkLength {
+		return c.writer.WriteError("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	old, _, wrongType := lookupTyped[string](s, key)
+	length := len(old)
+	// An empty value changes nothing, and does not create the key.
+	if !wrongType && value != "" {
+		buf := []byte(old)
+		if end := int(offset) + len(value); end > len(buf) {
+			buf = append(buf, make([]byte, end-len(buf))...)
+		}
+		copy(buf[offset:], value)
+		s.storeValue(key, string(buf))
+		s.propagate(command...)
+		s.notifyKeyspaceEvent(notifyString, "setrange", key)
+		length = len(buf)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(length))
+}
+
+// handleGetrangeCommand serves GETRANGE key start end, which replies with the
+// bytes of the string between the inclusive offsets start and end. Negative
+// offsets count from the end.
+func (s *server) handleGetrangeCommand(c *client, command []string) error {
+	key := command[1]
+
+	start, err := strconv.Atoi(command[2])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+	end, err := strconv.Atoi(command[3])
+	if err != nil {
+		return c.writer.WriteError("ERR value is not an integer or out of range")
+	}
+
+	s.dbLock.RLock()
+	value, ok, wrongType := lookupTyped[string](s, key)
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	// An end that is still negative once counted from the end lies before
+	// the string, so nothing is selected. Redis replies with an empty string
+	// here rather than clamping the end to the first byte.
+	if end < 0 && end+len(value) < 0 {
+		return c.writer.WriteBulkString("")
+	}
+	lo, hi, empty := normalizeRange(start, end, len(value))
+	if empty {
+		return c.writer.WriteBulkString("")
+	}
+	return c.writer.WriteBulkString(value[lo : hi+1])
+}
+
+func (s *server) handleGetsetCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	old, ok, wrongType := lookupTyped[string](s, key)
+	if !wrongType {
+		// Like SET, GETSET discards the previous timeout.
+		s.setKey(key, command[2], setOptions{})
+		s.propagateSet(key, command[2])
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(old)
+}
+
+// handleGetdelCommand serves GETDEL key, which replies with the value of key
+// and deletes it.
+func (s *server) handleGetdelCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	value, ok, wrongType := lookupTyped[string](s, key)
+	if ok {
+		s.deleteKey(key)
+		s.propagate("DEL", key)
+		s.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(value)
+}
+
+// handleGetexCommand serves GETEX key [EX seconds | PX milliseconds | EXAT
+// unix-time-seconds | PXAT unix-time-milliseconds | PERSIST], which replies
+// with the value of key and changes its timeout. Without an option it is the
+// same as GET.
+func (s *server) handleGetexCommand(c *client, command []string) error {
+	key := command[1]
+
+	var deadline time.Time
+	persist := false
+	for i := 2; i < len(command); i += 1 {
+		switch option := strings.ToUpper(command[i]); {
+		case (option == "EX" || option == "PX") && deadline.IsZero() && !persist && i+1 < len(command):
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			i += 1
+			ttl, message := parseExpireTime("getex", command[i], unit)
+			if message != "" {
+				return c.writer.WriteError(message)
+			}
+			deadline = time.Now().Add(ttl)
+		case (option == "EXAT" || option == "PXAT") && deadline.IsZero() && !persist && i+1 < len(command):
+			unit := time.Second
+			if option == "PXAT" {
+				unit = time.Millisecond
+			}
+			i += 1
+			timestamp, message := parseExpireTime("getex", command[i], unit)
+			if message != "" {
+				return c.writer.WriteError(message)
+			}
+			deadline = time.Unix(0, 0).Add(timestamp)
+		case option == "PERSIST" && deadline.IsZero() && !persist:
+			persist = true
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	value, ok, wrongType := lookupTyped[string](s, key)
+	if ok && !deadline.IsZero() {
+		s.expireAt(key, deadline)
+	}
+	if _, hasDeadline := s.expirations[key]; ok && persist && hasDeadline {
+		delete(s.expirations, key)
+		s.touchKey(key)
+		s.propagate("PERSIST", key)
+		s.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(value)
+}
+
+func (s *server) handleMsetCommand(c *client, command []string) error {
+	s.dbLock.Lock()
+	for i := 1; i < len(command); i += 2 {
+		s.setKey(command[i], command[i+1], setOptions{})
+	}
+	s.propagate(command...)
+	s.dbLock.Unlock()
+
+	return c.writer.WriteSimpleString("OK")
+}
+
+func (s *server) handleMgetCommand(c *client, command []string) error {
+	values := make([]any, len(command)-1)
+	s.dbLock.RLock()
+	// Keys holding other types are reported as missing.
+	for i, key := range command[1:] {
+		if value, ok, _ := lookupTyped[string](s, key); ok {
+			values[i] = value
+		}
+	}
+	s.dbLock.RUnlock()
+
+	return c.writer.WriteArray(values)
+}
+
+// handleLcsCommand serves LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len]
+// [WITHMATCHLEN], which finds the longest common subsequence of two strings,
+// treating missing keys as empty strings. The reply is the subsequence, its
+// length with LEN, or with IDX the ranges of the two strings that match,
+// last first, and the length.
+func (s *server) handleLcsCommand(c *client, command []string) error {
+	withLen, withIdx, withMatchLen := false, false, false
+	minMatchLen := 0
+	for i := 3; i < len(command); i += 1 {
+		switch option := strings.ToUpper(command[i]); {
+		case option == "LEN":
+			withLen = true
+		case option == "IDX":
+			withIdx = true
+		case option == "WITHMATCHLEN":
+			withMatchLen = true
+		case option == "MINMATCHLEN" && i+1 < len(command):
+			i += 1
+			n, err := strconv.Atoi(command[i])
+			if err != nil {
+				return c.writer.WriteError("ERR value is not an integer or out of range")
+			}
+			minMatchLen = max(n, 0)
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+	if withLen && withIdx {
+		return c.writer.WriteError("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	s.dbLock.RLock()
+	a, aOk, aWrongType := lookupTyped[string](s, command[1])
+	b, bOk, bWrongType := lookupTyped[string](s, command[2])
+	s.dbLock.RUnlock()
+
+	if aWrongType || bWrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !aOk {
+		s.expireKey(command[1])
+	}
+	if !bOk {
+		s.expireKey(command[2])
+	}
+	if (len(a)+1)*(len(b)+1) > maxBulkLength/4 {
+		return c.writer.WriteError("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+	}
+
+	// table[i*(len(b)+1)+j] is the length of the longest common subsequence
+	// of a[:i] and b[:j].
+	width := len(b) + 1
+	table := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i += 1 {
+		for j := 1; j <= len(b); j += 1 {
+			if a[i-1] == b[j-1] {
+				table[i*width+j] = table[(i-1)*width+j-1] + 1
+			} else {
+				table[i*width+j] = max(table[(i-1)*width+j], table[i*width+j-1])
+			}
+		}
+	}
+	length := table[len(a)*width+len(b)]
+	if withLen {
+		return c.writer.WriteInteger(int64(length))
+	}
+
+	// Walking the table back from the end recovers the subsequence and the
+	// contiguous ranges it is made of. rangeStart is len(a) while there is
+	// no range in progress.
+	lcs := make([]byte, length)
+	matches := []any{}
+	rangeStart, rangeEnd := len(a), len(a)
+	bRangeStart, bRangeEnd := 0, 0
+	for i, j, k := len(a), len(b), int(length); i > 0 && j > 0; {
+		emitRange := false
+		if a[i-1] == b[j-1] {
+			lcs[k-1] = a[i-1]
+			switch {
+			case rangeStart == len(a):
+				rangeStart, rangeEnd = i-1, i-1
+				bRangeStart, bRangeEnd = j-1, j-1
+			case rangeStart == i && bRangeStart == j:
+				rangeStart -= 1
+				bRangeStart -= 1
+			default:
+				emitRange = true
+			}
+			// A range that reached the start of either string is complete.
+			if rangeStart == 0 || bRangeStart == 0 {
+				emitRange = true
+			}
+			i, j, k = i-1, j-1, k-1
+		} else {
+			if table[(i-1)*width+j] > table[i*width+j-1] {
+				i -= 1
+			} else {
+				j -= 1
+			}
+			if rangeStart != len(a) {
+				emitRange = true
+			}
+		}
+
+		if emitRange {
+			matchLen := rangeEnd - rangeStart + 1
+			if matchLen >= minMatchLen {
+				match := []any{
+					[]any{int64(rangeStart), int64(rangeEnd)},
+					[]any{int64(bRangeStart), int64(bRangeEnd)},
+				}
+				if withMatchLen {
+					match = append(match, int64(matchLen))
+				}
+				matches = append(matches, match)
+			}
+			rangeStart = len(a)
+		}
+	}
+
+	if withIdx {
+		return c.writer.WriteMap([]any{"matches", matches, "len", int64(length)})
+	}
+	return c.writer.WriteBulkString(string(lcs))
+}
@@ -0,0 +1,373 @@
+package goredis_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestSetrange(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SETRANGE", "key", "0", "hello"}, ":5\r\n"},
+		{[]string{"SETRANGE", "key", "1", "ipp"}, ":5\r\n"},
+		{[]string{"GET", "key"}, "$5\r\nhippo\r\n"},
+		// Writing past the end pads with zero bytes.
+		{[]string{"SETRANGE", "key", "7", "!"}, ":8\r\n"},
+		{[]string{"GET", "key"}, "$8\r\nhippo\x00\x00!\r\n"},
+		{[]string{"SETRANGE", "key", "-1", "x"}, "-ERR offset is out of range\r\n"},
+	})
+}
+
+func TestGetrange(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("SET", "key", "hello")
+	tests := []struct {
+		start, end string
+		want       string
+	}{
+		{"0", "-1", "$5\r\nhello\r\n"},
+		{"1", "3", "$3\r\nell\r\n"},
+		{"-3", "-1", "$3\r\nllo\r\n"},
+		{"-100", "2", "$3\r\nhel\r\n"},
+		{"2", "100", "$3\r\nllo\r\n"},
+		{"3", "1", "$0\r\n\r\n"},
+		{"10", "20", "$0\r\n\r\n"},
+		// Ends before the start of the string select nothing.
+		{"0", "-100", "$0\r\n\r\n"},
+		{"0", "-6", "$0\r\n\r\n"},
+		{"-100", "-100", "$0\r\n\r\n"},
+	}
+	for _, test := range tests {
+		if got := c.Do("GETRANGE", "key", test.start, test.end); got != test.want {
+			t.Errorf("GETRANGE key %s %s = %q, want %q", test.start, test.end, got, test.want)
+		}
+	}
+	if got := c.Do("GETRANGE", "missing", "0", "-1"); got != "$0\r\n\r\n" {
+		t.Errorf("GETRANGE of a missing key = %q, want an empty string", got)
+	}
+}
+
+func TestGetMissingKey(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	// Connections start with RESP2, whose null is the null bulk string, and
+	// switch to the RESP3 null with HELLO 3.
+	run(t, c, []step{
+		{[]string{"GET", "missing"}, "$-1\r\n"},
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"DEL", "key"}, ":1\r\n"},
+		{[]string{"GET", "key"}, "$-1\r\n"},
+	})
+	c.Do("HELLO", "3")
+	run(t, c, []step{{[]string{"GET", "missing"}, "_\r\n"}})
+	c.Do("HELLO", "2")
+	run(t, c, []step{{[]string{"GET", "missing"}, "$-1\r\n"}})
+}
+
+func TestSetOptions(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		// NX only sets a missing key and XX only an existing one.
+		{[]string{"SET", "key", "1", "XX"}, "$-1\r\n"},
+		{[]string{"EXISTS", "key"}, ":0\r\n"},
+		{[]string{"SET", "key", "1", "nx"}, "+OK\r\n"},
+		{[]string{"SET", "key", "2", "NX"}, "$-1\r\n"},
+		{[]string{"SET", "key", "3", "XX"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n3\r\n"},
+
+		// EX and PX set an expiration time, and a plain SET clears it.
+		{[]string{"SET", "key", "4", "EX", "100"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"SET", "key", "5", "px", "100000", "XX"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"SET", "key", "6"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":-1\r\n"},
+
+		{[]string{"SET", "key", "7", "EX", "0"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "key", "7", "PX", "-1"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "key", "7", "EX", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"SET", "key", "7", "EX", "9223372036854775807"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "key", "7", "EX"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "key", "7", "EX", "1", "PX", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "key", "7", "NX", "XX"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "key", "7", "FOO"}, "-ERR syntax error\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n6\r\n"},
+	})
+
+	// A key set with PX is gone once the time is up.
+	run(t, c, []step{{[]string{"SET", "short", "value", "PX", "1"}, "+OK\r\n"}})
+	time.Sleep(10 * time.Millisecond)
+	run(t, c, []step{{[]string{"GET", "short"}, "$-1\r\n"}})
+}
+
+func TestSetKeepttl(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "1", "EX", "100"}, "+OK\r\n"},
+		{[]string{"SET", "key", "2", "KEEPTTL"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n2\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"SET", "key", "3", "keepttl", "XX"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"SET", "key", "4"}, "+OK\r\n"},
+		{[]string{"TTL", "key"}, ":-1\r\n"},
+		// A new key has no expiration time to keep.
+		{[]string{"SET", "new", "1", "KEEPTTL", "XX"}, "$-1\r\n"},
+		{[]string{"SET", "new", "1", "KEEPTTL"}, "+OK\r\n"},
+		{[]string{"TTL", "new"}, ":-1\r\n"},
+		{[]string{"SET", "key", "5", "KEEPTTL", "EX", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "key", "5", "PX", "1000", "KEEPTTL"}, "-ERR syntax error\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n4\r\n"},
+	})
+}
+
+func TestSetGet(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "key", "1", "GET"}, "$-1\r\n"},
+		{[]string{"SET", "key", "2", "get"}, "$1\r\n1\r\n"},
+		// The old value is returned whether or not NX or XX let the SET
+		// happen.
+		{[]string{"SET", "key", "3", "NX", "GET"}, "$1\r\n2\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n2\r\n"},
+		{[]string{"SET", "new", "1", "XX", "GET"}, "$-1\r\n"},
+		{[]string{"EXISTS", "new"}, ":0\r\n"},
+		{[]string{"SET", "key", "4", "GET", "EX", "100"}, "$1\r\n2\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+		{[]string{"SET", "key", "5", "GET", "GET"}, "-ERR syntax error\r\n"},
+		// A key of another type is left alone.
+		{[]string{"RPUSH", "list", "a"}, ":1\r\n"},
+		{[]string{"SET", "list", "x", "GET"}, wrongType},
+		{[]string{"TYPE", "list"}, "+list\r\n"},
+		{[]string{"SET", "list", "x"}, "+OK\r\n"},
+	})
+}
+
+func TestIncr(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		// A missing key counts as 0.
+		{[]string{"INCR", "counter"}, ":1\r\n"},
+		{[]string{"INCR", "counter"}, ":2\r\n"},
+		{[]string{"DECR", "counter"}, ":1\r\n"},
+		{[]string{"DECR", "other"}, ":-1\r\n"},
+		{[]string{"GET", "counter"}, "$1\r\n1\r\n"},
+		{[]string{"SET", "counter", "-10"}, "+OK\r\n"},
+		{[]string{"INCR", "counter"}, ":-9\r\n"},
+
+		// The key keeps its expiration time.
+		{[]string{"SET", "counter", "5", "EX", "100"}, "+OK\r\n"},
+		{[]string{"INCR", "counter"}, ":6\r\n"},
+		{[]string{"TTL", "counter"}, ":100\r\n"},
+
+		{[]string{"SET", "counter", "9223372036854775807"}, "+OK\r\n"},
+		{[]string{"INCR", "counter"}, "-ERR increment or decrement would overflow\r\n"},
+		{[]string{"SET", "counter", "-9223372036854775808"}, "+OK\r\n"},
+		{[]string{"DECR", "counter"}, "-ERR increment or decrement would overflow\r\n"},
+		{[]string{"GET", "counter"}, "$20\r\n-9223372036854775808\r\n"},
+		{[]string{"SET", "counter", "1.5"}, "+OK\r\n"},
+		{[]string{"INCR", "counter"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"SET", "counter", "abc"}, "+OK\r\n"},
+		{[]string{"DECR", "counter"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"LPUSH", "list", "1"}, ":1\r\n"},
+		{[]string{"INCR", "list"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"INCR"}, "-ERR wrong number of arguments for 'incr' command\r\n"},
+	})
+}
+
+func TestAppend(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"STRLEN", "key"}, ":0\r\n"},
+		// APPEND creates a missing key.
+		{[]string{"APPEND", "key", "hello"}, ":5\r\n"},
+		{[]string{"APPEND", "key", " world"}, ":11\r\n"},
+		{[]string{"APPEND", "key", ""}, ":11\r\n"},
+		{[]string{"GET", "key"}, "$11\r\nhello world\r\n"},
+		{[]string{"STRLEN", "key"}, ":11\r\n"},
+		// Lengths are in bytes.
+		{[]string{"SET", "key", "é"}, "+OK\r\n"},
+		{[]string{"STRLEN", "key"}, ":2\r\n"},
+
+		// The key keeps its expiration time.
+		{[]string{"SET", "key", "a", "EX", "100"}, "+OK\r\n"},
+		{[]string{"APPEND", "key", "b"}, ":2\r\n"},
+		{[]string{"TTL", "key"}, ":100\r\n"},
+
+		{[]string{"LPUSH", "list", "1"}, ":1\r\n"},
+		{[]string{"APPEND", "list", "x"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"STRLEN", "list"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"APPEND", "key"}, "-ERR wrong number of arguments for 'append' command\r\n"},
+		{[]string{"STRLEN", "key", "x"}, "-ERR wrong number of arguments for 'strlen' command\r\n"},
+	})
+}
+
+func TestGetset(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"GETSET", "key", "1"}, "$-1\r\n"},
+		{[]string{"GETSET", "key", "2"}, "$1\r\n1\r\n"},
+		{[]string{"GET", "key"}, "$1\r\n2\r\n"},
+		// Like SET, GETSET discards the expiration time.
+		{[]string{"SET", "key", "3", "EX", "100"}, "+OK\r\n"},
+		{[]string{"GETSET", "key", "4"}, "$1\r\n3\r\n"},
+		{[]string{"TTL", "key"}, ":-1\r\n"},
+		{[]string{"LPUSH", "list", "1"}, ":1\r\n"},
+		{[]string{"GETSET", "list", "x"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"TYPE", "list"}, "+list\r\n"},
+		{[]string{"GETSET", "key"}, "-ERR wrong number of arguments for 'getset' command\r\n"},
+	})
+}
+
+func TestMset(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SET", "b", "old", "EX", "100"}, "+OK\r\n"},
+		{[]string{"MSET", "a", "1", "b", "2", "a", "3"}, "+OK\r\n"},
+		// A key listed twice takes its last value.
+		{[]string{"MGET", "a", "b", "missing"}, "*3\r\n$1\r\n3\r\n$1\r\n2\r\n$-1\r\n"},
+		// MSET discards expiration times like SET.
+		{[]string{"TTL", "b"}, ":-1\r\n"},
+		// Keys holding other types are reported as missing.
+		{[]string{"LPUSH", "list", "1"}, ":1\r\n"},
+		{[]string{"MGET", "list", "a"}, "*2\r\n$-1\r\n$1\r\n3\r\n"},
+		{[]string{"MSET", "list", "x"}, "+OK\r\n"},
+		{[]string{"TYPE", "list"}, "+string\r\n"},
+		{[]string{"MSET", "a"}, "-ERR wrong number of arguments for 'mset' command\r\n"},
+		{[]string{"MGET"}, "-ERR wrong number of arguments for 'mget' command\r\n"},
+	})
+}
+
+func TestSetex(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SETEX", "a", "0", "v"}, "-ERR invalid expire time in 'setex' command\r\n"},
+		{[]string{"SETEX", "a", "x", "v"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"SETEX", "a", "100", "v"}, "+OK\r\n"},
+		{[]string{"GET", "a"}, "$1\r\nv\r\n"},
+		{[]string{"TTL", "a"}, ":100\r\n"},
+		{[]string{"PSETEX", "b", "-1", "v"}, "-ERR invalid expire time in 'psetex' command\r\n"},
+		{[]string{"PSETEX", "b", "100000", "v"}, "+OK\r\n"},
+		{[]string{"TTL", "b"}, ":100\r\n"},
+		{[]string{"SETEX", "a", "100"}, "-ERR wrong number of arguments for 'setex' command\r\n"},
+	})
+}
+
+func TestSetnx(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"SETNX", "a", "1"}, ":1\r\n"},
+		{[]string{"SETNX", "a", "2"}, ":0\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		// A key of another type counts as existing.
+		{[]string{"RPUSH", "list", "x"}, ":1\r\n"},
+		{[]string{"SETNX", "list", "2"}, ":0\r\n"},
+		{[]string{"TYPE", "list"}, "+list\r\n"},
+	})
+}
+
+func TestGetdel(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"GETDEL", "a"}, "$-1\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"GETDEL", "a"}, "$1\r\n1\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+		{[]string{"RPUSH", "list", "x"}, ":1\r\n"},
+		{[]string{"GETDEL", "list"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"EXISTS", "list"}, ":1\r\n"},
+	})
+}
+
+func TestGetex(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"GETEX", "a"}, "$-1\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		// Without options GETEX is a plain GET.
+		{[]string{"GETEX", "a"}, "$1\r\n1\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		{[]string{"GETEX", "a", "EX", "100"}, "$1\r\n1\r\n"},
+		{[]string{"TTL", "a"}, ":100\r\n"},
+		{[]string{"GETEX", "a", "PERSIST"}, "$1\r\n1\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		{[]string{"GETEX", "a", "EX", "0"}, "-ERR invalid expire time in 'getex' command\r\n"},
+		{[]string{"GETEX", "a", "EX", "1", "PERSIST"}, "-ERR syntax error\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		{[]string{"GETEX", "a", "EXAT", strconv.FormatInt(time.Now().Unix()+100, 10)}, "$1\r\n1\r\n"},
+		{[]string{"EXISTS", "a"}, ":1\r\n"},
+		// A time in the past deletes the key once it is read.
+		{[]string{"GETEX", "a", "PXAT", "1"}, "$1\r\n1\r\n"},
+		{[]string{"EXISTS", "a"}, ":0\r\n"},
+	})
+}
+
+func TestLcs(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"MSET", "key1", "ohmytext", "key2", "mynewtext"}, "+OK\r\n"},
+		{[]string{"LCS", "key1", "key2"}, "$6\r\nmytext\r\n"},
+		{[]string{"LCS", "key1", "key2", "LEN"}, ":6\r\n"},
+		// IDX lists the matching ranges of both strings, from the last.
+		{[]string{"LCS", "key1", "key2", "IDX"}, "*4\r\n$7\r\nmatches\r\n*2\r\n" +
+			"*2\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n" +
+			"*2\r\n*2\r\n:2\r\n:3\r\n*2\r\n:0\r\n:1\r\n" +
+			"$3\r\nlen\r\n:6\r\n"},
+		{[]string{"LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"}, "*4\r\n$7\r\nmatches\r\n*1\r\n" +
+			"*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n" +
+			"$3\r\nlen\r\n:6\r\n"},
+		{[]string{"SET", "same", "abc"}, "+OK\r\n"},
+		{[]string{"LCS", "same", "same", "IDX", "WITHMATCHLEN"}, "*4\r\n$7\r\nmatches\r\n*1\r\n" +
+			"*3\r\n*2\r\n:0\r\n:2\r\n*2\r\n:0\r\n:2\r\n:3\r\n" +
+			"$3\r\nlen\r\n:3\r\n"},
+		// A missing key is an empty string.
+		{[]string{"LCS", "key1", "missing"}, "$0\r\n\r\n"},
+		{[]string{"LCS", "key1", "missing", "IDX"}, "*4\r\n$7\r\nmatches\r\n*0\r\n$3\r\nlen\r\n:0\r\n"},
+
+		{[]string{"LCS", "key1", "key2", "LEN", "IDX"}, "-ERR If you want both the length and indexes, please just use IDX.\r\n"},
+		{[]string{"LCS", "key1", "key2", "FOO"}, "-ERR syntax error\r\n"},
+		{[]string{"LCS", "key1", "key2", "MINMATCHLEN"}, "-ERR syntax error\r\n"},
+		{[]string{"RPUSH", "list", "a"}, ":1\r\n"},
+		{[]string{"LCS", "key1", "list"}, wrongType},
+	})
+
+	// RESP3 clients get the IDX reply as a map.
+	c.Do("HELLO", "3")
+	run(t, c, []step{
+		{[]string{"LCS", "key1", "missing", "IDX"}, "%2\r\n$7\r\nmatches\r\n*0\r\n$3\r\nlen\r\n:0\r\n"},
+	})
+}
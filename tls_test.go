@@ -0,0 +1,116 @@
+package goredis_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// selfSigned returns a certificate for 127.0.0.1 signed by its own key,
+// usable by either end of a connection, and a pool that trusts it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goredis test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// dialTLS connects to the server at addr over TLS with config.
+func dialTLS(t *testing.T, addr string, config *tls.Config) (*redistest.Client, error) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { conn.Close() })
+	return redistest.NewClient(t, conn), nil
+}
+
+func TestTLS(t *testing.T) {
+	serverCert, roots := selfSigned(t)
+	addr, _ := redistest.StartServer(t, goredis.WithTLS(&tls.Config{Certificates: []tls.Certificate{serverCert}}))
+
+	c, err := dialTLS(t, addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, c, []step{
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"GET", "key"}, "$5\r\nvalue\r\n"},
+	})
+
+	// A client speaking plain RESP fails the handshake and is disconnected
+	// without being served.
+	plain := redistest.Dial(t, addr)
+	io.WriteString(plain, "*1\r\n$4\r\nPING\r\n")
+	plain.SetReadDeadline(time.Now().Add(time.Second))
+	reply, _ := io.ReadAll(plain)
+	if string(reply) == "+PONG\r\n" {
+		t.Error("the server answered a client that did not use TLS")
+	}
+}
+
+func TestTLSClientCertificates(t *testing.T) {
+	serverCert, roots := selfSigned(t)
+	clientCert, clientCAs := selfSigned(t)
+	addr, _ := redistest.StartServer(t, goredis.WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}))
+
+	c, err := dialTLS(t, addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, c, []step{{[]string{"PING"}, "+PONG\r\n"}})
+
+	// In TLS 1.3 the client only learns that the server rejected its
+	// certificate when it reads.
+	for _, certificates := range [][]tls.Certificate{nil, {serverCert}} {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, Certificates: certificates})
+		if err != nil {
+			continue
+		}
+		io.WriteString(conn, "*1\r\n$4\r\nPING\r\n")
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if n, err := conn.Read(make([]byte, 64)); err == nil {
+			t.Errorf("read %d bytes without a trusted client certificate", n)
+		}
+		conn.Close()
+	}
+}
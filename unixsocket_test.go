@@ -0,0 +1,79 @@
+package goredis_test
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	goredis "mhmdiamd/go-redis-clone"
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+// dialUnix connects to the Unix socket at path, waiting for the server to
+// open it.
+func dialUnix(t *testing.T, path string) *redistest.Client {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			t.Cleanup(func() { conn.Close() })
+			return redistest.NewClient(t, conn)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cannot connect to %s: %v", path, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.sock")
+	// A socket left behind by a server that did not stop cleanly.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	addr, stop := redistest.StartServer(t, goredis.WithUnixSocket(path))
+	c := dialUnix(t, path)
+
+	// Both listeners serve the same keyspace.
+	run(t, c, []step{
+		{[]string{"SET", "key", "value"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "unixsocket"}, "*2\r\n$10\r\nunixsocket\r\n$" + strconv.Itoa(len(path)) + "\r\n" + path + "\r\n"},
+	})
+	run(t, redistest.Connect(t, addr), []step{{[]string{"GET", "key"}, "$5\r\nvalue\r\n"}})
+
+	stop()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file remains after Stop: %v", err)
+	}
+}
+
+func TestUnixSocketPathTaken(t *testing.T) {
+	// A file that is not a socket is left alone, and Start fails.
+	path := filepath.Join(t.TempDir(), "redis.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := goredis.NewServer(listener, slog.New(slog.NewTextHandler(io.Discard, nil)), goredis.WithUnixSocket(path))
+	t.Cleanup(func() { server.Stop() })
+	if err := server.Start(); err == nil {
+		t.Error("Start() = nil with a file at the socket path")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("file at the socket path holds %q, %v", data, err)
+	}
+}
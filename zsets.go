@@ -0,0 +1,774 @@
+package goredis
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// zsetMemberOverhead is the memory a sorted set member costs on top of
+// elementSize, for the score and the second reference in the ordered slice.
+const zsetMemberOverhead = 24
+
+// sortedSet is the value of a sorted set key. Members are ordered by score,
+// and members with the same score by their bytes, like in Redis. Sorted sets
+// are never empty; the key is deleted along with the last member.
+type sortedSet struct {
+	scores map[string]float64
+	// sorted holds the members in order, so ranges and ranks are found by
+	// binary search.
+	sorted []zsetMember
+}
+
+type zsetMember struct {
+	member string
+	score  float64
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{scores: make(map[string]float64)}
+}
+
+func compareZsetMembers(a zsetMember, b zsetMember) int {
+	if c := cmp.Compare(a.score, b.score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.member, b.member)
+}
+
+// zsetMemberSize estimates the memory used by a member of a sorted set.
+func zsetMemberSize(member string) int64 {
+	return elementSize(member) + zsetMemberOverhead
+}
+
+// add sets the score of member, adding it if needed.
+func (z *sortedSet) add(member string, score float64) {
+	z.remove(member)
+	z.scores[member] = score
+	element := zsetMember{member, score}
+	i, _ := slices.BinarySearchFunc(z.sorted, element, compareZsetMembers)
+	z.sorted = slices.Insert(z.sorted, i, element)
+}
+
+// remove deletes member and reports whether it was in z.
+func (z *sortedSet) remove(member string) bool {
+	i, ok := z.rank(member)
+	if !ok {
+		return false
+	}
+	delete(z.scores, member)
+	z.sorted = slices.Delete(z.sorted, i, i+1)
+	return true
+}
+
+// rank returns the position of member in score order.
+func (z *sortedSet) rank(member string) (int, bool) {
+	score, ok := z.scores[member]
+	if !ok {
+		return 0, false
+	}
+	return slices.BinarySearchFunc(z.sorted, zsetMember{member, score}, compareZsetMembers)
+}
+
+// formatScore formats a score the way Redis replies with it.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
+
+// parseScore parses a score, rejecting NaN. The returned string is an error
+// message to reply with when the value is invalid.
+func parseScore(value string) (float64, string) {
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, "ERR value is not a valid float"
+	}
+	return score, ""
+}
+
+// zsetReply lists members, followed by its score after each when withScores
+// is set.
+func zsetReply(members []zsetMember, withScores bool) []any {
+	reply := make([]any, 0, len(members))
+	for _, m := range members {
+		reply = append(reply, m.member)
+		if withScores {
+			reply = append(reply, formatScore(m.score))
+		}
+	}
+	return reply
+}
+
+// handleZaddCommand serves ZADD key [NX | XX] [GT | LT] [CH] score member
+// [score member ...]. It replies with the number of members added, or with
+// CH the number added or whose score changed.
+func (s *server) handleZaddCommand(c *client, command []string) error {
+	key := command[1]
+
+	var nx, xx, gt, lt, ch bool
+	i := 2
+options:
+	for ; i < len(command); i += 1 {
+		switch strings.ToUpper(command[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		default:
+			break options
+		}
+	}
+	pairs := command[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return c.writer.WriteError("ERR syntax error")
+	}
+	if nx && xx {
+		return c.writer.WriteError("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return c.writer.WriteError("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, message := parseScore(pairs[2*j])
+		if message != "" {
+			return c.writer.WriteError(message)
+		}
+		scores[j] = score
+	}
+
+	added, changed := 0, 0
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	if !wrongType && !(xx && !ok) {
+		if !ok {
+			z = newSortedSet()
+			s.storeValue(key, z)
+		}
+		for j, score := range scores {
+			member := pairs[2*j+1]
+			current, exists := z.scores[member]
+			switch {
+			case exists && !nx && current != score && !(gt && score < current) && !(lt && score > current):
+				z.add(member, score)
+				changed += 1
+			case !exists && !xx:
+				z.add(member, score)
+				s.usedMemory += zsetMemberSize(member)
+				added += 1
+			}
+		}
+		if added+changed > 0 {
+			s.touchKey(key)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyZset, "zadd", key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if ch {
+		return c.writer.WriteInteger(int64(added + changed))
+	}
+	return c.writer.WriteInteger(int64(added))
+}
+
+// handleZincrbyCommand serves ZINCRBY key increment member, adding member
+// with a score of increment if needed, and replies with the new score.
+func (s *server) handleZincrbyCommand(c *client, command []string) error {
+	key := command[1]
+	member := command[3]
+
+	increment, message := parseScore(command[2])
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(key)
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	var score float64
+	if !wrongType {
+		var current float64
+		exists := false
+		if ok {
+			current, exists = z.scores[member]
+		}
+		score = current + increment
+		if math.IsNaN(score) {
+			message = "ERR resulting score is not a number (NaN)"
+		} else {
+			if !ok {
+				z = newSortedSet()
+				s.storeValue(key, z)
+			}
+			if !exists {
+				s.usedMemory += zsetMemberSize(member)
+			}
+			z.add(member, score)
+			s.touchKey(key)
+			s.propagate(command...)
+			s.notifyKeyspaceEvent(notifyZset, "zincr", key)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+	return c.writer.WriteBulkString(formatScore(score))
+}
+
+func (s *server) handleZscoreCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	var score float64
+	exists := false
+	if ok {
+		score, exists = z.scores[command[2]]
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if !exists {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteBulkString(formatScore(score))
+}
+
+// handleZrankCommand serves both ZRANK and ZREVRANK key member, which reply
+// with the position of member counting from the lowest or the highest
+// score.
+func (s *server) handleZrankCommand(c *client, command []string) error {
+	name := strings.ToLower(command[0])
+	key := command[1]
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	rank := 0
+	exists := false
+	if ok {
+		rank, exists = z.rank(command[2])
+		if name == "zrevrank" {
+			rank = len(z.sorted) - 1 - rank
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if !exists {
+		return c.writer.WriteNull()
+	}
+	return c.writer.WriteInteger(int64(rank))
+}
+
+// zrangeQuery is a range of a sorted set as ZRANGE and ZRANGESTORE select
+// it. By default start and stop are inclusive ranks, where negative ranks
+// count from the end. With BYSCORE they are score bounds and with BYLEX
+// member bounds, and LIMIT skips offset members and keeps count of the rest,
+// or all of them when count is negative. REV walks the set from the highest
+// score, and swaps the bounds so that the first one is the maximum.
+type zrangeQuery struct {
+	byScore bool
+	byLex   bool
+	rev     bool
+	// start and stop are the ranks, minScore and maxScore the score bounds
+	// and minLex and maxLex the member bounds, depending on the kind of
+	// range.
+	start, stop        int
+	minScore, maxScore scoreBound
+	minLex, maxLex     lexBound
+	offset, count      int
+	hasLimit           bool
+}
+
+// parseZrangeQuery parses the start and stop arguments of ZRANGE and
+// ZRANGESTORE, followed by their options. withScores reports WITHSCORES,
+// which is only accepted when allowWithScores is set. The returned string
+// is an error message to reply with when the arguments are invalid.
+func parseZrangeQuery(args []string, allowWithScores bool) (query zrangeQuery, withScores bool, message string) {
+	query.count = -1
+	for i := 2; i < len(args); i += 1 {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "BYSCORE" && !query.byLex:
+			query.byScore = true
+		case option == "BYLEX" && !query.byScore:
+			query.byLex = true
+		case option == "REV":
+			query.rev = true
+		case option == "WITHSCORES" && allowWithScores:
+			withScores = true
+		case option == "LIMIT" && i+2 < len(args):
+			var err1, err2 error
+			query.offset, err1 = strconv.Atoi(args[i+1])
+			query.count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return query, false, "ERR value is not an integer or out of range"
+			}
+			query.hasLimit = true
+			i += 2
+		default:
+			return query, false, "ERR syntax error"
+		}
+	}
+	if query.hasLimit && !query.byScore && !query.byLex {
+		return query, false, "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"
+	}
+	if withScores && query.byLex {
+		return query, false, "ERR syntax error, WITHSCORES not supported in combination with BYLEX"
+	}
+
+	first, second := args[0], args[1]
+	if query.rev && (query.byScore || query.byLex) {
+		first, second = second, first
+	}
+	switch {
+	case query.byScore:
+		minOk, maxOk := false, false
+		query.minScore, minOk = parseScoreBound(first)
+		query.maxScore, maxOk = parseScoreBound(second)
+		if !minOk || !maxOk {
+			return query, false, "ERR min or max is not a float"
+		}
+	case query.byLex:
+		lexQuery, message := parseLexQuery(first, second)
+		if message != "" {
+			return query, false, message
+		}
+		query.minLex, query.maxLex = lexQuery.minLex, lexQuery.maxLex
+	default:
+		var err1, err2 error
+		query.start, err1 = strconv.Atoi(first)
+		query.stop, err2 = strconv.Atoi(second)
+		if err1 != nil || err2 != nil {
+			return query, false, "ERR value is not an integer or out of range"
+		}
+	}
+	return query, withScores, ""
+}
+
+// selectRange returns the members of z in query, in the order the query
+// walks them. The result may share memory with z.
+func (query zrangeQuery) selectRange(z *sortedSet) []zsetMember {
+	if !query.byScore && !query.byLex {
+		lo, hi, empty := normalizeRange(query.start, query.stop, len(z.sorted))
+		if empty {
+			return nil
+		}
+		if !query.rev {
+			return z.sorted[lo : hi+1]
+		}
+		// Ranks count from the highest score.
+		selected := slices.Clone(z.sorted[len(z.sorted)-1-hi : len(z.sorted)-lo])
+		slices.Reverse(selected)
+		return selected
+	}
+
+	var selected []zsetMember
+	for _, m := range z.sorted {
+		if query.byScore && query.minScore.includes(m.score, true) && query.maxScore.includes(m.score, false) {
+			selected = append(selected, m)
+		}
+		if query.byLex && query.minLex.includes(m.member, true) && query.maxLex.includes(m.member, false) {
+			selected = append(selected, m)
+		}
+	}
+	if query.rev {
+		slices.Reverse(selected)
+	}
+	if query.offset < 0 || query.offset >= len(selected) {
+		return nil
+	}
+	selected = selected[query.offset:]
+	if query.count >= 0 && query.count < len(selected) {
+		selected = selected[:query.count]
+	}
+	return selected
+}
+
+// handleZrangeCommand serves ZRANGE key start stop [BYSCORE | BYLEX] [REV]
+// [LIMIT offset count] [WITHSCORES], as described by zrangeQuery.
+func (s *server) handleZrangeCommand(c *client, command []string) error {
+	key := command[1]
+
+	query, withScores, message := parseZrangeQuery(command[2:], true)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	reply := []any{}
+	if ok {
+		reply = zsetReply(query.selectRange(z), withScores)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleZrangestoreCommand serves ZRANGESTORE dst src start stop [BYSCORE |
+// BYLEX] [REV] [LIMIT offset count], which stores the range ZRANGE would
+// reply with at dst and replies with its size. dst is deleted when the range
+// is empty.
+func (s *server) handleZrangestoreCommand(c *client, command []string) error {
+	dst := command[1]
+	src := command[2]
+
+	query, _, message := parseZrangeQuery(command[3:], false)
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.Lock()
+	s.deleteIfExpired(src)
+	s.deleteIfExpired(dst)
+	z, ok, wrongType := lookupTyped[*sortedSet](s, src)
+	size := 0
+	if !wrongType {
+		stored := newSortedSet()
+		if ok {
+			for _, m := range query.selectRange(z) {
+				stored.add(m.member, m.score)
+			}
+		}
+		size = len(stored.sorted)
+		existed := s.deleteKey(dst)
+		if size > 0 {
+			s.storeValue(dst, stored)
+			s.notifyKeyspaceEvent(notifyZset, "zrangestore", dst)
+		} else if existed {
+			s.notifyKeyspaceEvent(notifyGeneric, "del", dst)
+		}
+		if size > 0 || existed {
+			s.propagate(command...)
+		}
+	}
+	s.dbLock.Unlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	return c.writer.WriteInteger(int64(size))
+}
+
+// scoreBound is one end of a score interval, as in ZRANGEBYSCORE. A bound
+// written with a leading ( excludes the score itself.
+type scoreBound struct {
+	score     float64
+	exclusive bool
+}
+
+// includes reports whether score is within the bound, as the minimum of an
+// interval when isMin is set and as the maximum otherwise.
+func (bound scoreBound) includes(score float64, isMin bool) bool {
+	if isMin {
+		return score > bound.score || (!bound.exclusive && score == bound.score)
+	}
+	return score < bound.score || (!bound.exclusive && score == bound.score)
+}
+
+func parseScoreBound(value string) (scoreBound, bool) {
+	bound := scoreBound{}
+	if strings.HasPrefix(value, "(") {
+		bound.exclusive = true
+		value = value[1:]
+	}
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(score) {
+		return bound, false
+	}
+	bound.score = score
+	return bound, true
+}
+
+// lexBound is one end of a member interval, as in ZRANGE BYLEX. It is
+// written as [member to include member itself, (member to exclude it, or -
+// and + for the lowest and highest possible member.
+type lexBound struct {
+	member    string
+	exclusive bool
+	// infinite is -1 for - and 1 for +.
+	infinite int
+}
+
+// includes reports whether member is within the bound, as the minimum of an
+// interval when isMin is set and as the maximum otherwise.
+func (bound lexBound) includes(member string, isMin bool) bool {
+	if bound.infinite != 0 {
+		return (bound.infinite < 0) == isMin
+	}
+	c := strings.Compare(member, bound.member)
+	if !isMin {
+		c = -c
+	}
+	return c > 0 || (!bound.exclusive && c == 0)
+}
+
+func parseLexBound(value string) (lexBound, bool) {
+	switch {
+	case value == "-":
+		return lexBound{infinite: -1}, true
+	case value == "+":
+		return lexBound{infinite: 1}, true
+	case strings.HasPrefix(value, "["):
+		return lexBound{member: value[1:]}, true
+	case strings.HasPrefix(value, "("):
+		return lexBound{member: value[1:], exclusive: true}, true
+	}
+	return lexBound{}, false
+}
+
+// handleZrangebyscoreCommand serves ZRANGEBYSCORE key min max [WITHSCORES]
+// [LIMIT offset count].
+func (s *server) handleZrangebyscoreCommand(c *client, command []string) error {
+	key := command[1]
+
+	minBound, minOk := parseScoreBound(command[2])
+	maxBound, maxOk := parseScoreBound(command[3])
+	if !minOk || !maxOk {
+		return c.writer.WriteError("ERR min or max is not a float")
+	}
+	withScores := false
+	offset, count := 0, -1
+	for i := 4; i < len(command); i += 1 {
+		switch option := strings.ToUpper(command[i]); {
+		case option == "WITHSCORES":
+			withScores = true
+		case option == "LIMIT" && i+2 < len(command):
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(command[i+1])
+			count, err2 = strconv.Atoi(command[i+2])
+			if err1 != nil || err2 != nil {
+				return c.writer.WriteError("ERR value is not an integer or out of range")
+			}
+			i += 2
+		default:
+			return c.writer.WriteError("ERR syntax error")
+		}
+	}
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	reply := []any{}
+	if ok && offset >= 0 {
+		// The first member above min is found by binary search, and the
+		// range ends at the first member above max.
+		lo, _ := slices.BinarySearchFunc(z.sorted, minBound, func(m zsetMember, bound scoreBound) int {
+			if m.score < bound.score || (bound.exclusive && m.score == bound.score) {
+				return -1
+			}
+			return 1
+		})
+		matched := []zsetMember{}
+		for _, m := range z.sorted[lo:] {
+			if m.score > maxBound.score || (maxBound.exclusive && m.score == maxBound.score) {
+				break
+			}
+			matched = append(matched, m)
+		}
+		if offset < len(matched) {
+			matched = matched[offset:]
+			if count >= 0 && count < len(matched) {
+				matched = matched[:count]
+			}
+			reply = zsetReply(matched, withScores)
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleZrangebylexCommand serves ZRANGEBYLEX key min max [LIMIT offset
+// count], the same as ZRANGE key min max BYLEX. It is meant for sorted sets
+// whose members all have the same score, which are then ordered by member.
+func (s *server) handleZrangebylexCommand(c *client, command []string) error {
+	key := command[1]
+
+	query, message := parseLexQuery(command[2], command[3])
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+	for i := 4; i < len(command); i += 1 {
+		if !strings.EqualFold(command[i], "LIMIT") || i+2 >= len(command) {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		var err1, err2 error
+		query.offset, err1 = strconv.Atoi(command[i+1])
+		query.count, err2 = strconv.Atoi(command[i+2])
+		if err1 != nil || err2 != nil {
+			return c.writer.WriteError("ERR value is not an integer or out of range")
+		}
+		query.hasLimit = true
+		i += 2
+	}
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	reply := []any{}
+	if ok {
+		reply = zsetReply(query.selectRange(z), false)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteArray(reply)
+}
+
+// handleZlexcountCommand serves ZLEXCOUNT key min max, which replies with
+// the number of members ZRANGEBYLEX key min max would return.
+func (s *server) handleZlexcountCommand(c *client, command []string) error {
+	key := command[1]
+
+	query, message := parseLexQuery(command[2], command[3])
+	if message != "" {
+		return c.writer.WriteError(message)
+	}
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	count := 0
+	if ok {
+		count = len(query.selectRange(z))
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(int64(count))
+}
+
+// parseLexQuery returns the query for the members between min and max, or
+// an error message to reply with if they are not valid member bounds.
+func parseLexQuery(min string, max string) (zrangeQuery, string) {
+	query := zrangeQuery{byLex: true, count: -1}
+	minOk, maxOk := false, false
+	query.minLex, minOk = parseLexBound(min)
+	query.maxLex, maxOk = parseLexBound(max)
+	if !minOk || !maxOk {
+		return query, "ERR min or max not valid string range item"
+	}
+	return query, ""
+}
+
+// handleZrandmemberCommand serves ZRANDMEMBER key [count [WITHSCORES]],
+// which replies with random members, counted like SRANDMEMBER. WITHSCORES
+// follows each member with its score.
+func (s *server) handleZrandmemberCommand(c *client, command []string) error {
+	key := command[1]
+
+	count := 1
+	if len(command) >= 3 {
+		n, message := parseRandomCount(command[2])
+		if message != "" {
+			return c.writer.WriteError(message)
+		}
+		count = n
+	}
+	withScores := false
+	if len(command) == 4 {
+		if !strings.EqualFold(command[3], "withscores") {
+			return c.writer.WriteError("ERR syntax error")
+		}
+		withScores = true
+	}
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	var members []zsetMember
+	if ok {
+		indexes := randomIndexes(len(z.sorted), count)
+		members = make([]zsetMember, len(indexes))
+		for i, index := range indexes {
+			members[i] = z.sorted[index]
+		}
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	if len(command) == 2 {
+		if len(members) == 0 {
+			return c.writer.WriteNull()
+		}
+		return c.writer.WriteBulkString(members[0].member)
+	}
+	return c.writer.WriteArray(zsetReply(members, withScores))
+}
+
+func (s *server) handleZcardCommand(c *client, command []string) error {
+	key := command[1]
+
+	s.dbLock.RLock()
+	z, ok, wrongType := lookupTyped[*sortedSet](s, key)
+	size := 0
+	if ok {
+		size = len(z.sorted)
+	}
+	s.dbLock.RUnlock()
+
+	if wrongType {
+		return c.writer.WriteError(wrongTypeError)
+	}
+	if !ok {
+		s.expireKey(key)
+	}
+	return c.writer.WriteInteger(int64(size))
+}
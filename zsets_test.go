@@ -0,0 +1,176 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"mhmdiamd/go-redis-clone/internal/redistest"
+)
+
+func TestZrangebylex(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("ZADD", "key", "0", "a", "0", "b", "0", "c", "0", "d", "0", "e")
+	run(t, c, []step{
+		{[]string{"ZRANGEBYLEX", "key", "-", "+"}, "*5\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n$1\r\ne\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "[b", "[d"}, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "(b", "(d"}, "*1\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "(c", "+"}, "*2\r\n$1\r\nd\r\n$1\r\ne\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "+", "-"}, "*0\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "-", "+", "LIMIT", "1", "2"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "-", "+", "LIMIT", "3", "-1"}, "*2\r\n$1\r\nd\r\n$1\r\ne\r\n"},
+		{[]string{"ZRANGEBYLEX", "missing", "-", "+"}, "*0\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "b", "+"}, "-ERR min or max not valid string range item\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "-", "+", "LIMIT", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"ZRANGEBYLEX", "key", "-", "+", "WITHSCORES"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestZlexcount(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("ZADD", "key", "0", "a", "0", "b", "0", "c", "0", "d", "0", "e")
+	c.Do("SET", "string", "value")
+	run(t, c, []step{
+		{[]string{"ZLEXCOUNT", "key", "-", "+"}, ":5\r\n"},
+		{[]string{"ZLEXCOUNT", "key", "[b", "(e"}, ":3\r\n"},
+		{[]string{"ZLEXCOUNT", "missing", "-", "+"}, ":0\r\n"},
+		{[]string{"ZLEXCOUNT", "key", "a", "+"}, "-ERR min or max not valid string range item\r\n"},
+		{[]string{"ZLEXCOUNT", "string", "-", "+"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	})
+}
+
+func TestSortedSet(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"ZADD", "zset", "2", "b", "1", "a", "2", "a2", "3", "c"}, ":4\r\n"},
+		{[]string{"TYPE", "zset"}, "+zset\r\n"},
+		// Members with the same score are ordered by name.
+		{[]string{"ZRANGE", "zset", "0", "-1"}, "*4\r\n$1\r\na\r\n$2\r\na2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGE", "zset", "0", "1", "WITHSCORES"}, "*4\r\n$1\r\na\r\n$1\r\n1\r\n$2\r\na2\r\n$1\r\n2\r\n"},
+		{[]string{"ZRANGE", "missing", "0", "-1"}, "*0\r\n"},
+		{[]string{"ZSCORE", "zset", "c"}, "$1\r\n3\r\n"},
+		{[]string{"ZSCORE", "zset", "q"}, "$-1\r\n"},
+		{[]string{"ZRANK", "zset", "a"}, ":0\r\n"},
+		{[]string{"ZREVRANK", "zset", "a"}, ":3\r\n"},
+		{[]string{"ZRANK", "zset", "q"}, "$-1\r\n"},
+		{[]string{"ZCARD", "zset"}, ":4\r\n"},
+		{[]string{"ZCARD", "missing"}, ":0\r\n"},
+
+		{[]string{"SET", "string", "v"}, "+OK\r\n"},
+		{[]string{"ZADD", "string", "1", "a"}, wrongType},
+		{[]string{"ZRANGE", "string", "0", "-1"}, wrongType},
+		{[]string{"ZINCRBY", "string", "1", "a"}, wrongType},
+	})
+}
+
+func TestZadd(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"ZADD", "zset", "1", "a", "3", "c"}, ":2\r\n"},
+		// GT and LT only update scores in one direction, and CH counts
+		// changed members as well as added ones.
+		{[]string{"ZADD", "zset", "GT", "0", "c"}, ":0\r\n"},
+		{[]string{"ZSCORE", "zset", "c"}, "$1\r\n3\r\n"},
+		{[]string{"ZADD", "zset", "GT", "CH", "5", "c", "1", "d"}, ":2\r\n"},
+		{[]string{"ZSCORE", "zset", "c"}, "$1\r\n5\r\n"},
+		{[]string{"ZADD", "zset", "LT", "9", "c"}, ":0\r\n"},
+		{[]string{"ZSCORE", "zset", "c"}, "$1\r\n5\r\n"},
+		{[]string{"ZADD", "zset", "XX", "1", "new"}, ":0\r\n"},
+		{[]string{"ZADD", "zset", "NX", "9", "a"}, ":0\r\n"},
+		{[]string{"ZSCORE", "zset", "a"}, "$1\r\n1\r\n"},
+		{[]string{"ZADD", "missing", "XX", "1", "new"}, ":0\r\n"},
+		{[]string{"EXISTS", "missing"}, ":0\r\n"},
+		{[]string{"ZCARD", "zset"}, ":3\r\n"},
+
+		{[]string{"ZADD", "zset", "NX", "GT", "1", "x"}, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n"},
+		{[]string{"ZADD", "zset", "NX", "XX", "1", "x"}, "-ERR XX and NX options at the same time are not compatible\r\n"},
+		{[]string{"ZADD", "zset", "x", "a"}, "-ERR value is not a valid float\r\n"},
+		{[]string{"ZADD", "zset", "1", "a", "2"}, "-ERR syntax error\r\n"},
+	})
+}
+
+func TestZincrby(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	run(t, c, []step{
+		{[]string{"ZINCRBY", "zset", "1.5", "a"}, "$3\r\n1.5\r\n"},
+		{[]string{"ZINCRBY", "zset", "1", "a"}, "$3\r\n2.5\r\n"},
+		{[]string{"ZINCRBY", "zset", "inf", "b"}, "$3\r\ninf\r\n"},
+		{[]string{"ZINCRBY", "zset", "-inf", "b"}, "-ERR resulting score is not a number (NaN)\r\n"},
+		{[]string{"ZSCORE", "zset", "b"}, "$3\r\ninf\r\n"},
+		{[]string{"ZINCRBY", "zset", "x", "a"}, "-ERR value is not a valid float\r\n"},
+	})
+}
+
+func TestZrangebyscore(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("ZADD", "zset", "1", "a", "2", "b", "2", "c", "2.5", "d", "5", "e")
+	run(t, c, []step{
+		{[]string{"ZRANGEBYSCORE", "zset", "(1", "2.5", "WITHSCORES"}, "*6\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n2\r\n$1\r\nd\r\n$3\r\n2.5\r\n"},
+		{[]string{"ZRANGEBYSCORE", "zset", "-inf", "+inf", "LIMIT", "1", "2"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGEBYSCORE", "zset", "(2", "(5"}, "*1\r\n$1\r\nd\r\n"},
+		{[]string{"ZRANGEBYSCORE", "zset", "5", "1"}, "*0\r\n"},
+		{[]string{"ZRANGEBYSCORE", "missing", "-inf", "+inf"}, "*0\r\n"},
+		{[]string{"ZRANGEBYSCORE", "zset", "x", "5"}, "-ERR min or max is not a float\r\n"},
+	})
+}
+
+func TestZrangeOptions(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("ZADD", "zset", "1", "a", "2", "b", "3", "c", "4", "d")
+	c.Do("ZADD", "lex", "0", "a", "0", "b", "0", "c", "0", "d")
+	run(t, c, []step{
+		{[]string{"ZRANGE", "zset", "0", "1", "REV"}, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGE", "zset", "(1", "3", "BYSCORE"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		// With REV the range runs from max to min.
+		{[]string{"ZRANGE", "zset", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"}, "*2\r\n$1\r\nc\r\n$1\r\nb\r\n"},
+		{[]string{"ZRANGE", "lex", "[b", "(d", "BYLEX"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGE", "lex", "+", "(b", "BYLEX", "REV"}, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n"},
+		{[]string{"ZRANGE", "lex", "-", "+", "BYLEX", "LIMIT", "1", "1"}, "*1\r\n$1\r\nb\r\n"},
+
+		{[]string{"ZRANGE", "lex", "b", "d", "BYLEX"}, "-ERR min or max not valid string range item\r\n"},
+		{[]string{"ZRANGE", "lex", "-", "+", "BYLEX", "WITHSCORES"}, "-ERR syntax error, WITHSCORES not supported in combination with BYLEX\r\n"},
+		{[]string{"ZRANGE", "zset", "0", "1", "LIMIT", "0", "1"}, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n"},
+		{[]string{"ZRANGE", "zset", "0", "-1", "FOO"}, "-ERR syntax error\r\n"},
+		{[]string{"ZRANGE", "zset", "a", "-1"}, "-ERR value is not an integer or out of range\r\n"},
+	})
+}
+
+func TestZrangestore(t *testing.T) {
+	addr, _ := redistest.StartServer(t)
+	c := redistest.Connect(t, addr)
+
+	c.Do("ZADD", "zset", "1", "a", "2", "b", "3", "c", "4", "d")
+	run(t, c, []step{
+		{[]string{"ZRANGESTORE", "dst", "zset", "1", "2"}, ":2\r\n"},
+		{[]string{"ZRANGE", "dst", "0", "-1", "WITHSCORES"}, "*4\r\n$1\r\nb\r\n$1\r\n2\r\n$1\r\nc\r\n$1\r\n3\r\n"},
+		{[]string{"ZRANGESTORE", "dst", "zset", "0", "1", "REV"}, ":2\r\n"},
+		{[]string{"ZRANGE", "dst", "0", "-1"}, "*2\r\n$1\r\nc\r\n$1\r\nd\r\n"},
+		{[]string{"ZRANGESTORE", "dst", "zset", "2", "+inf", "BYSCORE", "LIMIT", "0", "2"}, ":2\r\n"},
+		{[]string{"ZRANGE", "dst", "0", "-1"}, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		// The source is left alone.
+		{[]string{"ZCARD", "zset"}, ":4\r\n"},
+		// An empty range deletes the destination.
+		{[]string{"ZRANGESTORE", "dst", "zset", "10", "20"}, ":0\r\n"},
+		{[]string{"EXISTS", "dst"}, ":0\r\n"},
+		// The destination is replaced whatever its type.
+		{[]string{"SET", "string", "x"}, "+OK\r\n"},
+		{[]string{"ZRANGESTORE", "string", "zset", "0", "0"}, ":1\r\n"},
+		{[]string{"TYPE", "string"}, "+zset\r\n"},
+
+		{[]string{"ZRANGESTORE", "dst", "zset", "0", "1", "WITHSCORES"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "string", "x"}, "+OK\r\n"},
+		{[]string{"ZRANGESTORE", "dst", "string", "0", "1"}, wrongType},
+	})
+}